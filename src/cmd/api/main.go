@@ -1,17 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"zugzwang/internal/api/backend"
 	"zugzwang/internal/api/frontend"
+	"zugzwang/internal/engine"
 	"zugzwang/internal/platform"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	var bookPath string
+	var bookBest bool
+	flag.StringVar(&bookPath, "book", "", "the Polyglot opening book (.bin) the engine plays its games from (none when empty)")
+	flag.BoolVar(&bookBest, "bookbest", false, "only play the book move with the highest weight")
+	flag.Parse()
+
 	fmt.Println("Starting backend API.")
 
+	// Setup the engine, the book is loaded by InitEngine
+	engine.BOOK_PATH = bookPath
+	engine.BOOK_BEST_ONLY = bookBest
+	engine.InitEngine()
+
 	// Setup the DB
 	err := platform.InitDB()
 	if err != nil {
@@ -3,27 +3,479 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
 	"zugzwang/internal/engine"
 )
 
 /*
 This is the main binary for the command line interface with the chess engine
+The uci action plays over UCI on stdin and stdout, ex. go run ./cmd/engine -action uci -playbook book.bin plays from an opening book.
+Any action can be profiled with -cpuprofile, -memprofile and -trace.
+The default.pgo next to this file is a CPU profile of the bench, which go build uses for profile-guided optimization.
+Regenerate it after large changes to the search or evaluation with: go run ./cmd/engine -action bench -cpuprofile cmd/engine/default.pgo
 */
 
 func main() {
 
 	var action string
+	var resultsPath string
+	var revision string
+	var config string
+	var suite string
+	var base string
+	var head string
+	var engineA string
+	var engineB string
+	var games int
+	var openingsPath string
+	var maxPlies int
+	var sprt bool
+	var elo0 float64
+	var elo1 float64
+	var positionsPath string
+	var outPath string
+	var epochs int
+	var learningRate float64
+	var k float64
+	var paramsPath string
+	var fen string
+	var tbCode string
+	var tbDir string
+	var syzygyPath string
+	var pgnPaths string
+	var bookPath string
+	var playBook string
+	var bookBest bool
+	var bookPlies int
+	var bookMinGames int
+	var bookMinShare float64
+	var elos string
+	var depth uint
+	var divide bool
+	var epdPath string
+	var threads int
+	var perftHashMB int
+	var cpuProfile string
+	var memProfile string
+	var tracePath string
+	var attacks string
 	flag.StringVar(&action, "action", "perft", "the action the program takes")
+	flag.StringVar(&resultsPath, "results", "", "the file benchmark and strength test results are saved to and compared from (ex. results.json, not saved when empty)")
+	flag.StringVar(&revision, "rev", "", "the revision to save or compare results under (defaults to the current git revision)")
+	flag.StringVar(&config, "config", "default", "the configuration label to save or compare results under")
+	flag.StringVar(&suite, "suite", engine.SUITE_BENCHMARK, "the suite to compare (benchmark or strengthtest)")
+	flag.StringVar(&base, "base", "", "the base revision to compare against")
+	flag.StringVar(&head, "head", "", "the head revision to compare (defaults to -rev)")
+	flag.StringVar(&engineA, "a", "", "search options of engine A in a match (ex. depth=5,movetime=200,nodes=10000,elo=1200,lmr=false,eval=params.json,personality=aggressive)")
+	flag.StringVar(&engineB, "b", "", "search options of engine B in a match")
+	flag.IntVar(&games, "games", 0, "the maximum number of games in a match (defaults to every opening with both colours)")
+	flag.StringVar(&openingsPath, "openings", "", "a file of opening FENs for a match (defaults to the built in openings)")
+	flag.IntVar(&maxPlies, "maxplies", engine.DEFAULT_MAX_PLIES, "games in a match longer than this are adjudicated as a draw")
+	flag.BoolVar(&sprt, "sprt", true, "stop the match once the SPRT accepts or rejects")
+	flag.Float64Var(&elo0, "elo0", engine.DEFAULT_SPRT_OPTIONS.Elo0, "the SPRT null hypothesis, in Elo")
+	flag.Float64Var(&elo1, "elo1", engine.DEFAULT_SPRT_OPTIONS.Elo1, "the SPRT alternative hypothesis, in Elo")
+	flag.StringVar(&positionsPath, "positions", "", "a file of quiet labelled positions (FEN and game result) to tune on")
+	flag.StringVar(&outPath, "out", engine.DEFAULT_TUNE_OPTIONS.OutPath, "the file tuned evaluation parameters are written to")
+	flag.IntVar(&epochs, "epochs", engine.DEFAULT_TUNE_OPTIONS.Epochs, "the number of gradient descent passes when tuning")
+	flag.Float64Var(&learningRate, "lr", engine.DEFAULT_TUNE_OPTIONS.LearningRate, "the learning rate when tuning, in centipawns")
+	flag.Float64Var(&k, "k", 0, "the sigmoid scaling when tuning (fitted to the positions when 0)")
+	flag.StringVar(&paramsPath, "params", "", "an evaluation parameter file to use instead of the compiled defaults")
+	flag.StringVar(&fen, "fen", string(engine.STARTING_POSITION_FEN), "the position to explain the evaluation of, to probe the tablebases with, or to count with perft")
+	flag.StringVar(&tbCode, "tb", "", "the endgame to generate a tablebase of (ex. KQKR)")
+	flag.StringVar(&tbDir, "tbdir", "", "a directory of tablebases generated by the engine, probed by the search and where generated ones are saved")
+	flag.StringVar(&syzygyPath, "syzygy", "", "directories of Syzygy tablebases (.rtbw and .rtbz files) probed by the search, separated like PATH")
+	flag.StringVar(&pgnPaths, "pgn", "", "PGN files of games to build an opening book from, separated like PATH")
+	flag.StringVar(&bookPath, "book", engine.DEFAULT_BOOK_OPTIONS.OutPath, "the Polyglot opening book (.bin) an opening book is written to")
+	flag.StringVar(&playBook, "playbook", "", "the Polyglot opening book (.bin) the engine plays from before searching (none when empty)")
+	flag.BoolVar(&bookBest, "bookbest", false, "only play the book move with the highest weight, instead of picking one at random by the weights")
+	flag.IntVar(&bookPlies, "bookplies", engine.DEFAULT_BOOK_OPTIONS.MaxPlies, "only the first plies of the games are put in an opening book")
+	flag.IntVar(&bookMinGames, "bookmin", engine.DEFAULT_BOOK_OPTIONS.MinGames, "moves played in fewer games are left out of an opening book")
+	flag.Float64Var(&bookMinShare, "bookshare", engine.DEFAULT_BOOK_OPTIONS.MinShare, "moves played in less than this share (0 to 1) of the games of a position are left out of an opening book")
+	flag.StringVar(&elos, "elos", "", "the skill level Elos to calibrate, separated by commas (defaults to every skill level)")
+	flag.UintVar(&depth, "depth", 0, "the depth to count the -fen position to with perft, or the deepest counts checked in an EPD suite (0 runs the built in perft tests), or the depth of the bench (0 for the default)")
+	flag.BoolVar(&divide, "divide", false, "print the perft count of every root move")
+	flag.StringVar(&epdPath, "epd", "", "an EPD perft suite to check (ex. perftsuite.epd, \"<fen> ;D1 20 ;D2 400\")")
+	flag.IntVar(&threads, "threads", 0, "the number of goroutines counting perft root moves (defaults to the number of CPUs)")
+	flag.IntVar(&perftHashMB, "perfthash", engine.DEFAULT_PERFT_OPTIONS.HashMB, "the size of the perft hash table in MB (0 for no hash table)")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the action to the file (the bench profile is the default.pgo of the engine)")
+	flag.StringVar(&memProfile, "memprofile", "", "write a heap profile to the file after the action")
+	flag.StringVar(&tracePath, "trace", "", "write an execution trace of the action to the file")
+	flag.StringVar(&attacks, "attacks", "", "the sliding piece attack backend (magic or pext, defaults to magic)")
 	flag.Parse()
 
+	if err := startProfiling(cpuProfile, memProfile, tracePath); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer stopProfiling()
+
+	engine.EVAL_PARAMS_FILE = paramsPath
+	engine.TABLEBASE_DIR = tbDir
+	engine.SYZYGY_PATH = syzygyPath
+	engine.BOOK_PATH = playBook
+	engine.BOOK_BEST_ONLY = bookBest
+	engine.ATTACK_BACKEND = engine.AttackBackend(attacks)
+
 	switch action {
 	case "perft":
-		engine.Perft()
+		engine.InitEngine()
+		if depth >= engine.MAX_PLY {
+			fmt.Printf("Invalid perft depth %d; Should be less than %d\n", depth, engine.MAX_PLY)
+			exit(1)
+		}
+		options := engine.PerftOptions{Depth: uint8(depth), Divide: divide, Threads: threads, HashMB: perftHashMB}
+		switch {
+		case epdPath != "":
+			passed, err := engine.RunPerftSuite(epdPath, options)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			if !passed {
+				exit(1)
+			}
+		case depth > 0:
+			result, err := engine.RunPerft(engine.FEN(fen), options)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			if !divide {
+				fmt.Printf("Nodes: %d\nTime: %v\n", result.Nodes, result.Duration)
+			}
+		default:
+			if !engine.Perft(options) {
+				exit(1)
+			}
+		}
+	case "bench":
+		engine.InitEngine()
+		benchDepth := engine.BENCH_DEPTH
+		if depth > 0 {
+			benchDepth = uint8(min(depth, 10))
+		}
+		fmt.Println(engine.Bench(benchDepth))
+	case "attacks":
+		engine.InitEngine()
+		benchDepth := engine.BENCH_DEPTH
+		if depth > 0 {
+			benchDepth = uint8(min(depth, 10))
+		}
+		results, err := engine.CompareAttackBackends(benchDepth)
+		for _, result := range results {
+			fmt.Println(result)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		if !engine.HAS_PEXT {
+			fmt.Println("The CPU has no PEXT (BMI2), only the magics can be used")
+		}
 	case "strengthtest":
-		engine.StrengthTest()
+		saveRun(engine.StrengthTest(), resultsPath, revision, config)
 	case "benchmark":
-		engine.RunBenchmark()
+		saveRun(engine.RunBenchmark(), resultsPath, revision, config)
+	case "compare":
+		if head == "" {
+			head = revision
+		}
+		if head == "" {
+			head = currentRevision()
+		}
+		if !compareRuns(resultsPath, suite, base, head, config) {
+			exit(1)
+		}
+	case "match", "selfplay":
+		options, err := matchOptions(engineA, engineB, openingsPath, games, maxPlies)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		if sprt {
+			sprtOptions := engine.DEFAULT_SPRT_OPTIONS
+			sprtOptions.Elo0 = elo0
+			sprtOptions.Elo1 = elo1
+			options.SPRT = &sprtOptions
+		}
+		result := engine.RunMatch(options)
+		if result.Decision == engine.SPRT_REJECT {
+			exit(1)
+		}
+	case "calibrate":
+		options := engine.CalibrationOptions{Games: games, MaxPlies: maxPlies}
+		for field := range strings.SplitSeq(elos, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			elo, err := strconv.Atoi(field)
+			if err != nil || elo <= 0 {
+				fmt.Printf("Invalid Elo %q; Should be a positive number\n", field)
+				exit(1)
+			}
+			options.Elos = append(options.Elos, elo)
+		}
+		engine.CalibrateSkill(options)
+	case "eval":
+		engine.InitEngine()
+		explanation, err := engine.ExplainEval(engine.FEN(fen))
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		fmt.Print(explanation)
+	case "tune":
+		if positionsPath == "" {
+			fmt.Println("A file of labelled positions is required to tune (-positions)")
+			exit(1)
+		}
+		_, err := engine.Tune(engine.TuneOptions{
+			PositionsPath: positionsPath,
+			OutPath:       outPath,
+			Epochs:        epochs,
+			LearningRate:  learningRate,
+			K:             k,
+		})
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+	case "tablebase":
+		if tbCode == "" || tbDir == "" {
+			fmt.Println("An endgame (-tb) and a directory to save it in (-tbdir) are required to generate a tablebase")
+			exit(1)
+		}
+		engine.InitEngine()
+		if err := engine.GenerateTablebase(tbCode, tbDir); err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+	case "syzygy":
+		if syzygyPath == "" {
+			fmt.Println("A directory of Syzygy tablebases is required to probe them (-syzygy)")
+			exit(1)
+		}
+		engine.InitEngine()
+		wdl, dtz, err := engine.ProbeSyzygy(engine.FEN(fen))
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		fmt.Printf("WDL: %d, DTZ: %d\n", wdl, dtz)
+	case "uci":
+		engine.InitEngine()
+		if err := engine.RunUCI(os.Stdin, os.Stdout); err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+	case "book":
+		if pgnPaths == "" {
+			fmt.Println("PGN files of games are required to build an opening book (-pgn)")
+			exit(1)
+		}
+		engine.InitEngine()
+		options := engine.DEFAULT_BOOK_OPTIONS
+		options.PGNPaths = filepath.SplitList(pgnPaths)
+		options.OutPath = bookPath
+		options.MaxPlies = bookPlies
+		options.MinGames = bookMinGames
+		options.MinShare = bookMinShare
+		summary, err := engine.BuildBook(options)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		fmt.Printf("Read %d games (%d skipped) reaching %d positions, wrote %d moves to %v\n", summary.Games, summary.Skipped, summary.Positions, summary.Moves, bookPath)
 	default:
 		fmt.Println("The action is not supported: ", action)
 	}
 }
+
+// Stops the profiles started for the action, set by startProfiling
+var stopProfiling = func() {}
+
+// Start the profiles of the flags that are set, the memory profile is written when they are stopped
+func startProfiling(cpuProfile, memProfile, tracePath string) error {
+	var stops []func()
+	stopProfiling = func() {
+		for _, stop := range slices.Backward(stops) {
+			stop()
+		}
+		stops = nil
+	}
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			stopProfiling()
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopProfiling()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+
+	if memProfile != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(memProfile)
+			if err != nil {
+				fmt.Println("Failed to write the memory profile: ", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				fmt.Println("Failed to write the memory profile: ", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Exit with the code, writing the profiles first (deferred calls do not run on os.Exit)
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
+
+// Save a run to the results store, keyed by revision and config, the current git revision when none is given
+func saveRun(run *engine.TestRun, path, revision, config string) {
+	if path == "" {
+		return
+	}
+	if revision == "" {
+		revision = currentRevision()
+	}
+
+	run.Revision = revision
+	run.Config = config
+	if err := engine.SaveTestRun(path, run); err != nil {
+		fmt.Println("Failed to save the results: ", err)
+		return
+	}
+	fmt.Printf("Saved %v results for revision %v (%v) to %v\n", run.Suite, revision, config, path)
+}
+
+// Compare two saved runs, returns false if the comparison failed or the head regressed
+func compareRuns(path, suite, base, head, config string) bool {
+	if path == "" {
+		fmt.Println("A results file is required to compare (-results)")
+		return false
+	}
+	if base == "" {
+		fmt.Println("A base revision is required to compare (-base)")
+		return false
+	}
+
+	runs, err := engine.LoadTestRuns(path)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	baseRun, err := engine.FindTestRun(runs, suite, base, config)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	headRun, err := engine.FindTestRun(runs, suite, head, config)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return !engine.CompareTestRuns(baseRun, headRun)
+}
+
+// Build the options of a self-play match from the command line flags
+func matchOptions(engineA, engineB, openingsPath string, games, maxPlies int) (engine.MatchOptions, error) {
+	var options engine.MatchOptions
+	options.Games = games
+	options.MaxPlies = maxPlies
+
+	for i, spec := range []string{engineA, engineB} {
+		searchOptions, err := engine.ParseSearchOptions(spec)
+		if err != nil {
+			return options, err
+		}
+		options.Engines[i] = searchOptions
+	}
+
+	if openingsPath != "" {
+		openings, err := engine.LoadOpenings(openingsPath)
+		if err != nil {
+			return options, err
+		}
+		options.Openings = openings
+	}
+
+	return options, nil
+}
+
+// Get the git revision of the build, falling back to asking git directly (go run does not stamp vcs info)
+func currentRevision() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		revision := ""
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if revision != "" {
+			if len(revision) > 7 {
+				revision = revision[:7]
+			}
+			if modified {
+				revision += "-dirty"
+			}
+			return revision
+		}
+	}
+
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	revision := strings.TrimSpace(string(out))
+
+	// Mark uncommitted changes, so they are not confused with the committed build
+	status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -1,6 +1,32 @@
 package backend
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"zugzwang/internal/engine"
+	"zugzwang/internal/platform"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// The move of the player, sent over the websocket of a game
+// The position is the FEN after the player's move, and the history the FENs of the game before it, for repetitions
+type GameMove struct {
+	Position string   `json:"position"`
+	History  []string `json:"history"`
+}
+
+// The answer of the engine to a move of the player, its move in PCN or an error
+type GameReply struct {
+	Move  string `json:"move,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Upgrades the requests to start a game to websocket connections
+var upgrader = websocket.Upgrader{}
 
 /*
  *	route: "/start/{game_id}"
@@ -9,6 +35,67 @@ import "github.com/gin-gonic/gin"
  *  returns: Either an error that the game could not be started, or upgrades to a WS connection
  */
 func HandleGame(c *gin.Context) {
-	//gameId := c.Param("game_id")
+	gameId := c.Param("game_id")
+
+	// The game has to be set up first, and can only be started once
+	session, ok := StartSession(gameId)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found."})
+		return
+	}
+
+	// The game ends when this returns, which ends its session and stops the engine pondering for it
+	defer EndSession(gameId)
+
+	if err := platform.StartGame(gameId, c.Request.Context()); err != nil {
+		fmt.Println("Failed to start the game.")
+
+		// Return generic error to user
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error."})
+		return
+	}
+
+	// The request is done once the connection is upgraded, so the game is ended without its context
+	defer func() {
+		if err := platform.EndGame(gameId, context.Background()); err != nil {
+			fmt.Println("Failed to end the game.")
+		}
+	}()
+
+	// Upgrade replies with the error itself if the request is not a websocket request
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Answer each move of the player with the engine's move, until the player closes the connection
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply GameReply
+		var move GameMove
+		if err := json.Unmarshal(message, &move); err != nil {
+			reply.Error = fmt.Sprintf("Invalid move %q; Should be a JSON object with the position and history", message)
+		} else {
+			history := make([]engine.FEN, len(move.History))
+			for i, position := range move.History {
+				history[i] = engine.FEN(position)
+			}
+
+			engineMove, err := session.EngineMove(engine.FEN(move.Position), history)
+			if err != nil {
+				reply.Error = err.Error()
+			} else {
+				reply.Move = engineMove
+			}
+		}
 
+		if err := conn.WriteJSON(reply); err != nil {
+			return
+		}
+	}
 }
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"zugzwang/internal/engine"
+)
+
+// This file holds the game sessions, the engine side of the games set up by the frontend
+// A session is created by HandleSetup with the options the engine plays the game with, and is played by HandleGame
+// The session ends with the game, when its connection closes, or if the game is not started in time
+// After the engine moves it ponders on the player's expected reply, so the time the player thinks is not wasted
+
+// The engine side of a game
+type Session struct {
+	GameId string
+
+	// How the engine searches its moves, at the strength the player asked for
+	Options engine.SearchOptions
+
+	// Whether the game was started by HandleGame, guarded by sessionsLock
+	started bool
+}
+
+// How long a game that was set up has to be started, before its session is ended
+const SESSION_START_TIMEOUT = 5 * time.Minute
+
+// The sessions of the games, by game id
+// The engine searches one position at a time, so ENGINE_LOCK is held for every call into the engine
+var sessions = map[string]*Session{}
+var sessionsLock sync.Mutex
+var ENGINE_LOCK sync.Mutex
+
+// The session the engine is pondering for, nil when it is not pondering, guarded by ENGINE_LOCK
+var pondering *Session
+
+// Get the options the engine plays a game with from its setup
+// The engine plays at the Elo of the setup, with its personality if it has one (see engine.PERSONALITIES)
+func SessionOptions(setup Setup) (engine.SearchOptions, error) {
+	if setup.Elo <= 0 {
+		return engine.SearchOptions{}, fmt.Errorf("Invalid Elo %d; Should be a positive number", setup.Elo)
+	}
+
+	options := engine.DEFAULT_SEARCH_OPTIONS
+	options.Elo = setup.Elo
+	if setup.Personality != "" {
+		if _, err := engine.ParsePersonality(setup.Personality); err != nil {
+			return engine.SearchOptions{}, err
+		}
+		options.Personality = strings.ToLower(strings.TrimSpace(setup.Personality))
+	}
+	return options, nil
+}
+
+// Create the session of a game, the engine plays it with the options
+// The session is ended if the game is not started within SESSION_START_TIMEOUT
+func NewSession(gameId string, options engine.SearchOptions) *Session {
+	session := &Session{GameId: gameId, Options: options}
+
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	sessions[gameId] = session
+	time.AfterFunc(SESSION_START_TIMEOUT, session.expire)
+	return session
+}
+
+// End the session if its game was not started
+func (s *Session) expire() {
+	sessionsLock.Lock()
+	expired := !s.started && sessions[s.GameId] == s
+	sessionsLock.Unlock()
+	if expired {
+		EndSession(s.GameId)
+	}
+}
+
+// Start the session of a game, false if the game was not set up, has ended, or was already started
+func StartSession(gameId string) (*Session, bool) {
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	session, ok := sessions[gameId]
+	if !ok || session.started {
+		return nil, false
+	}
+	session.started = true
+	return session, true
+}
+
+// Get the session of a game, false if the game was not set up or has ended
+func GetSession(gameId string) (*Session, bool) {
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	session, ok := sessions[gameId]
+	return session, ok
+}
+
+// End the session of a game, stopping the engine pondering for it
+func EndSession(gameId string) {
+	sessionsLock.Lock()
+	session, ok := sessions[gameId]
+	delete(sessions, gameId)
+	sessionsLock.Unlock()
+	if !ok {
+		return
+	}
+
+	ENGINE_LOCK.Lock()
+	defer ENGINE_LOCK.Unlock()
+	if pondering == session {
+		engine.StopPonder()
+		pondering = nil
+	}
+}
+
+/*
+Search the engine's move in the position, returns the move in PCN.
+If the engine pondered on the player's reply for this session, the ponder search is used, else the position is searched.
+After the move the engine ponders on the player's expected reply, until the next move of a session is searched.
+*/
+func (s *Session) EngineMove(position engine.FEN, history []engine.FEN) (string, error) {
+	ENGINE_LOCK.Lock()
+	defer ENGINE_LOCK.Unlock()
+
+	// A miss stops the ponder search, and the position is searched like any other
+	var response *engine.EvaluateResponse
+	if pondering == s {
+		response, _ = engine.PonderHit(position, 1)
+	}
+	pondering = nil
+	if response == nil {
+		var err error
+		response, err = engine.Evalute(position, history, 1, s.Options)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	move := response.BestMove()
+	if move == "" {
+		return "", fmt.Errorf("Invalid position %v; Should have a legal move for the engine", position)
+	}
+
+	// Ponder on the position after the move, there is nothing to ponder on if the move ends the game
+	after, err := engine.PlayMove(position, move)
+	if err != nil {
+		return "", err
+	}
+	if _, err := engine.StartPonder(after, append(slices.Clone(history), position), s.Options); err == nil {
+		pondering = s
+	}
+	return move, nil
+}
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zugzwang/internal/engine"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionOptions(t *testing.T) {
+	// Tests setup to be run, with the personality of the options or the error
+	tests := []struct {
+		setup       Setup
+		personality string
+		err         bool
+	}{
+		{setup: Setup{Name: "a", Elo: 1200}},
+		{setup: Setup{Name: "a", Elo: 1200, Personality: " Aggressive "}, personality: "aggressive"},
+		{setup: Setup{Name: "a", Elo: 0}, err: true},
+		{setup: Setup{Name: "a", Elo: 1200, Personality: "reckless"}, err: true},
+	}
+
+	for _, tc := range tests {
+		options, err := SessionOptions(tc.setup)
+		if (err != nil) != tc.err {
+			t.Errorf("%+v: Expected an error %v, got %v", tc.setup, tc.err, err)
+			continue
+		}
+		if err == nil && (options.Elo != tc.setup.Elo || options.Personality != tc.personality) {
+			t.Errorf("%+v: Expected Elo %d and personality %q, got %+v", tc.setup, tc.setup.Elo, tc.personality, options)
+		}
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	// A game is started once, and its session is gone when it ends
+	NewSession("started", engine.DEFAULT_SEARCH_OPTIONS)
+	if _, ok := StartSession("started"); !ok {
+		t.Fatalf("Expected the session to start")
+	}
+	if _, ok := StartSession("started"); ok {
+		t.Errorf("Expected the session to only start once")
+	}
+	session, _ := GetSession("started")
+	session.expire()
+	if _, ok := GetSession("started"); !ok {
+		t.Errorf("Expected a started session to not expire")
+	}
+	EndSession("started")
+	if _, ok := GetSession("started"); ok {
+		t.Errorf("Expected the session to end with its game")
+	}
+
+	// A game that is never started has its session ended
+	pending := NewSession("pending", engine.DEFAULT_SEARCH_OPTIONS)
+	pending.expire()
+	if _, ok := GetSession("pending"); ok {
+		t.Errorf("Expected the session of a game that was not started to expire")
+	}
+	if _, ok := StartSession("pending"); ok {
+		t.Errorf("Expected an expired session to not start")
+	}
+
+	// A game that was not set up can not be started
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, router := gin.CreateTestContext(w)
+	router.GET("/start/:game_id", HandleGame)
+	c.Request = httptest.NewRequest(http.MethodGet, "/start/missing", nil)
+	router.HandleContext(c)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a game that was not set up to not be found, got %d", w.Code)
+	}
+
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions to be left, got %d", len(sessions))
+	}
+}
+
+func TestSessionEngineMove(t *testing.T) {
+	engine.InitEngine()
+
+	options := engine.DEFAULT_SEARCH_OPTIONS
+	options.Depth = 3
+	session := NewSession("game", options)
+	defer EndSession("game")
+
+	// After its move the engine ponders on the player's reply, until the game ends
+	position := engine.FEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	move, err := session.EngineMove(position, nil)
+	if err != nil || move == "" {
+		t.Fatalf("Expected a move, got %q (error: %v)", move, err)
+	}
+	if pondering != session || engine.PONDER == nil {
+		t.Fatalf("Expected the engine to ponder for the session")
+	}
+
+	// The next move is searched from the ponder search on a hit, or searched again on a miss
+	after, err := engine.PlayMove(position, move)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	next, err := engine.PlayMove(after, engine.PONDER.Reply)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if move, err := session.EngineMove(next, []engine.FEN{position, after}); err != nil || move == "" {
+		t.Errorf("Expected a move, got %q (error: %v)", move, err)
+	}
+
+	EndSession("game")
+	if pondering != nil || engine.PONDER != nil {
+		t.Errorf("Expected the engine to stop pondering when the game ends")
+	}
+}
@@ -10,8 +10,9 @@ import (
 
 // Struct to bind json POST data
 type Setup struct {
-	Name string `json:"name" binding:"required"`
-	Elo  int    `json:"elo" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Elo         int    `json:"elo" binding:"required"`
+	Personality string `json:"personality"`
 }
 
 /*
@@ -24,8 +25,8 @@ func HandleSetup(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Validate the request
-	// Request must have a name/username of the user and their estimated elo
-	// This is just for analytics, not security or anything
+	// Request must have a name/username of the user and the elo the engine should play at, and can pick a personality
+	// The name is just for analytics, not security or anything
 	var setup Setup
 	if err := c.ShouldBindBodyWithJSON(&setup); err != nil {
 
@@ -34,6 +35,13 @@ func HandleSetup(c *gin.Context) {
 		return
 	}
 
+	// Get how the engine plays the game, before the game is created
+	options, err := SessionOptions(setup)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create a new game
 	// TODO: There are legitmate reasons why a game my fail other than a server error (like too many current games running)
 	gameId, err := platform.CreateGame(setup.Name, setup.Elo, ctx)
@@ -45,6 +53,9 @@ func HandleSetup(c *gin.Context) {
 		return
 	}
 
+	// Setup the engine side of the game, at the strength the user asked for
+	NewSession(gameId, options)
+
 	// Return the game id to the user, this is what the frontend will use to establish the websocket connection later
 	c.JSON(http.StatusCreated, gin.H{"gameId": gameId})
 }
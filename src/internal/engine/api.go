@@ -14,12 +14,16 @@ type EvaluateResponse struct {
 
 /*
 Evaluate is the standard function to evalute a position, to be used by the API package to utilize the engine.
+The options set how the engine searches, and the Elo it plays at (see DEFAULT_SEARCH_OPTIONS).
 */
-func Evalute(position FEN, history []FEN, numberOfMoves int) (*EvaluateResponse, error) {
+func Evalute(position FEN, history []FEN, numberOfMoves int, options SearchOptions) (*EvaluateResponse, error) {
 	// Time the function from start to button, including building the board and history
 	// This is done as this provides a more accurate evalution of how fast the engine is
 	start := time.Now()
 
+	// The engine searches one position at a time, so a ponder search is stopped first
+	StopPonder()
+
 	// Build the board from the position
 	// This can fail if position is not a valid FEN string
 	board, err := position.toBoard(history)
@@ -27,8 +31,16 @@ func Evalute(position FEN, history []FEN, numberOfMoves int) (*EvaluateResponse,
 		return nil, err
 	}
 
+	// Play from the opening book while the position is in it, without searching
+	if moveEvals := board.probeBook(numberOfMoves); moveEvals != nil {
+		return &EvaluateResponse{
+			MoveEvals: moveEvals,
+			duration:  time.Since(start),
+		}, nil
+	}
+
 	// Search the board and get the results
-	results := board.search(numberOfMoves)
+	results := board.search(numberOfMoves, options)
 
 	// Stop the time
 	end := time.Now()
@@ -40,6 +52,29 @@ func Evalute(position FEN, history []FEN, numberOfMoves int) (*EvaluateResponse,
 	}, nil
 }
 
+// Get the move to play from the response, in PCN, empty if the position has no legal moves
+func (r *EvaluateResponse) BestMove() string {
+	if len(r.MoveEvals) == 0 {
+		return ""
+	}
+	return r.MoveEvals[0].move.toPCN()
+}
+
+// Play a move in PCN in the position, returns the position after it
+func PlayMove(position FEN, move string) (FEN, error) {
+	board, err := position.toBoard(nil)
+	if err != nil {
+		return "", err
+	}
+
+	m, err := board.moveFromPCN(move)
+	if err != nil {
+		return "", err
+	}
+	board.makeMove(m)
+	return board.toFEN(), nil
+}
+
 /*
 InitEngine should be called once at startup.
 This setups globals like TT tables, Zobrist keys, and pregenerated moves
@@ -54,12 +89,17 @@ func InitEngine() {
 	initKingMoves()
 	initMagicRook()
 	initMagicBishop()
-
-	// Setup PSTs
-	initPST()
+	initAttackBackend()
 
 	// Setup eval
 	initEval()
+	initPawnHash()
+	initEndgames()
+	initTablebases()
+	initSyzygy()
+
+	// Setup the opening book
+	initBook()
 
 	// Setup TT
 	initTT()
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+This file holds the bench, a fixed depth search of a fixed list of positions.
+The total nodes searched is a signature of the search: it only changes when the search or evaluation changes what it
+searches, so a refactor that is meant to only be faster has to keep it, and a change that is meant to change the search
+should state the new signature. The time taken gives the nodes per second, to compare the speed of builds.
+*/
+
+// The positions of the bench, from the opening to the endgame
+var BENCH_POSITIONS = []FEN{
+	STARTING_POSITION_FEN,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ-- - 1 8",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	"r2q1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP2BPPP/R2QK2R w KQ-- - 0 9",
+	"2r3k1/pp3ppp/4p3/3pP3/3P4/P4N2/1P3PPP/2R3K1 b - - 0 22",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"8/8/4k3/3p4/3P4/4K3/8/8 w - - 0 1",
+	"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1",
+}
+
+// The depth the bench searches each position to
+const BENCH_DEPTH uint8 = 7
+
+// The nodes of the bench to BENCH_DEPTH, checked by TestBench
+// Update it in the change that changes what the search searches, and never in a change that is only meant to be faster
+const BENCH_SIGNATURE = 2368755
+
+// The result of a bench
+type BenchResult struct {
+	Nodes    int
+	Duration time.Duration
+}
+
+// Search every bench position to the depth from a cleared TT and pawn hash, so the nodes do not depend on what ran before
+// The engine has to be initialized first
+func Bench(depth uint8) BenchResult {
+	options := DEFAULT_SEARCH_OPTIONS
+	options.Depth = depth
+
+	var result BenchResult
+	for _, position := range BENCH_POSITIONS {
+		board, err := position.toBoard(nil)
+		if err != nil {
+			panic(err)
+		}
+		ClearTT()
+		clearPawnHash()
+
+		start := time.Now()
+		result.Nodes += board.iterativeSearch(options).nodes
+		result.Duration += time.Since(start)
+	}
+	return result
+}
+
+// Print the result of a bench, the nodes line is the signature
+func (r BenchResult) String() string {
+	nps := float64(r.Nodes) / r.Duration.Seconds()
+	return fmt.Sprintf("Nodes: %d\nTime: %v\nNodes per second: %.0f", r.Nodes, r.Duration.Round(time.Millisecond), nps)
+}
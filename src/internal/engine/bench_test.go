@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"testing"
+)
+
+/*
+Benchmarks of the hot paths of the engine, run with: go test ./internal/engine -run '^$' -bench . -benchmem
+Each op goes over every bench position, and every benchmark reports its allocations, which should stay at 0 for all
+but the search.
+*/
+
+// Setup the boards of the bench positions
+func benchBoards(b *testing.B) []*Board {
+	b.Helper()
+	InitEngine()
+
+	boards := make([]*Board, len(BENCH_POSITIONS))
+	for i, position := range BENCH_POSITIONS {
+		board, err := position.toBoard(nil)
+		if err != nil {
+			b.Fatalf("Failed to build %v: %v", position, err)
+		}
+		boards[i] = board
+	}
+	return boards
+}
+
+func BenchmarkGenerateMoves(b *testing.B) {
+	boards := benchBoards(b)
+	var moves MoveList
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for _, board := range boards {
+			board.generatePseudoLegalMoves(&moves)
+		}
+	}
+}
+
+func BenchmarkMakeUnmake(b *testing.B) {
+	boards := benchBoards(b)
+	moves := make([][]Move, len(boards))
+	for i, board := range boards {
+		moves[i] = board.generateLegalMoves()
+		board.History = make(GameHistory, 0, STARTING_HISTORY_LENGTH)
+	}
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for i, board := range boards {
+			for _, move := range moves[i] {
+				unmake, _ := board.makeMove(move)
+				board.unMakeMove(unmake)
+			}
+		}
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	boards := benchBoards(b)
+	clearPawnHash()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for _, board := range boards {
+			board.eval()
+		}
+	}
+}
+
+func BenchmarkOrderScore(b *testing.B) {
+	boards := benchBoards(b)
+	moves := make([][]Move, len(boards))
+	for i, board := range boards {
+		moves[i] = board.generateLegalMoves()
+	}
+	var killers [2]Move
+	var cutoffHistory CutoffHeuristic
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for i, board := range boards {
+			for _, move := range moves[i] {
+				move.orderScore(board, nil, &killers, &killers, &cutoffHistory)
+			}
+		}
+	}
+}
+
+func BenchmarkQuiescence(b *testing.B) {
+	boards := benchBoards(b)
+	moveStack := make([]MoveList, MAX_PLY)
+	for _, board := range boards {
+		board.History = make(GameHistory, 0, STARTING_HISTORY_LENGTH)
+	}
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for _, board := range boards {
+			board.quiescence(0, MIN_EVAL, MAX_EVAL, moveStack)
+		}
+	}
+}
+
+// The bench at a shallow depth, with its nodes per second
+// The nodes are the same every run, see TestBench
+func BenchmarkSearch(b *testing.B) {
+	benchBoards(b)
+	b.ReportAllocs()
+
+	var result BenchResult
+	for b.Loop() {
+		result = Bench(4)
+	}
+	b.ReportMetric(float64(result.Nodes), "nodes")
+	b.ReportMetric(float64(result.Nodes)/result.Duration.Seconds(), "nodes/s")
+}
+
+func TestBench(t *testing.T) {
+	InitEngine()
+
+	// The bench searches the same nodes every time, whatever ran before it
+	first := Bench(3)
+	Evalute(STARTING_POSITION_FEN, nil, 1, SearchOptions{Depth: 4})
+	second := Bench(3)
+	if first.Nodes == 0 || first.Nodes != second.Nodes {
+		t.Errorf("Expected the bench to search the same nodes every time, got %d and %d", first.Nodes, second.Nodes)
+	}
+
+	// The signature only changes with what the search searches
+	if bench := Bench(BENCH_DEPTH); bench.Nodes != BENCH_SIGNATURE {
+		t.Errorf("Expected the bench signature %d, got %d", BENCH_SIGNATURE, bench.Nodes)
+	}
+}
+
+// Move generation again with the PEXT tables, to compare with BenchmarkGenerateMoves
+func BenchmarkGenerateMovesPext(b *testing.B) {
+	boards := benchBoards(b)
+	if err := SetAttackBackend(ATTACKS_PEXT); err != nil {
+		b.Skip(err)
+	}
+	defer SetAttackBackend(ATTACKS_MAGIC)
+	var moves MoveList
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for _, board := range boards {
+			board.generatePseudoLegalMoves(&moves)
+		}
+	}
+}
@@ -28,7 +28,8 @@ type benchmarkTest struct {
 }
 
 // Called to load run the benchmark
-func RunBenchmark() {
+// Returns the results of the run, to be saved and compared against other builds
+func RunBenchmark() *TestRun {
 	fmt.Println("Starting the benchmark test.")
 
 	// Init the engine
@@ -91,6 +92,11 @@ func RunBenchmark() {
 	totalPoints := 0
 	foundBestMove := 0
 	foundACandidate := 0
+	run := &TestRun{
+		Suite:     SUITE_BENCHMARK,
+		Timestamp: time.Now(),
+		Positions: make([]PositionResult, 0, len(tests)),
+	}
 
 	// Run the tests
 	for _, test := range tests {
@@ -111,7 +117,7 @@ func RunBenchmark() {
 		aggSearchTime := int64(0)
 		nodes := 0
 		bestEval := Eval(0)
-		bestMove := Move{}
+		bestMove := NO_MOVE
 
 		// search
 		timeStart := time.Now()
@@ -134,6 +140,7 @@ func RunBenchmark() {
 
 		// Get points
 		points := 0
+		isBest := false
 		bestMovePCN := bestMove.toPCN()
 		for _, candidate := range test.candidates {
 			if candidate.move == bestMovePCN {
@@ -141,6 +148,7 @@ func RunBenchmark() {
 				foundACandidate++
 				if points == 10 {
 					foundBestMove++
+					isBest = true
 				}
 			}
 		}
@@ -158,6 +166,18 @@ func RunBenchmark() {
 		totalNodes += nodes
 		totalSearchTime += int(aggSearchTime)
 		totalPoints += points
+
+		// Record the position result
+		run.Positions = append(run.Positions, PositionResult{
+			ID:     test.id,
+			Theme:  themeFromID(test.id),
+			Move:   bestMovePCN,
+			Eval:   bestEval,
+			Points: points,
+			Best:   isBest,
+			Nodes:  nodes,
+			TimeMs: aggSearchTime,
+		})
 	}
 
 	// Print final results
@@ -176,6 +196,7 @@ func RunBenchmark() {
 	fmt.Printf("Average Search Time: %d\n", avgTotalSearchTime)
 	fmt.Printf("Average Mn/s: %.2f\n\n", mnps)
 
+	return run
 }
 
 // Called to load a single test line
@@ -1,8 +1,10 @@
 package engine
 
 import (
+	"cmp"
 	"fmt"
 	"math/bits"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -81,6 +83,9 @@ func (position FEN) toBoard(history []FEN) (*Board, error) {
 	// Setup the Zobrist hash
 	board.Zobrist = board.toZobrist()
 
+	// Setup the incremental evaluation
+	board.initEvalState()
+
 	// Setup the board history if needed
 	if history != nil {
 		board.History = make([]ZobristHash, 0, STARTING_HISTORY_LENGTH)
@@ -89,6 +94,102 @@ func (position FEN) toBoard(history []FEN) (*Board, error) {
 	return &board, nil
 }
 
+// A piece on a square, used to setup boards without a FEN
+type placedPiece struct {
+	color Color
+	piece Piece
+	sq    Square
+}
+
+// Setup a board from its pieces, with no castling rights and no en passant square
+// This is much faster than parsing a FEN, for generating tables of positions
+func newBoardFromPieces(turn Color, pieces []placedPiece) *Board {
+	b := &Board{Turn: turn, EPS: NO_SQUARE, FMC: 1}
+	for i := range NUM_SQUARES {
+		b.MailBox[i] = NO_PIECE
+	}
+	for _, p := range pieces {
+		position := p.sq.bitBoardPosition()
+		b.Pieces[p.color][p.piece] |= position
+		b.Occupancy[p.color] |= position
+		b.Occupancy[EITHER_COLOR] |= position
+		b.MailBox[p.sq] = p.piece
+		if p.piece == KING {
+			b.KingSquare[p.color] = p.sq
+		}
+	}
+	b.Zobrist = b.toZobrist()
+	b.initEvalState()
+	return b
+}
+
+// Convert a board back into a FEN string
+func (b *Board) toFEN() FEN {
+	var sb strings.Builder
+
+	// Pieces, from the top left of the board (a8) down to the bottom right (h1)
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := range 8 {
+			sq := Square(rank*8 + file)
+			piece := b.getPieceAt(sq)
+			if piece == NO_PIECE {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			color := WHITE
+			if b.Occupancy[BLACK]&sq.bitBoardPosition() != 0 {
+				color = BLACK
+			}
+			sb.WriteString(piece.toString(color))
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			sb.WriteString("/")
+		}
+	}
+
+	// Turn
+	if b.Turn == WHITE {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	// Castling rights
+	castling := ""
+	for _, right := range []struct {
+		flag uint8
+		char string
+	}{{CASTLE_WK, "K"}, {CASTLE_WQ, "Q"}, {CASTLE_BK, "k"}, {CASTLE_BQ, "q"}} {
+		if b.CR&right.flag != 0 {
+			castling += right.char
+		}
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	// En passent square
+	if b.EPS == NO_SQUARE {
+		sb.WriteString(" -")
+	} else {
+		sb.WriteString(" " + b.EPS.toString())
+	}
+
+	// Clocks
+	sb.WriteString(fmt.Sprintf(" %d %d", b.HMC, b.FMC))
+
+	return FEN(sb.String())
+}
+
 // Return the Zobrist hash of a board
 func (b *Board) toZobrist() ZobristHash {
 	var hash ZobristHash
@@ -144,6 +245,10 @@ func (b *Board) setPieces(pieces string) error {
 			if c >= '1' && c <= '8' {
 				idx += Square(c - '0')
 			} else {
+				// A piece past the end of the row would be put off the board
+				if idx >= holdIdx+8 {
+					return fmt.Errorf("Invalid FEN string; String moved past 8 columns in a row")
+				}
 				position := idx.bitBoardPosition()
 				switch c {
 				case CHAR_BK:
@@ -239,82 +344,90 @@ type BoardSearchResults struct {
 	MoveEvals []MoveEval
 }
 
-func (b *Board) search(numberOfMoves int) BoardSearchResults {
-	return BoardSearchResults{}
+func (b *Board) search(numberOfMoves int, options SearchOptions) BoardSearchResults {
+	result := b.iterativeSearch(options)
+	if len(result.moves) == 0 {
+		return BoardSearchResults{Nodes: int32(result.nodes)}
+	}
+
+	// The move to play goes first, then the rest from best to worst
+	// At full strength the move to play is the best move, but a skill level can play another one
+	played := pickRootMove(result, options)
+	moveEvals := slices.Clone(result.moves)
+	slices.SortStableFunc(moveEvals, func(a, b MoveEval) int {
+		if (a.move == played) != (b.move == played) {
+			if a.move == played {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.eval, a.eval)
+	})
+
+	return BoardSearchResults{
+		Nodes:     int32(result.nodes),
+		MoveEvals: moveEvals[:min(max(numberOfMoves, 1), len(moveEvals))],
+	}
 }
 
 // This function generates all legal moves in a position
 // DO NOT USE THIS IN THE SEARCH OR ENGINE HOTPATH
 // This should only be used for giving the frontend the legal moves in a position
 func (b *Board) generateLegalMoves() []Move {
-	moves := make([]Move, MAX_NUMBER_OF_MOVES_IN_A_POSITION)
-	legalMoves := make([]Move, MAX_NUMBER_OF_MOVES_IN_A_POSITION)
-	numberOfMoves := b.generatePseudoLegalMoves(moves)
+	var list MoveList
+	legalMoves := make([]Move, 0, MAX_NUMBER_OF_MOVES_IN_A_POSITION)
+	b.generatePseudoLegalMoves(&list)
 
-	for i := range moves[:numberOfMoves] {
-		if b.isMoveLegal(moves[i]) {
-			legalMoves = append(legalMoves, moves[i])
+	for _, move := range list.slice() {
+		if b.isMoveLegal(move) {
+			legalMoves = append(legalMoves, move)
 		}
 	}
 
 	return legalMoves
 }
 
-// This function generates all pseduo legal moves in a position and fills out a pre-allocated move array
+// This function generates all pseduo legal moves in a position and fills out a pre-allocated move list
 // This is desirable as the engine will check the legality of the move in the search itself
 // This could avoid calling isMoveLegal for 30+ moves if we hit an AB-cutoff early, which is a big optimization
-func (b *Board) generatePseudoLegalMoves(moves []Move) int {
-	// Keep track of where we are in the moves array
-	moveIdx := 0
+// Returns the number of moves generated
+func (b *Board) generatePseudoLegalMoves(list *MoveList) int {
+	list.count = 0
 
 	// Generate pseudo-legal pawn moves
-	moveIdx = b.getPawnMoves(moves, moveIdx)
+	b.getPawnMoves(list)
 
 	// Generate pseudo-legal knight moves
-	moveIdx = b.getKnightMoves(moves, moveIdx)
+	b.getKnightMoves(list)
 
 	// Generate pseudo-legal king moves
-	moveIdx = b.getKingMoves(moves, moveIdx)
+	b.getKingMoves(list)
 
 	// Generate pseudo-legal bishop moves
-	moveIdx = b.getBishopMoves(moves, moveIdx)
+	b.getBishopMoves(list)
 
 	// Generate pseudo-legal rook moves
-	moveIdx = b.getRookMoves(moves, moveIdx)
+	b.getRookMoves(list)
 
 	// Generate pseudo-legal queen moves
-	moveIdx = b.getQueenMoves(moves, moveIdx)
+	b.getQueenMoves(list)
 
 	// Generate pseudo-legal castling moves
-	moveIdx = b.getCastlingMoves(moves, moveIdx)
+	b.getCastlingMoves(list)
 
-	return moveIdx
+	return list.count
 }
 
-func (b *Board) generatePseudoLegalMovesWithOrdering(moves []Move, ttEntry *TTEntry, killers *[2]Move, twoPlyKillers *[2]Move, cutoffHistory *CutoffHeuristic) int {
-	moveIdx := b.generatePseudoLegalMoves(moves)
+func (b *Board) generatePseudoLegalMovesWithOrdering(list *MoveList, ttEntry *TTEntry, killers *[2]Move, twoPlyKillers *[2]Move, cutoffHistory *CutoffHeuristic) int {
+	b.generatePseudoLegalMoves(list)
 
-	// Precompute scores
-	var scores [MAX_NUMBER_OF_MOVES_IN_A_POSITION]int
-	for i := range moveIdx {
-		scores[i] = moves[i].orderScore(b, ttEntry, killers, twoPlyKillers, cutoffHistory)
+	// Score the moves, then sort them by their scores
+	for i, move := range list.slice() {
+		list.scores[i] = int32(move.orderScore(b, ttEntry, killers, twoPlyKillers, cutoffHistory))
 	}
+	list.sort()
 
-	// Insertion sort descending using stack array
-	for i := 1; i < moveIdx; i++ {
-		move := moves[i]
-		score := scores[i]
-		j := i - 1
-		for j >= 0 && scores[j] < score {
-			moves[j+1] = moves[j]
-			scores[j+1] = scores[j]
-			j--
-		}
-		moves[j+1] = move
-		scores[j+1] = score
-	}
-
-	return moveIdx
+	return list.count
 }
 
 // This function unmakes a move, in-place, on a board
@@ -447,6 +560,13 @@ func (b *Board) unMakeMove(unmove MoveUndo) {
 
 	// Update either color occupancy
 	b.Occupancy[EITHER_COLOR] = (b.Occupancy[WHITE] | b.Occupancy[BLACK])
+
+	// Restore the incremental evaluation
+	b.EvalState = unmove.evalState
+
+	if DEBUG_DESYNC {
+		b.desync("unMakeMove")
+	}
 }
 
 // This function makes a move, in-place, on a board, and returns if that move was legal or not
@@ -459,6 +579,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 		eps:         b.EPS,
 		captured:    NO_PIECE,
 		isPromotion: false,
+		evalState:   b.EvalState,
 	}
 
 	// Add this boards Zobrist hash to the history and update clocks
@@ -469,12 +590,12 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 	}
 
 	// Decode the move and board start
-	start := move.start
-	target := move.target
+	start := move.start()
+	target := move.target()
 	startBitBoard := start.bitBoardPosition()
 	targetBitBoard := target.bitBoardPosition()
-	promotion := move.promotion
-	code := move.code
+	promotion := move.promotion()
+	code := move.code()
 	color := b.Turn
 	oppColor := color ^ 1
 	eps := b.EPS
@@ -509,6 +630,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 
 		// Hash out enempy piece
 		b.Zobrist ^= PIECE_ZOBRIST[oppColor][targetPiece][target]
+		b.EvalState.removePiece(b.evalParams(), oppColor, targetPiece, target)
 	}
 
 	// Handle the moving piece bitboards
@@ -520,6 +642,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 	// Hash out old piece and in new piece
 	b.Zobrist ^= PIECE_ZOBRIST[color][startPiece][start]
 	b.Zobrist ^= PIECE_ZOBRIST[color][startPiece][target]
+	b.EvalState.movePiece(b.evalParams(), color, startPiece, start, target)
 
 	// Handle the piece mailbox
 	b.MailBox[start] = NO_PIECE
@@ -554,6 +677,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 
 			// Hash out captured enemy pawn
 			b.Zobrist ^= PIECE_ZOBRIST[oppColor][PAWN][eps]
+			b.EvalState.removePiece(b.evalParams(), oppColor, PAWN, eps)
 
 			// Add to unmake struct
 			unmake.captured = PAWN
@@ -568,6 +692,8 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 			// Hash out pawn and in promoted piece
 			b.Zobrist ^= PIECE_ZOBRIST[color][PAWN][target]
 			b.Zobrist ^= PIECE_ZOBRIST[color][promotion][target]
+			b.EvalState.removePiece(b.evalParams(), color, PAWN, target)
+			b.EvalState.addPiece(b.evalParams(), color, promotion, target)
 
 			// Store it for unmake later
 			unmake.isPromotion = true
@@ -598,6 +724,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 			// Update Zobrist hash for the rook
 			b.Zobrist ^= PIECE_ZOBRIST[WHITE][ROOK][H1]
 			b.Zobrist ^= PIECE_ZOBRIST[WHITE][ROOK][F1]
+			b.EvalState.movePiece(b.evalParams(), WHITE, ROOK, H1, F1)
 
 			// Clear white castling rights
 			b.CR &= ^uint8(CASTLE_WK)
@@ -618,6 +745,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 			// Update Zobrist hash for the rook
 			b.Zobrist ^= PIECE_ZOBRIST[WHITE][ROOK][A1]
 			b.Zobrist ^= PIECE_ZOBRIST[WHITE][ROOK][D1]
+			b.EvalState.movePiece(b.evalParams(), WHITE, ROOK, A1, D1)
 
 			// Clear white castling rights
 			b.CR &= ^uint8(CASTLE_WK)
@@ -638,6 +766,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 			// Update Zobrist hash for the rook
 			b.Zobrist ^= PIECE_ZOBRIST[BLACK][ROOK][H8]
 			b.Zobrist ^= PIECE_ZOBRIST[BLACK][ROOK][F8]
+			b.EvalState.movePiece(b.evalParams(), BLACK, ROOK, H8, F8)
 
 			// Clear black castling rights
 			b.CR &= ^uint8(CASTLE_BK)
@@ -658,6 +787,7 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 			// Update Zobrist hash for the rook
 			b.Zobrist ^= PIECE_ZOBRIST[BLACK][ROOK][A8]
 			b.Zobrist ^= PIECE_ZOBRIST[BLACK][ROOK][D8]
+			b.EvalState.movePiece(b.evalParams(), BLACK, ROOK, A8, D8)
 
 			// Clear black castling rights
 			b.CR &= ^uint8(CASTLE_BK)
@@ -714,6 +844,10 @@ func (b *Board) makeMove(move Move) (MoveUndo, bool) {
 	// Hash in turn
 	b.Zobrist ^= BLACK_TO_MOVE_ZOBRIST
 
+	if DEBUG_DESYNC {
+		b.desync("makeMove")
+	}
+
 	// Verify the board start is legal
 	// Make sure the king is not attacked
 	if b.isSquareAttacked(b.KingSquare[color], b.Turn) {
@@ -730,6 +864,16 @@ func (b *Board) isMoveLegal(move Move) bool {
 	return isLegal
 }
 
+// Find the legal move matching a string of pure coordinate notation (PCN), like e2e4 or a7a8q
+func (b *Board) moveFromPCN(pcn string) (Move, error) {
+	for _, move := range b.generateLegalMoves() {
+		if move.toPCN() == pcn {
+			return move, nil
+		}
+	}
+	return NO_MOVE, fmt.Errorf("Invalid move; %v is not a legal move in the position", pcn)
+}
+
 // This function returns the piece at a specific square
 func (b *Board) getPieceAt(sq Square) Piece {
 	return b.MailBox[sq]
@@ -751,7 +895,11 @@ func (b *Board) print() {
 	}
 }
 
+// When set, every makeMove and unMakeMove checks the board with desync, this is slow and only for debugging
+var DEBUG_DESYNC = false
+
 // Useful for debugging move and unmove
+// Checks the mailbox against the bitboards, and the incremental evaluation against a full recompute
 func (b *Board) desync(where string) {
 	for sq := range NUM_SQUARES {
 		sqBB := Square(sq).bitBoardPosition()
@@ -823,6 +971,105 @@ func (b *Board) desync(where string) {
 			}
 		}
 	}
+
+	// Check the incremental evaluation against a full recompute
+	if state := b.computeEvalState(); state != b.EvalState {
+		b.print()
+		fmt.Printf("DESYNC (%s) -> Incremental eval: %+v, Recomputed eval: %+v\n", where, b.EvalState, state)
+		panic("Board desync: Eval Mismatch")
+	}
+}
+
+// Check that the parts of the board agree with each other, and that it is a position the engine can play from
+// Unlike desync this returns what is wrong instead of panicking, for tests and checking positions from outside
+func (b *Board) checkConsistency() error {
+	// The piece bitboards can not overlap, and make up the occupancy
+	var occupancy [NUM_COLORS]BitBoard
+	for color := range NUM_COLORS {
+		for piece := range NUM_PIECES {
+			if occupancy[WHITE]&b.Pieces[color][piece] != 0 || occupancy[BLACK]&b.Pieces[color][piece] != 0 {
+				return fmt.Errorf("Pieces overlap at %v %v", Piece(piece).toString(Color(color)), b.Pieces[color][piece])
+			}
+			occupancy[color] |= b.Pieces[color][piece]
+		}
+		if occupancy[color] != b.Occupancy[color] {
+			return fmt.Errorf("Occupancy of color %d is %v, the pieces are on %v", color, b.Occupancy[color], occupancy[color])
+		}
+	}
+	if b.Occupancy[EITHER_COLOR] != occupancy[WHITE]|occupancy[BLACK] {
+		return fmt.Errorf("Occupancy of either color is %v, the pieces are on %v", b.Occupancy[EITHER_COLOR], occupancy[WHITE]|occupancy[BLACK])
+	}
+
+	// The mailbox has the pieces of the bitboards
+	for sq := range Square(NUM_SQUARES) {
+		piece := NO_PIECE
+		for color := range NUM_COLORS {
+			for p := range NUM_PIECES {
+				if b.Pieces[color][p]&sq.bitBoardPosition() != 0 {
+					piece = Piece(p)
+				}
+			}
+		}
+		if b.MailBox[sq] != piece {
+			return fmt.Errorf("Mailbox has %v at %v, the bitboards have %v", b.MailBox[sq], sq.toString(), piece)
+		}
+	}
+
+	// Each side has one king, on its king square, and no pawns are on the first or last rank
+	for color := range NUM_COLORS {
+		if bits.OnesCount64(uint64(b.Pieces[color][KING])) != 1 {
+			return fmt.Errorf("Color %d has %d kings; Should have 1", color, bits.OnesCount64(uint64(b.Pieces[color][KING])))
+		}
+		if b.Pieces[color][KING] != b.KingSquare[color].bitBoardPosition() {
+			return fmt.Errorf("King square of color %d is %v, the king is on %v", color, b.KingSquare[color].toString(), b.Pieces[color][KING])
+		}
+		if b.Pieces[color][PAWN]&BitBoard(0xFF000000000000FF) != 0 {
+			return fmt.Errorf("Color %d has pawns on the first or last rank", color)
+		}
+	}
+
+	// Castling rights need the king and rook on their starting squares
+	for _, right := range []struct {
+		flag  uint8
+		color Color
+		king  Square
+		rook  Square
+	}{
+		{flag: CASTLE_WK, color: WHITE, king: E1, rook: H1},
+		{flag: CASTLE_WQ, color: WHITE, king: E1, rook: A1},
+		{flag: CASTLE_BK, color: BLACK, king: E8, rook: H8},
+		{flag: CASTLE_BQ, color: BLACK, king: E8, rook: A8},
+	} {
+		if b.CR&right.flag != 0 && (b.KingSquare[right.color] != right.king || b.Pieces[right.color][ROOK]&right.rook.bitBoardPosition() == 0) {
+			return fmt.Errorf("Castling rights %04b without the king and rook on %v and %v", right.flag, right.king.toString(), right.rook.toString())
+		}
+	}
+
+	// The en passant square is behind a pawn that was just double pushed
+	if b.EPS != NO_SQUARE {
+		pawn, behind, rank := b.EPS-8, b.EPS+8, BitBoard(0x0000FF0000000000)
+		if b.Turn == BLACK {
+			pawn, behind, rank = b.EPS+8, b.EPS-8, BitBoard(0x0000000000FF0000)
+		}
+		if b.EPS >= NUM_SQUARES || rank&b.EPS.bitBoardPosition() == 0 || b.Pieces[b.Turn^1][PAWN]&pawn.bitBoardPosition() == 0 ||
+			b.Occupancy[EITHER_COLOR]&(b.EPS.bitBoardPosition()|behind.bitBoardPosition()) != 0 {
+			return fmt.Errorf("En passant square %v is not behind a double pushed pawn", b.EPS.toString())
+		}
+	}
+
+	// The side that just moved can not be in check
+	if b.isSquareAttacked(b.KingSquare[b.Turn^1], b.Turn) {
+		return fmt.Errorf("The side not to move is in check")
+	}
+
+	// The incrementally updated hash and evaluation match a full recompute
+	if zobrist := b.toZobrist(); zobrist != b.Zobrist {
+		return fmt.Errorf("Zobrist hash is %x, recomputed it is %x", b.Zobrist, zobrist)
+	}
+	if state := b.computeEvalState(); state != b.EvalState {
+		return fmt.Errorf("Incremental eval is %+v, recomputed it is %+v", b.EvalState, state)
+	}
+	return nil
 }
 
 // Function used to get the phase score of the position
@@ -830,58 +1077,47 @@ func (b *Board) desync(where string) {
 // This returns the phase offset to be used against the PST tables
 // Function used by the board to get the pst value
 func (b *Board) getPhaseScore() int {
-	// Weights for calculating game phase (Non-Pawn Material is standard)
-	const (
-		PawnPhase   = 0
-		KnightPhase = 1
-		BishopPhase = 1
-		RookPhase   = 2
-		QueenPhase  = 4
-	)
-
-	// Calculate the maximum possible phase (Starting Position)
-	// 16 Pawns, 4 Knights, 4 Bishops, 4 Rooks, 2 Queens
-	const TotalPhase = PawnPhase*16 + KnightPhase*4 + BishopPhase*4 + RookPhase*4 + QueenPhase*2
-
-	// Start with TotalPhase and subtract the pieces currently on the board.
-	// If board is full -> phase = TotalPhase - TotalPhase = 0 (Opening)
-	// If board is empty -> phase = TotalPhase - 0 = TotalPhase (Endgame)
-	phase := TotalPhase
-
-	// Count White Pieces
-	wp := bits.OnesCount64(uint64(b.Pieces[WHITE][PAWN]))
-	wn := bits.OnesCount64(uint64(b.Pieces[WHITE][KNIGHT]))
-	wb := bits.OnesCount64(uint64(b.Pieces[WHITE][BISHOP]))
-	wr := bits.OnesCount64(uint64(b.Pieces[WHITE][ROOK]))
-	wq := bits.OnesCount64(uint64(b.Pieces[WHITE][QUEEN]))
-
-	// Count Black Pieces
-	bp := bits.OnesCount64(uint64(b.Pieces[BLACK][PAWN]))
-	bn := bits.OnesCount64(uint64(b.Pieces[BLACK][KNIGHT]))
-	bb := bits.OnesCount64(uint64(b.Pieces[BLACK][BISHOP]))
-	br := bits.OnesCount64(uint64(b.Pieces[BLACK][ROOK]))
-	bq := bits.OnesCount64(uint64(b.Pieces[BLACK][QUEEN]))
-
-	// Subtract material currently on board
-	phase -= wp * PawnPhase
-	phase -= bp * PawnPhase
-	phase -= wn * KnightPhase
-	phase -= bn * KnightPhase
-	phase -= wb * BishopPhase
-	phase -= bb * BishopPhase
-	phase -= wr * RookPhase
-	phase -= br * RookPhase
-	phase -= wq * QueenPhase
-	phase -= bq * QueenPhase
+	// Start with TOTAL_PHASE and subtract the pieces currently on the board (kept in the eval state)
+	// If board is full -> phase = TOTAL_PHASE - TOTAL_PHASE = 0 (Opening)
+	// If board is empty -> phase = TOTAL_PHASE - 0 = TOTAL_PHASE (Endgame)
+	phase := TOTAL_PHASE - b.EvalState.Phase
 
 	// Normalize to range [0, 256]
 	// 0   = Opening
 	// 256 = Endgame
-	phase = (phase*256 + (TotalPhase / 2)) / TotalPhase
+	phase = (phase*256 + (TOTAL_PHASE / 2)) / TOTAL_PHASE
 
 	return phase
 }
 
+// Function to check if neither side has enough material left to checkmate
+// This is only the dead positions every arbiter agrees on: K v K, K+minor v K and K+B v K+B with same coloured bishops
+func (b *Board) isInsufficientMaterial() bool {
+	// Any pawn, rook or queen can still mate
+	for color := range NUM_COLORS {
+		if b.Pieces[color][PAWN]|b.Pieces[color][ROOK]|b.Pieces[color][QUEEN] != 0 {
+			return false
+		}
+	}
+
+	whiteMinors := bits.OnesCount64(uint64(b.Pieces[WHITE][KNIGHT] | b.Pieces[WHITE][BISHOP]))
+	blackMinors := bits.OnesCount64(uint64(b.Pieces[BLACK][KNIGHT] | b.Pieces[BLACK][BISHOP]))
+
+	// King and at most one minor piece against a bare king
+	if whiteMinors+blackMinors <= 1 {
+		return true
+	}
+
+	// One bishop each, on the same coloured squares
+	if whiteMinors == 1 && blackMinors == 1 && b.Pieces[WHITE][KNIGHT]|b.Pieces[BLACK][KNIGHT] == 0 {
+		whiteOnLight := b.Pieces[WHITE][BISHOP]&LIGHT_SQUARES != 0
+		blackOnLight := b.Pieces[BLACK][BISHOP]&LIGHT_SQUARES != 0
+		return whiteOnLight == blackOnLight
+	}
+
+	return false
+}
+
 // Function to check if the board has voliated the 3-fold repitition rule
 func (b *Board) isThreeFold() bool {
 	count := 0
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Positions the fuzzer starts from, with castling, en passant and promotions to play
+var FUZZ_POSITIONS = []FEN{
+	STARTING_POSITION_FEN,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ-- - 1 8",
+	"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	"8/8/8/8/k2Pp2Q/8/8/3K4 b - d3 0 1",
+	"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+}
+
+// Place the kings and a few random pieces, for positions the FENs do not reach
+func randomPosition(rng *rand.Rand) FEN {
+	for {
+		squares := rng.Perm(NUM_SQUARES)
+		pieces := []placedPiece{
+			{color: WHITE, piece: KING, sq: Square(squares[0])},
+			{color: BLACK, piece: KING, sq: Square(squares[1])},
+		}
+		for _, sq := range squares[2 : 2+rng.Intn(14)] {
+			piece := Piece(rng.Intn(int(KING)))
+			if piece == PAWN && (sq < 8 || sq >= 56) {
+				continue
+			}
+			pieces = append(pieces, placedPiece{color: Color(rng.Intn(2)), piece: piece, sq: Square(sq)})
+		}
+
+		board := newBoardFromPieces(Color(rng.Intn(2)), pieces)
+		if board.checkConsistency() == nil {
+			return board.toFEN()
+		}
+	}
+}
+
+func FuzzMakeUnmake(f *testing.F) {
+	InitEngine()
+
+	// An empty position starts from a random one of the seed instead
+	for i, position := range FUZZ_POSITIONS {
+		f.Add(string(position), int64(i), uint8(40))
+	}
+	for seed := range int64(8) {
+		f.Add("", seed, uint8(30))
+	}
+
+	f.Fuzz(func(t *testing.T, position string, seed int64, plies uint8) {
+		rng := rand.New(rand.NewSource(seed))
+		if position == "" {
+			position = string(randomPosition(rng))
+		}
+		board, err := FEN(position).toBoard(nil)
+		if err != nil || board.checkConsistency() != nil {
+			t.Skip()
+		}
+
+		var moves MoveList
+		for range plies {
+			before := *board
+			before.History = nil
+			legalMoves := []Move{}
+
+			// Every move, legal or not, is made and unmade back to the same board
+			board.generatePseudoLegalMoves(&moves)
+			for _, move := range moves.slice() {
+				unmake, isLegal := board.makeMove(move)
+				if isLegal {
+					legalMoves = append(legalMoves, move)
+					if err := board.checkConsistency(); err != nil {
+						t.Fatalf("%v after %v: %v", before.toFEN(), move.toPCN(), err)
+					}
+					checkClocks(t, &before, board, move)
+				}
+				board.unMakeMove(unmake)
+
+				after := *board
+				after.History = nil
+				if !reflect.DeepEqual(after, before) {
+					t.Fatalf("%v: Unmaking %v gave %v", before.toFEN(), move.toPCN(), after.toFEN())
+				}
+			}
+
+			if len(legalMoves) == 0 {
+				return
+			}
+			board.makeMove(legalMoves[rng.Intn(len(legalMoves))])
+		}
+	})
+}
+
+// Check the turn and clocks of a board after a legal move
+func checkClocks(t *testing.T, before, after *Board, move Move) {
+	t.Helper()
+
+	if after.Turn != before.Turn^1 {
+		t.Fatalf("%v after %v: Expected the turn to pass", before.toFEN(), move.toPCN())
+	}
+
+	hmc := before.HMC + 1
+	if before.MailBox[move.start()] == PAWN || move.code() == MOVE_CODE_CAPTURE || move.code() == MOVE_CODE_EN_PASSANT {
+		hmc = 0
+	}
+	fmc := before.FMC
+	if before.Turn == BLACK {
+		fmc++
+	}
+	if after.HMC != hmc || after.FMC != fmc {
+		t.Fatalf("%v after %v: Expected clocks %d %d, got %d %d", before.toFEN(), move.toPCN(), hmc, fmc, after.HMC, after.FMC)
+	}
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+)
+
+/*
+This file holds the builder of opening books from PGN game collections.
+The games are replayed up to a number of plies, and every move played is counted under the Polyglot key of its position,
+scored by the result of the game for the side that played it. Moves that were not played often enough are dropped,
+and the rest are written as a Polyglot .bin book, weighted by their score, which can be played by LoadBook.
+*/
+
+// Options for building a book
+type BookOptions struct {
+	// The PGN files the games are read from
+	PGNPaths []string
+
+	// The file the book is written to
+	OutPath string
+
+	// Only the moves of the first plies of the games are kept
+	MaxPlies int
+
+	// Moves played in fewer games than this are dropped
+	MinGames int
+
+	// Moves played in less than this share of the games of their position are dropped (0 to 1)
+	MinShare float64
+
+	// The score of a move for each result of its game, for the side that played it
+	WinScore  int
+	DrawScore int
+	LossScore int
+}
+
+// Default book options
+var DEFAULT_BOOK_OPTIONS = BookOptions{
+	OutPath:   "book.bin",
+	MaxPlies:  20,
+	MinGames:  2,
+	WinScore:  2,
+	DrawScore: 1,
+}
+
+// What was read and written when building a book
+type BookSummary struct {
+	Games     int
+	Skipped   int
+	Positions int // Positions reached in the games, before filtering
+	Moves     int
+}
+
+// The statistics of a move in a position
+type bookMoveStats struct {
+	games int
+	score int
+}
+
+// Build a book from PGN files and write it out
+func BuildBook(options BookOptions) (BookSummary, error) {
+	// The keys of the book would not be the standard ones, so the book would not be read correctly by other programs
+	if !polyglotRandomValid() {
+		return BookSummary{}, fmt.Errorf("Invalid Polyglot random numbers; The key of the starting position should be %016x", POLYGLOT_START_KEY)
+	}
+	return buildBook(options)
+}
+
+func buildBook(options BookOptions) (BookSummary, error) {
+	var summary BookSummary
+	if len(options.PGNPaths) == 0 {
+		return summary, fmt.Errorf("No PGN files to build the book from")
+	}
+
+	stats := map[uint64]map[uint16]*bookMoveStats{}
+	for _, path := range options.PGNPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			return summary, err
+		}
+
+		err = readPGN(f, func(game pgnGame) error {
+			summary.Games++
+			if !addBookGame(stats, game, options) {
+				summary.Skipped++
+			}
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			return summary, fmt.Errorf("Failed to read %v: %w", path, err)
+		}
+	}
+
+	entries := bookEntries(stats, options)
+	summary.Positions = len(stats)
+	summary.Moves = len(entries)
+	return summary, writeBook(options.OutPath, entries)
+}
+
+// Replay a game into the statistics of the book, returns false if the game was skipped
+// Games without a result are skipped, and games with an illegal move are only counted up to it
+func addBookGame(stats map[uint64]map[uint16]*bookMoveStats, game pgnGame, options BookOptions) bool {
+	result, ok := PGN_RESULTS[game.result]
+	if !ok {
+		return false
+	}
+
+	start := STARTING_POSITION_FEN
+	if fen, ok := game.tags["FEN"]; ok {
+		start = FEN(fen)
+	}
+	board, err := start.toBoard(nil)
+	if err != nil {
+		return false
+	}
+
+	for ply, san := range game.moves {
+		if ply >= options.MaxPlies {
+			break
+		}
+		move, err := board.moveFromSAN(san)
+		if err != nil {
+			return ply > 0
+		}
+
+		// Score the move for the side that played it
+		score := options.DrawScore
+		switch {
+		case result == RESULT_WHITE_WINS && board.Turn == WHITE, result == RESULT_BLACK_WINS && board.Turn == BLACK:
+			score = options.WinScore
+		case result != RESULT_DRAW:
+			score = options.LossScore
+		}
+
+		key := board.polyglotKey()
+		if stats[key] == nil {
+			stats[key] = map[uint16]*bookMoveStats{}
+		}
+		polyglotMove := move.toPolyglot()
+		if stats[key][polyglotMove] == nil {
+			stats[key][polyglotMove] = &bookMoveStats{}
+		}
+		stats[key][polyglotMove].games++
+		stats[key][polyglotMove].score += score
+
+		board.makeMove(move)
+	}
+	return true
+}
+
+// Filter the statistics into entries, sorted by key and then by weight
+// The scores are scaled down to fit the 16 bit weights if needed
+func bookEntries(stats map[uint64]map[uint16]*bookMoveStats, options BookOptions) []BookEntry {
+	maxScore := 0
+	for _, moves := range stats {
+		for _, move := range moves {
+			maxScore = max(maxScore, move.score)
+		}
+	}
+
+	var entries []BookEntry
+	for key, moves := range stats {
+		games := 0
+		for _, move := range moves {
+			games += move.games
+		}
+
+		for polyglotMove, move := range moves {
+			if move.games < options.MinGames || float64(move.games) < options.MinShare*float64(games) || move.score <= 0 {
+				continue
+			}
+
+			weight := move.score
+			if maxScore > 0xFFFF {
+				weight = max(weight*0xFFFF/maxScore, 1)
+			}
+			entries = append(entries, BookEntry{Key: key, Move: polyglotMove, Weight: uint16(weight)})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b BookEntry) int {
+		if c := cmp.Compare(a.Key, b.Key); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Weight, a.Weight); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Move, b.Move)
+	})
+	return entries
+}
+
+// Write the entries of a book in the Polyglot format
+func writeBook(path string, entries []BookEntry) error {
+	data := make([]byte, 0, len(entries)*POLYGLOT_ENTRY_SIZE)
+	for _, entry := range entries {
+		data = binary.BigEndian.AppendUint64(data, entry.Key)
+		data = binary.BigEndian.AppendUint16(data, entry.Move)
+		data = binary.BigEndian.AppendUint16(data, entry.Weight)
+		data = binary.BigEndian.AppendUint32(data, entry.Learn)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Convert a move into a Polyglot move, castling is written as the king taking its own rook
+func (m Move) toPolyglot() uint16 {
+	target := m.target()
+	if m.code() == MOVE_CODE_CASTLE {
+		if target > m.start() {
+			target = m.start() + 3
+		} else {
+			target = m.start() - 4
+		}
+	}
+
+	promotion := 0
+	switch m.promotion() {
+	case KNIGHT:
+		promotion = 1
+	case BISHOP:
+		promotion = 2
+	case ROOK:
+		promotion = 3
+	case QUEEN:
+		promotion = 4
+	}
+
+	return uint16(promotion)<<12 | uint16(m.start())<<6 | uint16(target)
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildBook(t *testing.T) {
+	InitEngine()
+
+	dir := t.TempDir()
+	pgns := map[string]string{
+		"a.pgn": `[Result "1-0"]
+1. e4 e5 2. Nf3 1-0
+[Result "0-1"]
+1. e4 c5 0-1
+[Result "1/2-1/2"]
+1. d4 d5 1/2-1/2
+[Result "*"]
+1. e4 e5 *`,
+		"b.pgn": `1. e4 e5 2. Nf3 Nc6 1/2-1/2
+1. Nf3 1-0
+1. e4 Qh4 1-0`,
+	}
+	var paths []string
+	for name, pgn := range pgns {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(pgn), 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	// Tests setup to be run, with the expected book moves and weights after playing the moves from the starting position
+	tests := []struct {
+		name     string
+		maxPlies int
+		minGames int
+		minShare float64
+		moves    string
+		expected map[string]int
+	}{
+		{name: "start", maxPlies: 20, minGames: 2, expected: map[string]int{"e2e4": 5}},
+		{name: "after e4", maxPlies: 20, minGames: 2, moves: "e2e4", expected: map[string]int{"e7e5": 1}},
+		{name: "after e5", maxPlies: 20, minGames: 2, moves: "e2e4 e7e5", expected: map[string]int{"g1f3": 3}},
+		{name: "once", maxPlies: 20, minGames: 1, expected: map[string]int{"e2e4": 5, "d2d4": 1, "g1f3": 2}},
+		{name: "share", maxPlies: 20, minGames: 1, minShare: 0.2, expected: map[string]int{"e2e4": 5}},
+		{name: "max plies", maxPlies: 1, minGames: 1, moves: "e2e4", expected: map[string]int{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			options := DEFAULT_BOOK_OPTIONS
+			options.PGNPaths = paths
+			options.OutPath = filepath.Join(dir, "book.bin")
+			options.MaxPlies = tc.maxPlies
+			options.MinGames = tc.minGames
+			options.MinShare = tc.minShare
+
+			summary, err := BuildBook(options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if summary.Games != 7 || summary.Skipped != 1 {
+				t.Errorf("Expected 7 games with 1 skipped, got %+v", summary)
+			}
+
+			book, err := LoadBook(options.OutPath)
+			if err != nil {
+				t.Fatalf("Failed to load the book: %v", err)
+			}
+			board, err := STARTING_POSITION_FEN.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Failed to build the starting position: %v", err)
+			}
+			for pcn := range strings.FieldsSeq(tc.moves) {
+				move, err := board.moveFromPCN(pcn)
+				if err != nil {
+					t.Fatalf("%v: %v", pcn, err)
+				}
+				board.makeMove(move)
+			}
+
+			got := map[string]int{}
+			for _, move := range board.bookMoves(book) {
+				got[move.move.toPCN()] = move.weight
+			}
+			if !maps.Equal(got, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// Scores too large for the weights are scaled down
+	entries := bookEntries(map[uint64]map[uint16]*bookMoveStats{
+		1: {1: {games: 5, score: 200000}, 2: {games: 5, score: 100000}, 3: {games: 5, score: 1}},
+	}, DEFAULT_BOOK_OPTIONS)
+	if len(entries) != 3 || entries[0].Weight != 0xFFFF || entries[1].Weight != 0x7FFF || entries[2].Weight != 1 {
+		t.Errorf("Expected weights of 65535, 32767 and 1, got %v", entries)
+	}
+
+	// There has to be a game to build from
+	if _, err := BuildBook(BookOptions{OutPath: filepath.Join(dir, "empty.bin")}); err == nil {
+		t.Errorf("Expected an error building a book without PGN files")
+	}
+
+	// Books are only built with the standard keys, so other programs can read them
+	POLYGLOT_RANDOM[POLYGLOT_RANDOM_SIZE-1]++
+	defer func() { POLYGLOT_RANDOM[POLYGLOT_RANDOM_SIZE-1]-- }()
+	if _, err := BuildBook(BookOptions{PGNPaths: paths, OutPath: filepath.Join(dir, "nonstandard.bin")}); err == nil {
+		t.Errorf("Expected an error building a book without the standard Polyglot random numbers")
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import "math"
+
+/*
+This file holds the statistics used to judge the results of self-play matches.
+Elo is estimated from the score of the match, and the SPRT decides when enough games were played
+to accept or reject a change. The SPRT uses the normal approximation of the trinomial (win/draw/loss)
+model, the same approximation used by most engine testing frameworks.
+*/
+
+// Convert an expected score (0 to 1) into an Elo difference
+func scoreToElo(score float64) float64 {
+	// Clamp, otherwise a perfect score is an infinite Elo difference
+	score = min(max(score, 0.001), 0.999)
+	return -400 * math.Log10(1/score-1)
+}
+
+// Convert an Elo difference into an expected score (0 to 1)
+func eloToScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// The mean score and the per game variance of the score of a match
+func matchScore(wins, draws, losses int) (float64, float64) {
+	games := float64(wins + draws + losses)
+	if games == 0 {
+		return 0.5, 0
+	}
+
+	score := (float64(wins) + float64(draws)/2) / games
+	variance := (float64(wins)*math.Pow(1-score, 2) +
+		float64(draws)*math.Pow(0.5-score, 2) +
+		float64(losses)*math.Pow(score, 2)) / games
+	return score, variance
+}
+
+// Estimate the Elo difference of a match, with the 95% confidence interval (as +/- error)
+func EstimateElo(wins, draws, losses int) (elo float64, errorMargin float64) {
+	score, variance := matchScore(wins, draws, losses)
+	elo = scoreToElo(score)
+
+	games := float64(wins + draws + losses)
+	if games == 0 {
+		return elo, 0
+	}
+
+	// 1.96 standard deviations either side of the mean score covers 95%
+	stdErr := math.Sqrt(variance / games)
+	upper := scoreToElo(score + 1.96*stdErr)
+	lower := scoreToElo(score - 1.96*stdErr)
+	return elo, (upper - lower) / 2
+}
+
+// Options for the sequential probability ratio test
+// H0 is that the change is worth Elo0, H1 is that the change is worth Elo1
+// Alpha is the chance of accepting H1 when H0 is true, Beta is the chance of accepting H0 when H1 is true
+type SPRTOptions struct {
+	Elo0  float64
+	Elo1  float64
+	Alpha float64
+	Beta  float64
+}
+
+// The default SPRT bounds, good for testing small search and eval tweaks
+var DEFAULT_SPRT_OPTIONS = SPRTOptions{
+	Elo0:  0,
+	Elo1:  10,
+	Alpha: 0.05,
+	Beta:  0.05,
+}
+
+// SPRT decisions
+const (
+	SPRT_CONTINUE = "continue"
+	SPRT_ACCEPT   = "accept H1"
+	SPRT_REJECT   = "accept H0"
+)
+
+// The log-likelihood ratio of the match results, and the lower and upper bounds to stop the test at
+func (o SPRTOptions) llr(wins, draws, losses int) (llr, lower, upper float64) {
+	lower = math.Log(o.Beta / (1 - o.Alpha))
+	upper = math.Log((1 - o.Beta) / o.Alpha)
+
+	score, variance := matchScore(wins, draws, losses)
+
+	// Not enough information yet (all games had the same result)
+	if variance == 0 {
+		return 0, lower, upper
+	}
+
+	games := float64(wins + draws + losses)
+	s0 := eloToScore(o.Elo0)
+	s1 := eloToScore(o.Elo1)
+	llr = games * (s1 - s0) * (2*score - s0 - s1) / (2 * variance)
+	return llr, lower, upper
+}
+
+// Decide if the test should stop, and which hypothesis was accepted
+func (o SPRTOptions) decide(wins, draws, losses int) string {
+	llr, lower, upper := o.llr(wins, draws, losses)
+	if llr >= upper {
+		return SPRT_ACCEPT
+	}
+	if llr <= lower {
+		return SPRT_REJECT
+	}
+	return SPRT_CONTINUE
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreToElo(t *testing.T) {
+	// Tests setup to be run
+	tests := []struct {
+		name  string
+		score float64
+		elo   float64
+	}{
+		{
+			name:  "Even score is 0 Elo",
+			score: 0.5,
+			elo:   0,
+		},
+		{
+			name:  "76% score is about 200 Elo",
+			score: 0.76,
+			elo:   200.2,
+		},
+		{
+			name:  "24% score is about -200 Elo",
+			score: 0.24,
+			elo:   -200.2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			elo := scoreToElo(tc.score)
+			if math.Abs(elo-tc.elo) > 0.5 {
+				t.Errorf("scoreToElo(%v) = %.2f, expected %.2f", tc.score, elo, tc.elo)
+			}
+
+			// Converting back should give the same score
+			if score := eloToScore(elo); math.Abs(score-tc.score) > 0.0001 {
+				t.Errorf("eloToScore(%.2f) = %v, expected %v", elo, score, tc.score)
+			}
+		})
+	}
+}
+
+func TestEstimateEloErrorShrinks(t *testing.T) {
+	// The same score over more games should give the same Elo with a smaller error
+	smallElo, smallError := EstimateElo(30, 40, 30)
+	largeElo, largeError := EstimateElo(300, 400, 300)
+
+	if math.Abs(smallElo) > 0.001 || math.Abs(largeElo) > 0.001 {
+		t.Errorf("Expected an even match to be 0 Elo, got %.2f and %.2f", smallElo, largeElo)
+	}
+	if largeError >= smallError {
+		t.Errorf("Expected the error to shrink with more games, got %.2f then %.2f", smallError, largeError)
+	}
+}
+
+func TestSPRTDecide(t *testing.T) {
+	// Tests setup to be run
+	tests := []struct {
+		name     string
+		wins     int
+		draws    int
+		losses   int
+		decision string
+	}{
+		{
+			name:     "Too few games to decide",
+			wins:     6,
+			draws:    10,
+			losses:   4,
+			decision: SPRT_CONTINUE,
+		},
+		{
+			name:     "Clear gain accepts H1",
+			wins:     600,
+			draws:    800,
+			losses:   400,
+			decision: SPRT_ACCEPT,
+		},
+		{
+			name:     "Clear loss accepts H0",
+			wins:     400,
+			draws:    800,
+			losses:   600,
+			decision: SPRT_REJECT,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := DEFAULT_SPRT_OPTIONS.decide(tc.wins, tc.draws, tc.losses)
+			if decision != tc.decision {
+				llr, lower, upper := DEFAULT_SPRT_OPTIONS.llr(tc.wins, tc.draws, tc.losses)
+				t.Errorf("Expected %v, got %v (LLR %.2f, bounds %.2f %.2f)", tc.decision, decision, llr, lower, upper)
+			}
+		})
+	}
+}
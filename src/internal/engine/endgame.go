@@ -0,0 +1,267 @@
+package engine
+
+import "math/bits"
+
+/*
+This file holds the knowledge of specific endgames.
+Known endgames, found by their material signature, are evaluated by their own evaluators instead of the general terms:
+the mating nets of KXK and KBNK drive the lone king to the edge or the right corner, so won positions are converted,
+and KPK is looked up in the bitbase.
+Other endgames keep the general evaluation but are scaled towards a draw when the side ahead can rarely win,
+like opposite coloured bishops, rook pawns with the wrong bishop, or no pawns left to win with.
+*/
+
+// The evaluation of a won endgame, high enough to be above any normal evaluation but below the mate scores
+const KNOWN_WIN = Eval(10000)
+
+// The scale of the evaluation when no endgame knowledge applies, the scale is out of this
+const SCALE_NORMAL = 64
+
+// The piece counts of both sides packed into a key, 4 bits for each piece (other than the king) of each color
+type materialSignature uint64
+
+// An evaluator of a known endgame, from the perspective of the strong side
+// It returns false when it has no better evaluation than the general one
+type endgameEvaluator struct {
+	name string
+	eval func(b *Board, strong Color) (Eval, bool)
+}
+
+// The known endgames and the side of them that is strong, by material signature
+var ENDGAME_EVALUATORS map[materialSignature]endgameEntry
+
+type endgameEntry struct {
+	evaluator *endgameEvaluator
+	strong    Color
+}
+
+// The mating net against a lone king, for the endgames with too many signatures to list
+var KXK_EVALUATOR = &endgameEvaluator{"KXK", evalKXK}
+
+// Light squares, used for the colour of bishops
+const LIGHT_SQUARES = BitBoard(0x55AA55AA55AA55AA)
+
+// Init the endgame evaluators, called at engine startup
+func initEndgames() {
+	ENDGAME_EVALUATORS = make(map[materialSignature]endgameEntry)
+
+	add := func(code string, eval func(b *Board, strong Color) (Eval, bool)) {
+		evaluator := &endgameEvaluator{code, eval}
+		ENDGAME_EVALUATORS[signatureOf(code, WHITE)] = endgameEntry{evaluator, WHITE}
+		ENDGAME_EVALUATORS[signatureOf(code, BLACK)] = endgameEntry{evaluator, BLACK}
+	}
+	add("KBNK", evalKBNK)
+	add("KPK", evalKPK)
+}
+
+// Get the material signature of the board
+func (b *Board) materialSignature() materialSignature {
+	var signature materialSignature
+	for color := range NUM_COLORS {
+		for piece := PAWN; piece < KING; piece++ {
+			count := min(bits.OnesCount64(uint64(b.Pieces[color][piece])), 15)
+			signature |= materialSignature(count) << (4 * (int(color)*int(KING) + int(piece)))
+		}
+	}
+	return signature
+}
+
+// Get the material signature of an endgame code like "KBNK", where the first king's side is the given color
+func signatureOf(code string, strong Color) materialSignature {
+	var signature materialSignature
+	color := int(strong) ^ 1
+	for _, c := range code {
+		if c == 'K' {
+			color ^= 1
+			continue
+		}
+		piece := map[rune]Piece{'P': PAWN, 'N': KNIGHT, 'B': BISHOP, 'R': ROOK, 'Q': QUEEN}[c]
+		signature += materialSignature(1) << (4 * (color*int(KING) + int(piece)))
+	}
+	return signature
+}
+
+// Get the evaluator of the endgame on the board and the strong side, nil when it is not a known endgame
+func (b *Board) endgameEvaluator() (*endgameEvaluator, Color) {
+	// Positions with many pieces are never listed endgames, and are most of the positions evaluated
+	if bits.OnesCount64(uint64(b.Occupancy[EITHER_COLOR])) <= 6 {
+		if entry, ok := ENDGAME_EVALUATORS[b.materialSignature()]; ok {
+			return entry.evaluator, entry.strong
+		}
+	}
+
+	// A lone king against mating material is a mating net however many pieces are left
+	for color := WHITE; color <= BLACK; color++ {
+		if b.Occupancy[color^1] == b.Pieces[color^1][KING] && b.canForceMate(color) {
+			return KXK_EVALUATOR, color
+		}
+	}
+	return nil, WHITE
+}
+
+// Evaluate the board with the evaluator of a known endgame, from white's perspective
+// Returns false when the board is not a known endgame, or the evaluator has nothing better than the general evaluation
+func (b *Board) endgameEval() (Eval, bool) {
+	evaluator, strong := b.endgameEvaluator()
+	if evaluator == nil {
+		return 0, false
+	}
+	eval, ok := evaluator.eval(b, strong)
+	if !ok {
+		return 0, false
+	}
+	if strong == BLACK {
+		eval = -eval
+	}
+	return eval, true
+}
+
+// Check if a side has the pieces to force mate against a lone king without any help from pawns
+func (b *Board) canForceMate(color Color) bool {
+	if b.Pieces[color][QUEEN]|b.Pieces[color][ROOK] != 0 {
+		return true
+	}
+	bishops := b.Pieces[color][BISHOP]
+	if bishops&LIGHT_SQUARES != 0 && bishops&^LIGHT_SQUARES != 0 {
+		return true
+	}
+	return bishops != 0 && b.Pieces[color][KNIGHT] != 0
+}
+
+// The distance between two squares in king moves
+func squareDistance(a, b Square) int {
+	fileDistance := int(a)%8 - int(b)%8
+	rankDistance := int(a)/8 - int(b)/8
+	return max(fileDistance, -fileDistance, rankDistance, -rankDistance)
+}
+
+// Bonus for the lone king being near the edge, 0 in the centre and 120 in the corners
+func pushToEdge(sq Square) Eval {
+	file := int(sq) % 8
+	rank := int(sq) / 8
+	return Eval(20 * ((3 - min(file, 7-file)) + (3 - min(rank, 7-rank))))
+}
+
+// Bonus for the kings being close together, as the strong king is needed for the mate
+func pushClose(a, b Square) Eval {
+	return Eval(20 * (7 - squareDistance(a, b)))
+}
+
+// A lone king against enough material to mate, drive the king to the edge and bring the strong king close
+func evalKXK(b *Board, strong Color) (Eval, bool) {
+	weak := strong ^ 1
+	strongKing := b.KingSquare[strong]
+	weakKing := b.KingSquare[weak]
+
+	eval := KNOWN_WIN + b.EvalState.Material[strong] + pushToEdge(weakKing) + pushClose(strongKing, weakKing)
+	return eval, true
+}
+
+// King, bishop and knight against a king, the mate is only possible in a corner of the bishop's colour
+func evalKBNK(b *Board, strong Color) (Eval, bool) {
+	weak := strong ^ 1
+	strongKing := b.KingSquare[strong]
+	weakKing := b.KingSquare[weak]
+
+	// The corners the bishop covers, a1 and h8 are dark
+	corners := [2]Square{0, 63}
+	if b.Pieces[strong][BISHOP]&LIGHT_SQUARES != 0 {
+		corners = [2]Square{7, 56}
+	}
+	cornerDistance := 14
+	for _, corner := range corners {
+		fileDistance := int(weakKing)%8 - int(corner)%8
+		rankDistance := int(weakKing)/8 - int(corner)/8
+		cornerDistance = min(cornerDistance, max(fileDistance, -fileDistance)+max(rankDistance, -rankDistance))
+	}
+
+	eval := KNOWN_WIN + b.EvalState.Material[strong] + pushClose(strongKing, weakKing)
+	eval += pushToEdge(weakKing)/2 + Eval(15*(14-cornerDistance))
+	return eval, true
+}
+
+// King and pawn against a king, solved exactly by the bitbase
+// A won position is better the further the pawn has advanced and the closer the king is in front of it, so the search makes progress
+func evalKPK(b *Board, strong Color) (Eval, bool) {
+	if !b.probeKPK() {
+		return 0, true
+	}
+
+	pawns := b.Pieces[strong][PAWN]
+	pawn := pawns.popSquare()
+	rank, stop := int(pawn)/8, pawn+8
+	if strong == BLACK {
+		rank, stop = 7-rank, pawn-8
+	}
+	return KNOWN_WIN + PAWN_VALUE*Eval(rank) + Eval(10*(7-squareDistance(b.KingSquare[strong], stop))), true
+}
+
+// Get the scale of the evaluation for the side it favours, out of SCALE_NORMAL
+// Endgames where the side ahead can rarely win are scaled towards a draw
+func (b *Board) endgameScale(eval Eval) int {
+	strong := WHITE
+	if eval < 0 {
+		strong = BLACK
+	}
+	weak := strong ^ 1
+
+	strongPawns := b.Pieces[strong][PAWN]
+	strongPieces := b.EvalState.Material[strong] - Eval(bits.OnesCount64(uint64(strongPawns)))*PAWN_VALUE
+	weakPieces := b.EvalState.Material[weak] - Eval(bits.OnesCount64(uint64(b.Pieces[weak][PAWN])))*PAWN_VALUE
+
+	// Without pawns a side needs more than a minor piece extra to win
+	if strongPawns == 0 {
+		if strongPieces-weakPieces <= BISHOP_VALUE {
+			switch {
+			case strongPieces < ROOK_VALUE:
+				return 0
+			case weakPieces <= BISHOP_VALUE:
+				return 4
+			default:
+				return 14
+			}
+		}
+
+		// Two knights can not force mate
+		if b.Pieces[strong][KNIGHT] == b.Occupancy[strong]&^b.Pieces[strong][KING] && strongPieces <= 2*KNIGHT_VALUE {
+			return 0
+		}
+	}
+
+	// Pawns all on one rook file, with only a bishop that does not cover the promotion square (or no pieces at all),
+	// are a draw once the lone king reaches the corner
+	for _, file := range []int{0, 7} {
+		if strongPawns == 0 || strongPawns&^FileMask[file] != 0 {
+			continue
+		}
+		bishops := b.Pieces[strong][BISHOP]
+		if strongPieces != 0 && (strongPieces != BISHOP_VALUE || bishops == 0) {
+			continue
+		}
+		promotion := Square(56 + file)
+		if strong == BLACK {
+			promotion = Square(file)
+		}
+		wrongBishop := bishops == 0 || (bishops&LIGHT_SQUARES != 0) != (promotion.bitBoardPosition()&LIGHT_SQUARES != 0)
+		if wrongBishop && squareDistance(b.KingSquare[weak], promotion) <= 1 {
+			return 0
+		}
+	}
+
+	// Opposite coloured bishops, very drawish when they are the only pieces left
+	whiteBishops, blackBishops := b.Pieces[WHITE][BISHOP], b.Pieces[BLACK][BISHOP]
+	if bits.OnesCount64(uint64(whiteBishops)) == 1 && bits.OnesCount64(uint64(blackBishops)) == 1 &&
+		(whiteBishops&LIGHT_SQUARES != 0) != (blackBishops&LIGHT_SQUARES != 0) {
+		if strongPieces == BISHOP_VALUE && weakPieces == BISHOP_VALUE {
+			return 22
+		}
+		return 46
+	}
+
+	return SCALE_NORMAL
+}
+
+// Scale an evaluation, out of SCALE_NORMAL
+func scaleEval(eval Eval, scale int) Eval {
+	return Eval(int(eval) * scale / SCALE_NORMAL)
+}
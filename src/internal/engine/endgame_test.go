@@ -0,0 +1,113 @@
+package engine
+
+import "testing"
+
+func TestEndgameEval(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected endgame evaluator (empty for none) and scale
+	tests := []struct {
+		name     string
+		position FEN
+		endgame  string
+		scale    int
+	}{
+		{
+			name:     "King and queen against king",
+			position: "8/8/8/3k4/8/8/8/3QK3 w - - 0 1",
+			endgame:  "KXK",
+		},
+		{
+			name:     "Black rook against king",
+			position: "8/8/8/3k4/8/8/8/r3K3 b - - 0 1",
+			endgame:  "KXK",
+		},
+		{
+			name:     "King, bishop and knight against king",
+			position: "8/8/8/3k4/8/8/8/1NB1K3 w - - 0 1",
+			endgame:  "KBNK",
+		},
+		{
+			name:     "Unstoppable pawn",
+			position: "8/8/8/8/8/7k/P7/4K3 w - - 0 1",
+			endgame:  "KPK",
+		},
+		{
+			name:     "Two knights can not win",
+			position: "8/8/8/3k4/8/8/8/1N2K1N1 w - - 0 1",
+			scale:    0,
+		},
+		{
+			name:     "Rook against bishop",
+			position: "8/8/8/3k4/8/3b4/8/R3K3 w - - 0 1",
+			scale:    4,
+		},
+		{
+			name:     "Rook pawn with the wrong bishop",
+			position: "k7/8/8/8/8/8/P7/2B1K3 w - - 0 1",
+			scale:    0,
+		},
+		{
+			name:     "Rook pawn with the right bishop",
+			position: "k7/8/8/8/8/8/P7/3BK3 w - - 0 1",
+			scale:    SCALE_NORMAL,
+		},
+		{
+			name:     "Opposite coloured bishops",
+			position: "8/5k2/8/3b1p2/5P2/4PK2/8/2B5 w - - 0 1",
+			scale:    22,
+		},
+		{
+			name:     "Opposite coloured bishops with rooks",
+			position: "3r4/5k2/8/3b1p2/5P2/4PK2/8/2B1R3 w - - 0 1",
+			scale:    46,
+		},
+		{
+			name:     "Starting position",
+			position: STARTING_POSITION_FEN,
+			scale:    SCALE_NORMAL,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+
+			eval, ok := board.endgameEval()
+			if tc.endgame != "" {
+				evaluator, strong := board.endgameEvaluator()
+				if !ok || evaluator.name != tc.endgame {
+					t.Fatalf("Expected the %v evaluator", tc.endgame)
+				}
+				if strong == BLACK {
+					eval = -eval
+				}
+				if eval < KNOWN_WIN {
+					t.Errorf("Expected a known win, got %d", eval)
+				}
+				return
+			}
+
+			if ok {
+				t.Fatalf("Expected no endgame evaluator, got %d", eval)
+			}
+			if scale := board.endgameScale(board.evaluateTerms(nil)); scale != tc.scale {
+				t.Errorf("Expected scale %d, got %d", tc.scale, scale)
+			}
+		})
+	}
+}
+
+func TestKBNKDrivesToTheRightCorner(t *testing.T) {
+	InitEngine()
+
+	// The dark squared bishop mates in a1 or h8, so the lone king is better off in a8
+	right, _ := FEN("8/8/8/8/8/2K5/8/k1BN4 w - - 0 1").toBoard(nil)
+	wrong, _ := FEN("k7/8/2K5/8/8/8/8/2BN4 w - - 0 1").toBoard(nil)
+	if right.eval() <= wrong.eval() {
+		t.Errorf("Expected the king in the bishop's corner to be worse, got %d and %d", right.eval(), wrong.eval())
+	}
+}
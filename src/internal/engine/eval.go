@@ -16,17 +16,126 @@ const (
 	KING_VALUE   Eval = 20000
 )
 
+// These are used for move ordering and pruning, the evaluation uses the material weights in EvalParams
 var PIECE_VALUES [NUM_PIECES]Eval
 
+// Weights of each piece when calculating the game phase (Non-Pawn Material is standard)
+var PHASE_WEIGHTS = [NUM_PIECES]int{PAWN: 0, KNIGHT: 1, BISHOP: 1, ROOK: 2, QUEEN: 4, KING: 0}
+
+// The maximum possible phase (Starting Position)
+// 16 Pawns, 4 Knights, 4 Bishops, 4 Rooks, 2 Queens
+const TOTAL_PHASE = 4*1 + 4*1 + 4*2 + 2*4
+
+// The parts of the evaluation that are updated incrementally as moves are made
+type EvalState struct {
+	// Material and piece square tables, white minus black, for the opening and the endgame
+	PST Weight
+
+	// Sum of the phase weights of the pieces on the board
+	Phase int
+
+	// Material of each side, using PIECE_VALUES without the king
+	Material [NUM_COLORS]Eval
+
+	// Zobrist hash of just the pawns, the key of the pawn hash
+	PawnKey ZobristHash
+}
+
+// Add a piece to the eval state
+func (s *EvalState) addPiece(params *EvalParams, color Color, piece Piece, sq Square) {
+	w := params.pst[color][piece][sq]
+	if color == WHITE {
+		s.PST.Opening += w.Opening
+		s.PST.Endgame += w.Endgame
+	} else {
+		s.PST.Opening -= w.Opening
+		s.PST.Endgame -= w.Endgame
+	}
+	s.Phase += PHASE_WEIGHTS[piece]
+	if piece != KING {
+		s.Material[color] += PIECE_VALUES[piece]
+	}
+	if piece == PAWN {
+		s.PawnKey ^= PIECE_ZOBRIST[color][PAWN][sq]
+	}
+}
+
+// Remove a piece from the eval state
+func (s *EvalState) removePiece(params *EvalParams, color Color, piece Piece, sq Square) {
+	w := params.pst[color][piece][sq]
+	if color == WHITE {
+		s.PST.Opening -= w.Opening
+		s.PST.Endgame -= w.Endgame
+	} else {
+		s.PST.Opening += w.Opening
+		s.PST.Endgame += w.Endgame
+	}
+	s.Phase -= PHASE_WEIGHTS[piece]
+	if piece != KING {
+		s.Material[color] -= PIECE_VALUES[piece]
+	}
+	if piece == PAWN {
+		s.PawnKey ^= PIECE_ZOBRIST[color][PAWN][sq]
+	}
+}
+
+// Move a piece in the eval state, only the piece square tables (and the pawn key) change
+func (s *EvalState) movePiece(params *EvalParams, color Color, piece Piece, start, target Square) {
+	if piece == PAWN {
+		s.PawnKey ^= PIECE_ZOBRIST[color][PAWN][start] ^ PIECE_ZOBRIST[color][PAWN][target]
+	}
+
+	from := params.pst[color][piece][start]
+	to := params.pst[color][piece][target]
+	if color == WHITE {
+		s.PST.Opening += to.Opening - from.Opening
+		s.PST.Endgame += to.Endgame - from.Endgame
+	} else {
+		s.PST.Opening -= to.Opening - from.Opening
+		s.PST.Endgame -= to.Endgame - from.Endgame
+	}
+}
+
+// Compute the eval state of the board from scratch
+func (b *Board) computeEvalState() EvalState {
+	var state EvalState
+	params := b.evalParams()
+	for color := range NUM_COLORS {
+		for piece := PAWN; piece <= KING; piece++ {
+			bitboard := b.Pieces[color][piece]
+			for bitboard != 0 {
+				state.addPiece(params, Color(color), piece, bitboard.popSquare())
+			}
+		}
+	}
+	return state
+}
+
+// Get the evaluation parameters of the board, the engine's unless a search set its own
+func (b *Board) evalParams() *EvalParams {
+	if b.params != nil {
+		return b.params
+	}
+	return &EVAL_PARAMS
+}
+
+// Rebuild the eval state of the board, needed when the board is setup or the evaluation parameters change
+func (b *Board) initEvalState() {
+	b.EvalState = b.computeEvalState()
+}
+
 // File masks used for fast evaluation
 var FileMask [8]BitBoard
 
+// Masks of the files next to each file, used for isolated and backward pawns
+var AdjacentFileMask [8]BitBoard
+
 // Passed pawn masks for fast evaluation
 var WhitePassedMask [64]BitBoard
 var BlackPassedMask [64]BitBoard
 
-// King safety pask for fast evaluation
-var KingSafetyMask [64]BitBoard
+// The squares around each king that enemy attacks are counted on, by color and square
+var KingZone [NUM_COLORS][64]BitBoard
 
 // Init evaluation, called at engine startup
 func initEval() {
@@ -37,6 +146,9 @@ func initEval() {
 	PIECE_VALUES[QUEEN] = QUEEN_VALUE
 	PIECE_VALUES[KING] = KING_VALUE
 
+	// Setup the evaluation parameters
+	initEvalParams()
+
 	// Init file mask
 	for file := range 8 {
 		for rank := range 8 {
@@ -44,6 +156,15 @@ func initEval() {
 			FileMask[file] |= 1 << sq
 		}
 	}
+	for file := range 8 {
+		AdjacentFileMask[file] = 0
+		if file > 0 {
+			AdjacentFileMask[file] |= FileMask[file-1]
+		}
+		if file < 7 {
+			AdjacentFileMask[file] |= FileMask[file+1]
+		}
+	}
 
 	// Init passed pawn mask
 	for sq := range NUM_SQUARES {
@@ -62,29 +183,39 @@ func initEval() {
 		}
 	}
 
-	// Init king safety mask
+	// Init the king zones, the squares next to the king and the rank in front of those
 	for sq := range NUM_SQUARES {
-		f := sq % 8
-		r := sq / 8
+		around := KING_MOVES[sq] | Square(sq).bitBoardPosition()
+		KingZone[WHITE][sq] = around | (around << 8)
+		KingZone[BLACK][sq] = around | (around >> 8)
+	}
+}
 
-		for df := -2; df <= 2; df++ {
-			for dr := -2; dr <= 2; dr++ {
-				// Skip the square the king is actually standing on
-				if df == 0 && dr == 0 {
-					continue
-				}
+// Trace of an evaluation, recording how many times each weight was applied for each side
+// This is only built when tuning or explaining the evaluation, it is nil in the search
+type evalTrace map[*Weight]*[NUM_COLORS]int
 
-				tf, tr := f+df, r+dr
-				if tf >= 0 && tf < 8 && tr >= 0 && tr < 8 {
-					KingSafetyMask[sq] |= (BitBoard(1) << (tr*8 + tf))
-				}
-			}
-		}
+// Record a weight being applied count times for a side
+func (t evalTrace) add(w *Weight, color Color, count int) {
+	if count == 0 {
+		return
+	}
+	counts, ok := t[w]
+	if !ok {
+		counts = &[NUM_COLORS]int{}
+		t[w] = counts
 	}
+	counts[color] += count
 }
 
-// Piece square table evaluation of the position
-func (b *Board) pstEval(phaseSocre int) Eval {
+// Piece square table evaluation of the position (including material)
+func (b *Board) pstEval(phaseSocre int, trace evalTrace) Eval {
+	// The sums are kept in the eval state, they only need to be recomputed when tracing
+	if trace == nil {
+		return b.EvalState.PST.interpolate(phaseSocre)
+	}
+
+	params := b.evalParams()
 	openingEval := Eval(0)
 	endgameEval := Eval(0)
 
@@ -93,16 +224,30 @@ func (b *Board) pstEval(phaseSocre int) Eval {
 		whiteBitboard := b.Pieces[WHITE][p]
 		for whiteBitboard != 0 {
 			sq := whiteBitboard.popSquare()
-			openingEval += PST[OPENING][WHITE][p][sq]
-			endgameEval += PST[ENDGAME][WHITE][p][sq]
+			openingEval += params.pst[WHITE][p][sq].Opening
+			endgameEval += params.pst[WHITE][p][sq].Endgame
+			if trace != nil {
+				trace.add(&params.Material[p], WHITE, 1)
+				trace.add(&params.PST[p][sq], WHITE, 1)
+				if params.sacrificePercent[WHITE] != 0 {
+					trace.add(&params.sacrifice[WHITE][p], WHITE, 1)
+				}
+			}
 		}
 
 		// Subtract Black Pieces
 		blackBitboard := b.Pieces[BLACK][p]
 		for blackBitboard != 0 {
 			sq := blackBitboard.popSquare()
-			openingEval -= PST[OPENING][BLACK][p][sq]
-			endgameEval -= PST[ENDGAME][BLACK][p][sq]
+			openingEval -= params.pst[BLACK][p][sq].Opening
+			endgameEval -= params.pst[BLACK][p][sq].Endgame
+			if trace != nil {
+				trace.add(&params.Material[p], BLACK, 1)
+				trace.add(&params.PST[p][sq^56], BLACK, 1)
+				if params.sacrificePercent[BLACK] != 0 {
+					trace.add(&params.sacrifice[BLACK][p], BLACK, 1)
+				}
+			}
 		}
 	}
 
@@ -115,257 +260,117 @@ func interpolatePhase(phaseScore int, opneing, endgame Eval) Eval {
 	return Eval(((int(opneing) * (256 - phaseScore)) + (int(endgame) * phaseScore)) / 256)
 }
 
-// Function to get the evalution based on the pawn structure of the board
-func (b *Board) pawnStructureEval(phaseScore int) Eval {
-	eval := Eval(0)
-
-	// Get pawn bitboards
-	whitePawns := b.Pieces[WHITE][PAWN]
-	blackPawns := b.Pieces[BLACK][PAWN]
-
-	// Doubled pawns
-	// Each doubled pawn will be worth a 15 centipawn penalty to 36 point penalty
-	// The penalty increases as the game moves towards the engame
-	doubledPenalty := interpolatePhase(phaseScore, 15, 36)
-	doubledWhite := doubledPawns(whitePawns)
-	doubledBlack := doubledPawns(blackPawns)
-	eval -= Eval(bits.OnesCount64(uint64(doubledWhite))) * doubledPenalty
-	eval += Eval(bits.OnesCount64(uint64(doubledBlack))) * doubledPenalty
-
-	// Isolated pawns
-	// Each isolated pawn will be worth a 19 centipawn penalty to 31 point penalty
-	// The penalty increases as the game moves towards the engame
-	isolatedPenalty := interpolatePhase(phaseScore, 19, 31)
-	isolatedWhite := isolatedPawns(whitePawns)
-	isolatedBlack := isolatedPawns(blackPawns)
-	eval -= Eval(bits.OnesCount64(uint64(isolatedWhite))) * isolatedPenalty
-	eval += Eval(bits.OnesCount64(uint64(isolatedBlack))) * isolatedPenalty
-
-	// Passed pawns
-	// Each passed pawn will be worth a 15 centipawn bonus to 38 point bonus
-	// Potentially in the future make this row dependent scores
-	passedBonus := interpolatePhase(phaseScore, 15, 38)
-	passedWhite := passedPawnsWhite(whitePawns, blackPawns)
-	passedBlack := passedPawnsBlack(blackPawns, whitePawns)
-	eval += Eval(passedWhite) * passedBonus
-	eval -= Eval(passedBlack) * passedBonus
-
-	return eval
-}
-
-// Get white passed pawns
-func passedPawnsWhite(whitePawns, blackPawns BitBoard) int {
-	count := 0
-	for whitePawns > 0 {
-		sq := whitePawns.popSquare()
-		if WhitePassedMask[sq]&blackPawns == 0 {
-			count++
-		}
-	}
-
-	return count
-}
-
-// Get black passed pawns
-func passedPawnsBlack(blackPawns, whitePawns BitBoard) int {
-	count := 0
-	for blackPawns > 0 {
-		sq := blackPawns.popSquare()
-		if BlackPassedMask[sq]&whitePawns == 0 {
-			count++
-		}
-	}
-
-	return count
-}
-
-// Function to get doubled pawns
-func doubledPawns(pawns BitBoard) int {
-	doubled := 0
-
-	for file := range 8 {
-		onFile := pawns & FileMask[file]
-		if bits.OnesCount64(uint64(onFile)) >= 2 {
-			doubled++
-		}
+// Apply a weight count times for white and count times for black, returning the difference
+func applyWeight(w *Weight, phaseScore int, whiteCount, blackCount int, trace evalTrace) Eval {
+	if trace != nil {
+		trace.add(w, WHITE, whiteCount)
+		trace.add(w, BLACK, blackCount)
 	}
-	return doubled
+	return Eval(whiteCount-blackCount) * w.interpolate(phaseScore)
 }
 
-// Function to get isolated pawns
-func isolatedPawns(pawns BitBoard) BitBoard {
-	isolated := BitBoard(0)
+// Function to evaluate open file control (rooks/queens/kings on open files)
+func (b *Board) fileBasedEval(phaseScore int, trace evalTrace) Eval {
+	params := b.evalParams()
 
+	// Count the pieces of each side on open, own semi-open and opponent semi-open files
+	var rookOpen, rookOwnSemi, rookOppSemi [NUM_COLORS]int
+	var queenOpen, queenOwnSemi, queenOppSemi [NUM_COLORS]int
+	var kingOpen, kingOwnSemi, kingOppSemi [NUM_COLORS]int
 	for file := range 8 {
-		onFile := pawns & FileMask[file]
-
-		// Adjacent files
-		var neighbors BitBoard
-		if file > 0 {
-			neighbors |= pawns & FileMask[file-1]
-		}
-		if file < 7 {
-			neighbors |= pawns & FileMask[file+1]
+		mask := FileMask[file]
+		for color := range NUM_COLORS {
+			hasOwnPawn := (b.Pieces[color][PAWN] & mask) != 0
+			hasOppPawn := (b.Pieces[color^1][PAWN] & mask) != 0
+			rooks := bits.OnesCount64(uint64(b.Pieces[color][ROOK] & mask))
+			queens := bits.OnesCount64(uint64(b.Pieces[color][QUEEN] & mask))
+			kings := bits.OnesCount64(uint64(b.Pieces[color][KING] & mask))
+
+			switch {
+			case !hasOwnPawn && !hasOppPawn:
+				rookOpen[color] += rooks
+				queenOpen[color] += queens
+				kingOpen[color] += kings
+			case !hasOwnPawn:
+				rookOwnSemi[color] += rooks
+				queenOwnSemi[color] += queens
+				kingOwnSemi[color] += kings
+			case !hasOppPawn:
+				rookOppSemi[color] += rooks
+				queenOppSemi[color] += queens
+				kingOppSemi[color] += kings
+			}
 		}
-
-		// Isolated pawns on this file
-		isolated |= onFile &^ neighbors
 	}
 
-	return isolated
-}
-
-// Function to evaluate king safety
-func (b *Board) kingSafetyEval(phaseScore int) Eval {
 	eval := Eval(0)
-
-	// Get phase scores
-	friendlyPawnScore := interpolatePhase(phaseScore, 17, 0)
-	enemyPawnScore := interpolatePhase(phaseScore, 13, 0)
-	friendlyPieceScore := interpolatePhase(phaseScore, 7, 0)
-	enemyPieceScore := interpolatePhase(phaseScore, 13, 0)
-
-	// Do white king safety
-	// Get the saftey mask, and check for white pawns/pieces and black pawns/pieces
-	// Evaluate strong for number of pawns in front of king, and harshly for enemy pawns/pieces next to king
-	safetyMask := KingSafetyMask[b.KingSquare[WHITE]]
-	friendlyPawns := b.Pieces[WHITE][PAWN] & safetyMask
-	enemyPawns := b.Pieces[BLACK][PAWN] & safetyMask
-	friendlyPieces := (b.Occupancy[WHITE] &^ friendlyPawns) & safetyMask
-	enemyPieces := (b.Occupancy[BLACK] &^ enemyPawns) & safetyMask
-	eval += Eval(bits.OnesCount64(uint64(friendlyPawns))) * friendlyPawnScore
-	eval += Eval(bits.OnesCount64(uint64(friendlyPieces))) * friendlyPieceScore
-	eval -= Eval(bits.OnesCount64(uint64(enemyPawns))) * enemyPawnScore
-	eval -= Eval(bits.OnesCount64(uint64(enemyPieces))) * enemyPieceScore
-
-	// Do black king safety
-	// Get the saftey mask, and check for black pawns/pieces and white pawns/pieces
-	// Evaluate strong for number of pawns in front of king, and harshly for enemy pawns/pieces next to king
-	safetyMask = KingSafetyMask[b.KingSquare[BLACK]]
-	friendlyPawns = b.Pieces[BLACK][PAWN] & safetyMask
-	enemyPawns = b.Pieces[WHITE][PAWN] & safetyMask
-	friendlyPieces = (b.Occupancy[BLACK] &^ friendlyPawns) & safetyMask
-	enemyPieces = (b.Occupancy[WHITE] &^ enemyPawns) & safetyMask
-	eval -= Eval(bits.OnesCount64(uint64(friendlyPawns))) * friendlyPawnScore
-	eval -= Eval(bits.OnesCount64(uint64(friendlyPieces))) * friendlyPieceScore
-	eval += Eval(bits.OnesCount64(uint64(enemyPawns))) * enemyPawnScore
-	eval += Eval(bits.OnesCount64(uint64(enemyPieces))) * enemyPieceScore
+	eval += applyWeight(&params.RookOpenFile, phaseScore, rookOpen[WHITE], rookOpen[BLACK], trace)
+	eval += applyWeight(&params.RookOwnSemiOpenFile, phaseScore, rookOwnSemi[WHITE], rookOwnSemi[BLACK], trace)
+	eval += applyWeight(&params.RookOppSemiOpenFile, phaseScore, rookOppSemi[WHITE], rookOppSemi[BLACK], trace)
+	eval += applyWeight(&params.QueenOpenFile, phaseScore, queenOpen[WHITE], queenOpen[BLACK], trace)
+	eval += applyWeight(&params.QueenOwnSemiOpenFile, phaseScore, queenOwnSemi[WHITE], queenOwnSemi[BLACK], trace)
+	eval += applyWeight(&params.QueenOppSemiOpenFile, phaseScore, queenOppSemi[WHITE], queenOppSemi[BLACK], trace)
+	eval += applyWeight(&params.KingOpenFile, phaseScore, kingOpen[WHITE], kingOpen[BLACK], trace)
+	eval += applyWeight(&params.KingOwnSemiOpenFile, phaseScore, kingOwnSemi[WHITE], kingOwnSemi[BLACK], trace)
+	eval += applyWeight(&params.KingOppSemiOpenFile, phaseScore, kingOppSemi[WHITE], kingOppSemi[BLACK], trace)
 
 	return eval
 }
 
-// Function to evaluate open file control (rooks/queens/kings on open files)
-func (b *Board) fileBasedEval(phaseScore int) Eval {
-	eval := Eval(0)
-
-	bonusForRookYourSemi := interpolatePhase(phaseScore, 11, 23)
-	bonusForRookOppSemi := interpolatePhase(phaseScore, 7, 16)
-	bonusForRookFull := interpolatePhase(phaseScore, 19, 26)
-	bonusForQueenYourSemi := interpolatePhase(phaseScore, 7, 14)
-	bonusForQueenOppSemi := interpolatePhase(phaseScore, 4, 10)
-	bonusForQueenFull := interpolatePhase(phaseScore, 9, 17)
-	penaltyForKingYourSemi := interpolatePhase(phaseScore, 22, 0)
-	penaltyForKingOppSemi := interpolatePhase(phaseScore, 17, 0)
-	penaltyForKingFull := interpolatePhase(phaseScore, 32, 0)
-
-	for file := range 8 {
-		mask := FileMask[file]
-		hasWhitePawn := (b.Pieces[WHITE][PAWN] & mask) != 0
-		hasBlackPawn := (b.Pieces[BLACK][PAWN] & mask) != 0
-		whiteRooks := Eval(bits.OnesCount64(uint64(b.Pieces[WHITE][ROOK] & mask)))
-		blackRooks := Eval(bits.OnesCount64(uint64(b.Pieces[BLACK][ROOK] & mask)))
-		whiteQueen := Eval(bits.OnesCount64(uint64(b.Pieces[WHITE][QUEEN] & mask)))
-		blackQueen := Eval(bits.OnesCount64(uint64(b.Pieces[BLACK][QUEEN] & mask)))
-		hasWhiteKing := (b.Pieces[WHITE][KING] & mask) != 0
-		hasBlackKing := (b.Pieces[BLACK][KING] & mask) != 0
-
-		fullOpen := !hasWhitePawn && !hasBlackPawn
-		whiteSemiOpen := !hasWhitePawn && hasBlackPawn
-		blackSemiOpen := !hasBlackPawn && hasWhitePawn
-
-		// Full open
-		if fullOpen {
-			eval += whiteRooks * bonusForRookFull
-			eval -= blackRooks * bonusForRookFull
-			eval += whiteQueen * bonusForQueenFull
-			eval -= blackQueen * bonusForQueenFull
-
-			if hasWhiteKing {
-				eval -= penaltyForKingFull
-			}
-			if hasBlackKing {
-				eval += penaltyForKingFull
-			}
-
-		}
-
-		// White semi open
-		if whiteSemiOpen {
-			eval += whiteRooks * bonusForRookYourSemi
-			eval -= blackRooks * bonusForRookOppSemi
-			eval += whiteQueen * bonusForQueenYourSemi
-			eval -= blackQueen * bonusForQueenOppSemi
-
-			if hasWhiteKing {
-				eval -= penaltyForKingYourSemi
-			}
-			if hasBlackKing {
-				eval += penaltyForKingOppSemi
-			}
-
-		}
-
-		// Black semi open
-		if blackSemiOpen {
-			eval += whiteRooks * bonusForRookOppSemi
-			eval -= blackRooks * bonusForRookYourSemi
-			eval += whiteQueen * bonusForQueenOppSemi
-			eval -= blackQueen * bonusForQueenYourSemi
-
-			if hasWhiteKing {
-				eval -= penaltyForKingOppSemi
-			}
-			if hasBlackKing {
-				eval += penaltyForKingYourSemi
-			}
+// Main evaluation function, to be called by the searching algorithm
+// The evaluation is from white's perspective
+func (b *Board) eval() Eval {
+	return b.evaluate(nil)
+}
 
-		}
+// Evaluate the board, recording the weights used into the trace if it is not nil
+// Known endgames have their own evaluators, and drawish endgames are scaled down
+func (b *Board) evaluate(trace evalTrace) Eval {
+	if eval, ok := b.endgameEval(); ok {
+		return eval
 	}
 
-	return eval
+	eval := b.evaluateTerms(trace)
+	return scaleEval(eval, b.endgameScale(eval))
 }
 
-// Main evaluation function, to be called by the searching algorithm
-func (b *Board) eval() Eval {
+// Evaluate the board with the general evaluation terms
+func (b *Board) evaluateTerms(trace evalTrace) Eval {
+	params := b.evalParams()
+
 	// Get the current phase of the board
 	phaseScore := b.getPhaseScore()
 
 	// Simple pst evaluation
-	eval := b.pstEval(phaseScore)
+	eval := b.pstEval(phaseScore, trace)
 
 	// Simple tempo evaluation
 	if b.Turn == WHITE {
-		eval += 10
+		eval += applyWeight(&params.Tempo, phaseScore, 1, 0, trace)
 	} else {
-		eval -= 10
+		eval += applyWeight(&params.Tempo, phaseScore, 0, 1, trace)
 	}
 
 	// Simple bishop pair evaluation
+	whitePair := 0
 	if bits.OnesCount64(uint64(b.Pieces[WHITE][BISHOP])) >= 2 {
-		eval += 30
+		whitePair = 1
 	}
+	blackPair := 0
 	if bits.OnesCount64(uint64(b.Pieces[BLACK][BISHOP])) >= 2 {
-		eval -= 30
+		blackPair = 1
 	}
+	eval += applyWeight(&params.BishopPair, phaseScore, whitePair, blackPair, trace)
 
-	// Do pawn structure eval
-	eval += b.pawnStructureEval(phaseScore)
-
-	// Do king safety
-	eval += b.kingSafetyEval(phaseScore)
+	// Do pawn structure eval, the king shields are used by the king safety in the activity eval
+	pawnEval, shieldUnits := b.pawnEval(phaseScore, trace)
+	eval += pawnEval
 
 	// Do file based eval
-	eval += b.fileBasedEval(phaseScore)
+	eval += b.fileBasedEval(phaseScore, trace)
+
+	// Do mobility, threats, outposts and king safety
+	eval += b.activityEval(phaseScore, shieldUnits, trace)
 
 	return eval
 }
@@ -0,0 +1,58 @@
+package engine
+
+import "testing"
+
+func TestIncrementalEvalMatchesRecompute(t *testing.T) {
+	InitEngine()
+
+	// With DEBUG_DESYNC every make and unmake checks the eval state against a full recompute, and panics on a mismatch
+	DEBUG_DESYNC = true
+	defer func() { DEBUG_DESYNC = false }()
+
+	// Tests setup to be run, covering castling, en passant, promotions and captures
+	tests := []struct {
+		name          string
+		position      FEN
+		depth         uint8
+		expectedNodes int
+	}{
+		{
+			name:          "Kiwipete",
+			position:      "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			depth:         3,
+			expectedNodes: 97862,
+		},
+		{
+			name:          "Promotions",
+			position:      "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+			depth:         3,
+			expectedNodes: 9467,
+		},
+		{
+			name:          "En passant endgame",
+			position:      "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+			depth:         4,
+			expectedNodes: 43238,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+
+			moveStack := make([]MoveList, tc.depth+1)
+			result := board.perftNegamax(tc.depth, moveStack)
+			if result.nodes != tc.expectedNodes {
+				t.Errorf("Expected %d nodes, got %d", tc.expectedNodes, result.nodes)
+			}
+
+			// The board is back to the start, so the eval state should be too
+			if state := board.computeEvalState(); state != board.EvalState {
+				t.Errorf("Eval state %+v does not match the recomputed %+v", board.EvalState, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+This file holds the explanation of the static evaluation.
+The evaluation is traced and the weights it used are grouped into terms, each shown per side and per phase,
+so it is possible to see why the evaluation likes or dislikes a position.
+*/
+
+// A term of the evaluation, with the totals of each side before interpolating
+type EvalTerm struct {
+	Name  string `json:"name"`
+	White Weight `json:"white"`
+	Black Weight `json:"black"`
+
+	// The interpolated value of the term, white minus black
+	Total Eval `json:"total"`
+}
+
+// The evaluation of a position broken into its terms, from white's perspective
+type EvalExplanation struct {
+	Position   FEN        `json:"position"`
+	PhaseScore int        `json:"phaseScore"`
+	Terms      []EvalTerm `json:"terms"`
+
+	// The known endgame the position was evaluated as, empty when the terms were used
+	Endgame string `json:"endgame,omitempty"`
+
+	// The scale of the terms for drawish endgames, out of SCALE_NORMAL
+	Scale int `json:"scale"`
+
+	// The scaled sum of the interpolated terms, and the evaluation itself
+	// These can differ by a few centipawns, as the evaluation rounds some terms before adding them
+	Total Eval `json:"total"`
+	Eval  Eval `json:"eval"`
+}
+
+// The weights that make up a term of the evaluation
+type evalTermWeights struct {
+	name    string
+	weights []*Weight
+}
+
+// The weights that make up each term of the evaluation, in the order they are explained
+func (p *EvalParams) evalTerms() []evalTermWeights {
+	// The weights a personality adds for the side it plays are explained with the terms they change
+	material := make([]*Weight, 0, 3*NUM_PIECES)
+	for piece := range NUM_PIECES {
+		material = append(material, &p.Material[piece], &p.sacrifice[WHITE][piece], &p.sacrifice[BLACK][piece])
+	}
+	pst := make([]*Weight, 0, int(NUM_PIECES)*NUM_SQUARES)
+	for piece := range NUM_PIECES {
+		for sq := range NUM_SQUARES {
+			pst = append(pst, &p.PST[piece][sq])
+		}
+	}
+
+	mobility := make([]*Weight, 0, 66)
+	for _, table := range [][]Weight{p.KnightMobility[:], p.BishopMobility[:], p.RookMobility[:], p.QueenMobility[:]} {
+		for i := range table {
+			mobility = append(mobility, &table[i])
+		}
+	}
+	kingSafety := make([]*Weight, 0, 3*KING_SAFETY_UNITS)
+	for units := range KING_SAFETY_UNITS {
+		kingSafety = append(kingSafety, &p.KingSafety[units], &p.kingAttack[WHITE][units], &p.kingAttack[BLACK][units])
+	}
+	passed := []*Weight{&p.PassedPawn}
+	for rank := range 8 {
+		passed = append(passed, &p.PassedPawnRank[rank])
+	}
+
+	return []evalTermWeights{
+		{"Material", material},
+		{"Piece squares", pst},
+		{"Tempo", []*Weight{&p.Tempo}},
+		{"Bishop pair", []*Weight{&p.BishopPair}},
+		{"Doubled pawns", []*Weight{&p.DoubledPawn}},
+		{"Isolated pawns", []*Weight{&p.IsolatedPawn}},
+		{"Backward pawns", []*Weight{&p.BackwardPawn}},
+		{"Connected pawns", []*Weight{&p.ConnectedPawn}},
+		{"Passed pawns", passed},
+		{"Rook files", []*Weight{&p.RookOpenFile, &p.RookOwnSemiOpenFile, &p.RookOppSemiOpenFile}},
+		{"Queen files", []*Weight{&p.QueenOpenFile, &p.QueenOwnSemiOpenFile, &p.QueenOppSemiOpenFile}},
+		{"King files", []*Weight{&p.KingOpenFile, &p.KingOwnSemiOpenFile, &p.KingOppSemiOpenFile}},
+		{"Mobility", mobility},
+		{"Threats", []*Weight{&p.ThreatByPawn, &p.ThreatByMinor, &p.ThreatByRook, &p.HangingPiece}},
+		{"Outposts", []*Weight{&p.KnightOutpost, &p.BishopOutpost}},
+		{"Rook on 7th", []*Weight{&p.RookOnSeventh}},
+		{"King safety", kingSafety},
+	}
+}
+
+// Explain the evaluation of the board
+func (b *Board) explainEval() *EvalExplanation {
+	trace := evalTrace{}
+	terms := b.evaluateTerms(trace)
+
+	explanation := &EvalExplanation{
+		Position:   b.toFEN(),
+		PhaseScore: b.getPhaseScore(),
+		Scale:      b.endgameScale(terms),
+		Eval:       b.evaluate(nil),
+	}
+	if _, ok := b.endgameEval(); ok {
+		evaluator, _ := b.endgameEvaluator()
+		explanation.Endgame = evaluator.name
+	}
+	for _, term := range b.evalParams().evalTerms() {
+		explained := EvalTerm{Name: term.name}
+		for _, w := range term.weights {
+			counts, ok := trace[w]
+			if !ok {
+				continue
+			}
+			explained.White.Opening += Eval(counts[WHITE]) * w.Opening
+			explained.White.Endgame += Eval(counts[WHITE]) * w.Endgame
+			explained.Black.Opening += Eval(counts[BLACK]) * w.Opening
+			explained.Black.Endgame += Eval(counts[BLACK]) * w.Endgame
+		}
+		explained.Total = explained.White.interpolate(explanation.PhaseScore) - explained.Black.interpolate(explanation.PhaseScore)
+
+		explanation.Terms = append(explanation.Terms, explained)
+		explanation.Total += explained.Total
+	}
+	explanation.Total = scaleEval(explanation.Total, explanation.Scale)
+
+	return explanation
+}
+
+// ExplainEval breaks the static evaluation of a position into its terms
+// The engine must be initialized first
+func ExplainEval(position FEN) (*EvalExplanation, error) {
+	board, err := position.toBoard(nil)
+	if err != nil {
+		return nil, err
+	}
+	return board.explainEval(), nil
+}
+
+// Format the explanation as a table
+func (e *EvalExplanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Position: %v\n", e.Position)
+	fmt.Fprintf(&sb, "Phase: %d/256 (0 is the opening, 256 the endgame)\n\n", e.PhaseScore)
+
+	fmt.Fprintf(&sb, "%-16s %16s %16s %8s\n", "Term", "White (mg, eg)", "Black (mg, eg)", "Total")
+	for _, term := range e.Terms {
+		fmt.Fprintf(&sb, "%-16s %16s %16s %8d\n", term.Name,
+			fmt.Sprintf("%d, %d", term.White.Opening, term.White.Endgame),
+			fmt.Sprintf("%d, %d", term.Black.Opening, term.Black.Endgame),
+			term.Total)
+	}
+	if e.Scale != SCALE_NORMAL {
+		fmt.Fprintf(&sb, "%-16s %16s %16s %8s\n", "Endgame scale", "", "", fmt.Sprintf("%d/%d", e.Scale, SCALE_NORMAL))
+	}
+	fmt.Fprintf(&sb, "%-16s %16s %16s %8d\n\n", "Sum of terms", "", "", e.Total)
+	if e.Endgame != "" {
+		fmt.Fprintf(&sb, "Known endgame: %v, the terms are not used\n", e.Endgame)
+	}
+	fmt.Fprintf(&sb, "Eval: %d (white's perspective)\n", e.Eval)
+
+	return sb.String()
+}
@@ -0,0 +1,44 @@
+package engine
+
+import "testing"
+
+func TestExplainEvalSumsToEval(t *testing.T) {
+	InitEngine()
+
+	fens := []FEN{
+		STARTING_POSITION_FEN,
+		"r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 9",
+		"8/5pk1/6p1/3R4/1p6/1r4P1/5PK1/8 b - - 3 41",
+		"8/5k2/8/3b1p2/5P2/4PK2/8/2B5 w - - 0 1",
+	}
+	for _, fen := range fens {
+		explanation, err := ExplainEval(fen)
+		if err != nil {
+			t.Fatalf("Invalid FEN %v: %v", fen, err)
+		}
+
+		// The terms are interpolated separately, so allow a little rounding error
+		diff := explanation.Total - explanation.Eval
+		if diff < -5 || diff > 5 {
+			t.Errorf("%v: terms sum to %d, eval is %d", fen, explanation.Total, explanation.Eval)
+		}
+	}
+	// A board with its own parameters is explained with them, with the terms a personality adds for the side it plays
+	for color := range NUM_COLORS {
+		params, paramsKey := SearchOptions{Personality: "gambiteer"}.searchParams(Color(color))
+		for _, fen := range fens {
+			board, err := fen.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN %v: %v", fen, err)
+			}
+			board.params, board.paramsKey = params, paramsKey
+			board.initEvalState()
+
+			explanation := board.explainEval()
+			diff := explanation.Total - explanation.Eval
+			if diff < -5 || diff > 5 {
+				t.Errorf("%v with its own parameters for color %d: terms sum to %d, eval is %d", fen, color, explanation.Total, explanation.Eval)
+			}
+		}
+	}
+}
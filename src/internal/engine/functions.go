@@ -165,13 +165,61 @@ func (p Piece) toString(c Color) string {
 	return "-"
 }
 
+// Pack a move, the promotion is NO_PIECE for moves that are not promotions
+func newMove(start, target Square, promotion Piece, code uint8) Move {
+	flags := uint16(code)
+	if promotion != NO_PIECE {
+		flags = MOVE_PROMOTION_FLAG | uint16(promotion-KNIGHT)
+		if code == MOVE_CODE_CAPTURE {
+			flags |= MOVE_PROMOTION_TAKES
+		}
+	}
+	return Move(flags<<MOVE_FLAGS_SHIFT | uint16(target)<<MOVE_TARGET_SHIFT | uint16(start))
+}
+
+// The square the move starts on
+func (m Move) start() Square {
+	return Square(m & MOVE_SQUARE_MASK)
+}
+
+// The square the move lands on
+func (m Move) target() Square {
+	return Square(m >> MOVE_TARGET_SHIFT & MOVE_SQUARE_MASK)
+}
+
+// The piece the move promotes to, NO_PIECE if it is not a promotion
+func (m Move) promotion() Piece {
+	flags := m >> MOVE_FLAGS_SHIFT
+	if flags&MOVE_PROMOTION_FLAG == 0 {
+		return NO_PIECE
+	}
+	return KNIGHT + Piece(flags&3)
+}
+
+// The code of the move, ex. MOVE_CODE_CAPTURE
+func (m Move) code() uint8 {
+	flags := uint8(m >> MOVE_FLAGS_SHIFT)
+	if flags&MOVE_PROMOTION_FLAG == 0 {
+		return flags
+	}
+	if flags&MOVE_PROMOTION_TAKES != 0 {
+		return MOVE_CODE_CAPTURE
+	}
+	return MOVE_CODE_NONE
+}
+
+// The start and target squares of the move as one index, for tables by both squares
+func (m Move) fromTo() int {
+	return int(m & MOVE_FROM_TO_MASK)
+}
+
 // Converts a Move to a string - allways sets the promotion piece to WHITE
 func (m Move) toString() string {
-	start := m.start.toString()
-	target := m.target.toString()
-	promotion := m.promotion.toString(WHITE)
+	start := m.start().toString()
+	target := m.target().toString()
+	promotion := m.promotion().toString(WHITE)
 	codeStr := "MOVE_CODE_NONE"
-	switch m.code {
+	switch m.code() {
 	case MOVE_CODE_CAPTURE:
 		codeStr = "MOVE_CODE_CAPTURE"
 	case MOVE_CODE_EN_PASSANT:
@@ -188,10 +236,10 @@ func (m Move) toString() string {
 // Converts a move to the pure cordniates notations (PCN)
 func (m Move) toPCN() string {
 	promo := ""
-	if m.promotion != NO_PIECE {
-		promo = m.promotion.toString(BLACK)
+	if m.promotion() != NO_PIECE {
+		promo = m.promotion().toString(BLACK)
 	}
-	return fmt.Sprintf("%v%v%v", m.start.toString(), m.target.toString(), promo)
+	return fmt.Sprintf("%v%v%v", m.start().toString(), m.target().toString(), promo)
 }
 
 // Converts a string of SAN to PCN
@@ -252,13 +300,13 @@ func (b *Board) SanToPCN(san string) (string, error) {
 
 	// 3. Generate all legal moves to find the candidate
 	// (Assuming you have a GenerateLegalMoves method)
-	moves := make([]Move, 256)
-	numberOfMoves := b.generatePseudoLegalMoves(moves)
+	var moves MoveList
+	b.generatePseudoLegalMoves(&moves)
 
 	var candidate Move
 	found := false
 
-	for _, m := range moves[:numberOfMoves] {
+	for _, m := range moves.slice() {
 		unmake, isLegal := b.makeMove(m)
 		if !isLegal {
 			b.unMakeMove(unmake)
@@ -267,13 +315,13 @@ func (b *Board) SanToPCN(san string) (string, error) {
 		b.unMakeMove(unmake)
 
 		// A. Check Target Square
-		if m.target != targetSq {
+		if m.target() != targetSq {
 			continue
 		}
 
 		// B. Check Piece Type
 		// We need to look at what piece is currently on the start square
-		movingPiece := b.MailBox[m.start]
+		movingPiece := b.MailBox[m.start()]
 		// Extract type (0-5) from your internal Piece byte
 		if movingPiece != targetPieceType {
 			continue
@@ -291,10 +339,10 @@ func (b *Board) SanToPCN(san string) (string, error) {
 			case "=R":
 				wantedPromo = ROOK
 			}
-			if m.promotion != wantedPromo {
+			if m.promotion() != wantedPromo {
 				continue
 			}
-		} else if m.promotion != NO_PIECE {
+		} else if m.promotion() != NO_PIECE {
 			// If SAN didn't specify promotion, but this move is a promotion, skip it
 			// (e.g., SAN "e8" shouldn't match a move that is "e7e8q")
 			continue
@@ -302,7 +350,7 @@ func (b *Board) SanToPCN(san string) (string, error) {
 
 		// D. Check Disambiguation (e.g. "Nbd7" -> start file must be 'b')
 		if disambiguation != "" {
-			startSqStr := m.start.toString()
+			startSqStr := m.start().toString()
 			startFile := string(startSqStr[0])
 			startRank := string(startSqStr[1])
 
@@ -337,12 +385,12 @@ func (b *Board) SanToPCN(san string) (string, error) {
 	}
 
 	// 4. Convert the found move to PCN string (e.g., "e2e4" or "a7a8q")
-	startStr := candidate.start.toString()
-	finalTargetStr := candidate.target.toString()
+	startStr := candidate.start().toString()
+	finalTargetStr := candidate.target().toString()
 	promoSuffix := ""
-	if candidate.promotion != NO_PIECE {
+	if candidate.promotion() != NO_PIECE {
 		// PCN usually uses lowercase for promotion (e.g., e7e8q)
-		promoSuffix = strings.ToLower(candidate.promotion.toString(BLACK))
+		promoSuffix = strings.ToLower(candidate.promotion().toString(BLACK))
 	}
 
 	return startStr + finalTargetStr + promoSuffix, nil
@@ -357,17 +405,17 @@ func (m *Move) orderScore(board *Board, ttEntry *TTEntry, killers *[2]Move, twoP
 	}
 
 	// Check promotion
-	if m.promotion != NO_PIECE {
-		return 900_000 + int(PIECE_VALUES[m.promotion])
+	if m.promotion() != NO_PIECE {
+		return 900_000 + int(PIECE_VALUES[m.promotion()])
 	}
 
 	// Check captures MVV-LVA
-	if m.code == MOVE_CODE_CAPTURE {
-		return 800_000 + int((PIECE_VALUES[board.MailBox[m.target]]*10)-PIECE_VALUES[board.MailBox[m.start]])
+	if m.code() == MOVE_CODE_CAPTURE {
+		return 800_000 + int((PIECE_VALUES[board.MailBox[m.target()]]*10)-PIECE_VALUES[board.MailBox[m.start()]])
 	}
 
 	// En passent is also a caputre
-	if m.code == MOVE_CODE_EN_PASSANT {
+	if m.code() == MOVE_CODE_EN_PASSANT {
 		return 800_900
 	}
 
@@ -397,11 +445,11 @@ func (m *Move) orderScore(board *Board, ttEntry *TTEntry, killers *[2]Move, twoP
 	// Cap history to prevent it from overtaking killers/captures
 	score := 0
 	if cutoffHistory != nil {
-		score = min(cutoffHistory[board.Turn][m.start][m.target], 650_000)
+		score = min(cutoffHistory[board.Turn][m.fromTo()], 650_000)
 	}
 
 	// Castling bonus - boost castling above regular quiet moves
-	if m.code == MOVE_CODE_CASTLE {
+	if m.code() == MOVE_CODE_CASTLE {
 		return score + 7_000
 	}
 
@@ -0,0 +1,100 @@
+package engine
+
+import "math/bits"
+
+/*
+This file holds the king safety evaluation.
+Each king gets attack units from the enemy pieces attacking its zone, the safe checks the enemy has,
+and the state of the pawns in front of it. The units are looked up in a non-linear table, so a lone
+knight near the king costs very little while a full attack with a broken shield costs a lot.
+*/
+
+// The size of the king safety table, attack units past the end use the last entry
+const KING_SAFETY_UNITS = 64
+
+// Get the king safety attack units from the pawns on the king's file and the files next to it
+// These only depend on the pawns and the king squares, so they are cached in the pawn hash
+func (b *Board) shieldUnits() [NUM_COLORS]int {
+	params := b.evalParams()
+	var units [NUM_COLORS]int
+
+	for color := WHITE; color <= BLACK; color++ {
+		kingSq := b.KingSquare[color]
+		kingFile := int(kingSq) % 8
+		kingRank := int(kingSq) / 8
+		pawns := b.Pieces[color][PAWN]
+		enemyPawns := b.Pieces[color^1][PAWN]
+
+		// The two ranks in front of the king hold the shield, enemy pawns on the three ranks in front are a storm
+		var shieldRanks, stormRanks BitBoard
+		for i := 1; i <= 3; i++ {
+			rank := kingRank + i
+			if color == BLACK {
+				rank = kingRank - i
+			}
+			if rank < 0 || rank > 7 {
+				continue
+			}
+			if i <= 2 {
+				shieldRanks |= BitBoard(0xFF) << (rank * 8)
+			}
+			stormRanks |= BitBoard(0xFF) << (rank * 8)
+		}
+
+		for file := max(kingFile-1, 0); file <= min(kingFile+1, 7); file++ {
+			if pawns&FileMask[file]&shieldRanks == 0 {
+				units[color] += params.MissingShieldUnits
+			}
+			if pawns&FileMask[file] == 0 {
+				units[color] += params.OpenFileUnits
+			}
+			if enemyPawns&FileMask[file]&stormRanks != 0 {
+				units[color] += params.PawnStormUnits
+			}
+		}
+	}
+
+	return units
+}
+
+// Add the king safety of both kings to the total, from white's perspective
+// attackUnits and attackers are the units and number of enemy pieces attacking each king's zone
+func (b *Board) kingSafetyEval(total *Weight, attacks *[NUM_COLORS][NUM_PIECES]BitBoard, attackUnits, attackers, shieldUnits [NUM_COLORS]int, trace evalTrace) {
+	params := b.evalParams()
+	occupancy := b.Occupancy[EITHER_COLOR]
+
+	for color := WHITE; color <= BLACK; color++ {
+		enemy := color ^ 1
+		kingSq := b.KingSquare[color]
+		units := shieldUnits[color]
+
+		// A single attacker is rarely dangerous, so it only counts for half
+		if attackers[color] >= 2 {
+			units += attackUnits[color]
+		} else {
+			units += attackUnits[color] / 2
+		}
+
+		// Safe checks land on squares not defended by the king's side, and not taken by the enemy's own pieces
+		var defended BitBoard
+		for piece := PAWN; piece <= KING; piece++ {
+			defended |= attacks[color][piece]
+		}
+		safe := ^defended &^ b.Occupancy[enemy]
+
+		bishopRays := getBishopAttacks(kingSq, occupancy)
+		rookRays := getRookAttacks(kingSq, occupancy)
+		units += bits.OnesCount64(uint64(KNIGHT_MOVES[kingSq]&attacks[enemy][KNIGHT]&safe)) * params.SafeCheckUnits[KNIGHT]
+		units += bits.OnesCount64(uint64(bishopRays&attacks[enemy][BISHOP]&safe)) * params.SafeCheckUnits[BISHOP]
+		units += bits.OnesCount64(uint64(rookRays&attacks[enemy][ROOK]&safe)) * params.SafeCheckUnits[ROOK]
+		units += bits.OnesCount64(uint64((bishopRays|rookRays)&attacks[enemy][QUEEN]&safe)) * params.SafeCheckUnits[QUEEN]
+
+		units = min(max(units, 0), KING_SAFETY_UNITS-1)
+		addWeight(total, &params.KingSafety[units], color, 1, trace)
+
+		// A personality values the attack of the side it plays more, without defending its own king more
+		if params.kingAttackPercent[enemy] != 0 {
+			addWeight(total, &params.kingAttack[color][units], color, 1, trace)
+		}
+	}
+}
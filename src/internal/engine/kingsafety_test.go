@@ -0,0 +1,82 @@
+package engine
+
+import "testing"
+
+func TestKingSafetyUnits(t *testing.T) {
+	InitEngine()
+	params := &EVAL_PARAMS
+
+	// Tests setup to be run, with the expected shield units for [white, black]
+	tests := []struct {
+		name     string
+		position FEN
+		expected [NUM_COLORS]int
+	}{
+		{
+			name:     "Starting position",
+			position: STARTING_POSITION_FEN,
+			expected: [NUM_COLORS]int{0, 0},
+		},
+		{
+			name:     "Castled king with a pushed shield pawn",
+			position: "6k1/5ppp/8/8/8/6P1/5P1P/6K1 w - - 0 1",
+			expected: [NUM_COLORS]int{0, 0},
+		},
+		{
+			name:     "King without pawns",
+			position: "6k1/5ppp/8/8/8/8/8/6K1 w - - 0 1",
+			expected: [NUM_COLORS]int{3 * (params.MissingShieldUnits + params.OpenFileUnits), 0},
+		},
+		{
+			name:     "Pawn storm against the king",
+			position: "6k1/5p1p/8/8/6p1/8/5PPP/6K1 w - - 0 1",
+			expected: [NUM_COLORS]int{params.PawnStormUnits, params.MissingShieldUnits},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+			if got := board.shieldUnits(); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestKingSafetyAttack(t *testing.T) {
+	InitEngine()
+
+	// The same king under attack by a queen and a rook, with and without its shield
+	sheltered, err := FEN("6k1/5ppp/8/8/8/8/5PPP/3QR1K1 b - - 0 1").toBoard(nil)
+	if err != nil {
+		t.Fatalf("Invalid FEN: %v", err)
+	}
+	exposed, err := FEN("6k1/8/8/8/8/8/5PPP/3QR1K1 b - - 0 1").toBoard(nil)
+	if err != nil {
+		t.Fatalf("Invalid FEN: %v", err)
+	}
+
+	safetyOf := func(b *Board) [NUM_COLORS]int {
+		trace := evalTrace{}
+		b.activityEval(b.getPhaseScore(), b.shieldUnits(), trace)
+		var units [NUM_COLORS]int
+		for i := range EVAL_PARAMS.KingSafety {
+			if counts, ok := trace[&EVAL_PARAMS.KingSafety[i]]; ok {
+				for color := WHITE; color <= BLACK; color++ {
+					if counts[color] != 0 {
+						units[color] = i
+					}
+				}
+			}
+		}
+		return units
+	}
+
+	if s, e := safetyOf(sheltered), safetyOf(exposed); e[BLACK] <= s[BLACK] {
+		t.Errorf("Expected the exposed king to have more units than the sheltered one, got %d and %d", e[BLACK], s[BLACK])
+	}
+}
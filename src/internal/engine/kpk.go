@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"math/bits"
+	"sync"
+)
+
+/*
+This file holds the king and pawn against king bitbase.
+The bitbase is generated by retrograde analysis with the engine's own move generator the first time it is probed,
+so it needs no files. Every position with white having the pawn is solved as a win or a draw and packed into a bitset.
+Positions with black having the pawn, or the pawn on the e to h files, are mirrored onto the stored ones.
+*/
+
+// The pawn can be on the a to d files and the second to seventh ranks, with the kings anywhere and either side to move
+const KPK_PAWN_SQUARES = 24
+const KPK_SIZE = KPK_PAWN_SQUARES * NUM_SQUARES * NUM_SQUARES * int(NUM_COLORS)
+
+// The bitbase, a set bit means the side with the pawn wins
+var KPK_BITBASE [KPK_SIZE / 64]uint64
+
+var kpkOnce sync.Once
+
+// The result of a position while the bitbase is being generated
+type kpkResult uint8
+
+const (
+	KPK_UNKNOWN kpkResult = iota
+	KPK_INVALID
+	KPK_DRAW
+	KPK_WIN
+)
+
+// Get the index of a position into the bitbase, white has the pawn on the a to d files
+func kpkIndex(turn Color, whiteKing, blackKing, pawn Square) int {
+	pawnIndex := (int(pawn)/8-1)*4 + int(pawn)%8
+	return ((pawnIndex*NUM_SQUARES+int(whiteKing))*NUM_SQUARES+int(blackKing))*int(NUM_COLORS) + int(turn)
+}
+
+// Get the position of an index into the bitbase
+func kpkPosition(index int) (turn Color, whiteKing, blackKing, pawn Square) {
+	turn = Color(index % int(NUM_COLORS))
+	index /= int(NUM_COLORS)
+	blackKing = Square(index % NUM_SQUARES)
+	index /= NUM_SQUARES
+	whiteKing = Square(index % NUM_SQUARES)
+	pawnIndex := index / NUM_SQUARES
+	pawn = Square((pawnIndex/4+1)*8 + pawnIndex%4)
+	return turn, whiteKing, blackKing, pawn
+}
+
+// Generate the bitbase
+func initKPK() {
+	results := make([]kpkResult, KPK_SIZE)
+
+	// The positions each position can move to, for positions that are not decided yet
+	children := make([][]int32, KPK_SIZE)
+
+	for index := range KPK_SIZE {
+		turn, whiteKing, blackKing, pawn := kpkPosition(index)
+
+		// The pieces need their own squares, the kings can not touch, and the side not to move can not be in check
+		if whiteKing == blackKing || whiteKing == pawn || blackKing == pawn ||
+			KING_MOVES[whiteKing]&blackKing.bitBoardPosition() != 0 ||
+			(turn == WHITE && pawnAttacks(pawn.bitBoardPosition(), WHITE)&blackKing.bitBoardPosition() != 0) {
+			results[index] = KPK_INVALID
+			continue
+		}
+
+		board := newBoardFromPieces(turn, []placedPiece{{WHITE, KING, whiteKing}, {BLACK, KING, blackKing}, {WHITE, PAWN, pawn}})
+		moves := board.generateLegalMoves()
+		if len(moves) == 0 {
+			results[index] = KPK_DRAW
+			if board.isInCheck(turn) {
+				results[index] = KPK_WIN
+			}
+			continue
+		}
+
+		for _, move := range moves {
+			undo, _ := board.makeMove(move)
+			switch {
+			case board.Pieces[WHITE][PAWN] == 0 && board.Pieces[WHITE][QUEEN]|board.Pieces[WHITE][ROOK] != 0:
+				// Promoting to a queen or rook wins, unless it is lost straight away or stalemates
+				if results[index] != KPK_WIN && board.promotionWins() {
+					results[index] = KPK_WIN
+				}
+			case board.Pieces[WHITE][PAWN] == 0:
+				// The pawn was taken, or promoted to a piece that can not mate
+			default:
+				newPawn := board.Pieces[WHITE][PAWN]
+				children[index] = append(children[index], int32(kpkIndex(board.Turn, board.KingSquare[WHITE], board.KingSquare[BLACK], newPawn.popSquare())))
+			}
+			board.unMakeMove(undo)
+		}
+
+		// Black taking the pawn is a draw
+		if turn == BLACK && len(children[index]) < len(moves) {
+			results[index] = KPK_DRAW
+		}
+	}
+
+	// Keep deciding positions from the ones they can move to until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for index := range KPK_SIZE {
+			if results[index] != KPK_UNKNOWN {
+				continue
+			}
+
+			// White wants any move that wins, black wants any move that draws
+			good, bad := KPK_WIN, KPK_DRAW
+			if Color(index%int(NUM_COLORS)) == BLACK {
+				good, bad = KPK_DRAW, KPK_WIN
+			}
+			result := bad
+			for _, child := range children[index] {
+				if results[child] == good {
+					result = good
+					break
+				}
+				if results[child] == KPK_UNKNOWN {
+					result = KPK_UNKNOWN
+				}
+			}
+			if result != KPK_UNKNOWN {
+				results[index] = result
+				changed = true
+			}
+		}
+	}
+
+	// Positions still undecided can never be won, as white can not force anything
+	for index, result := range results {
+		if result == KPK_WIN {
+			KPK_BITBASE[index/64] |= 1 << (index % 64)
+		}
+	}
+}
+
+// Check if a white promotion wins, with black to move after it
+func (b *Board) promotionWins() bool {
+	moves := b.generateLegalMoves()
+	if len(moves) == 0 {
+		return b.isInCheck(BLACK)
+	}
+	for _, move := range moves {
+		if b.MailBox[move.target()] != NO_PIECE {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if the board is king and pawn against king
+func (b *Board) isKPK() bool {
+	return bits.OnesCount64(uint64(b.Occupancy[EITHER_COLOR])) == 3 && b.Pieces[WHITE][PAWN]|b.Pieces[BLACK][PAWN] != 0
+}
+
+// Probe the bitbase, returns true if the side with the pawn wins
+// The board must be king and pawn against king
+func (b *Board) probeKPK() bool {
+	kpkOnce.Do(initKPK)
+
+	strong := WHITE
+	if b.Pieces[WHITE][PAWN] == 0 {
+		strong = BLACK
+	}
+	pawns := b.Pieces[strong][PAWN]
+	pawn := pawns.popSquare()
+	strongKing, weakKing := b.KingSquare[strong], b.KingSquare[strong^1]
+
+	// Flip the board so the strong side is white, and mirror it so the pawn is on the a to d files
+	turn := b.Turn
+	if strong == BLACK {
+		pawn, strongKing, weakKing = pawn^56, strongKing^56, weakKing^56
+		turn ^= 1
+	}
+	if pawn%8 > 3 {
+		pawn, strongKing, weakKing = pawn^7, strongKing^7, weakKing^7
+	}
+
+	index := kpkIndex(turn, strongKing, weakKing, pawn)
+	return KPK_BITBASE[index/64]&(1<<(index%64)) != 0
+}
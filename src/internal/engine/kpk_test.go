@@ -0,0 +1,75 @@
+package engine
+
+import "testing"
+
+func TestProbeKPK(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with whether the side with the pawn wins
+	tests := []struct {
+		name     string
+		position FEN
+		win      bool
+	}{
+		{
+			name:     "King on the sixth in front of the pawn",
+			position: "4k3/8/4K3/4P3/8/8/8/8 w - - 0 1",
+			win:      true,
+		},
+		{
+			name:     "King on the sixth in front of the pawn, black to move",
+			position: "4k3/8/4K3/4P3/8/8/8/8 b - - 0 1",
+			win:      true,
+		},
+		{
+			name:     "Defending king takes the opposition",
+			position: "8/4k3/8/4K3/4P3/8/8/8 w - - 0 1",
+			win:      false,
+		},
+		{
+			name:     "Defending king has to give way",
+			position: "8/4k3/8/4K3/4P3/8/8/8 b - - 0 1",
+			win:      true,
+		},
+		{
+			name:     "Defending king in front of the pawn",
+			position: "8/8/8/4k3/8/8/4P3/4K3 w - - 0 1",
+			win:      false,
+		},
+		{
+			name:     "Rook pawn",
+			position: "k7/8/K7/P7/8/8/8/8 w - - 0 1",
+			win:      false,
+		},
+		{
+			name:     "Rook pawn on the h file",
+			position: "7k/8/7K/7P/8/8/8/8 w - - 0 1",
+			win:      false,
+		},
+		{
+			name:     "Black pawn",
+			position: "8/8/8/8/4p3/4k3/8/4K3 w - - 0 1",
+			win:      true,
+		},
+		{
+			name:     "Pawn outside the square",
+			position: "8/8/8/8/8/7k/P7/4K3 w - - 0 1",
+			win:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+			if !board.isKPK() {
+				t.Fatalf("Expected a KPK position")
+			}
+			if got := board.probeKPK(); got != tc.win {
+				t.Errorf("Expected win %v, got %v", tc.win, got)
+			}
+		})
+	}
+}
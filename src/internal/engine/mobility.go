@@ -0,0 +1,145 @@
+package engine
+
+import "math/bits"
+
+/*
+This file holds the attack based evaluation terms: mobility, threats, outposts and rooks on the seventh.
+The attacks of every piece are generated with the same lookup tables as the move generation,
+and are also used for the king safety.
+*/
+
+// Evaluate the activity of the pieces and the king safety, from white's perspective
+func (b *Board) activityEval(phaseScore int, shieldUnits [NUM_COLORS]int, trace evalTrace) Eval {
+	params := b.evalParams()
+	occupancy := b.Occupancy[EITHER_COLOR]
+	var total Weight
+
+	// Squares attacked by each side, by piece
+	var attacks [NUM_COLORS][NUM_PIECES]BitBoard
+
+	// King safety attack units, and the number of enemy pieces attacking each king zone
+	var kingAttackUnits, kingAttackers [NUM_COLORS]int
+	for color := WHITE; color <= BLACK; color++ {
+		attacks[color][PAWN] = pawnAttacks(b.Pieces[color][PAWN], color)
+		attacks[color][KING] = KING_MOVES[b.KingSquare[color]]
+	}
+
+	for color := WHITE; color <= BLACK; color++ {
+		enemy := color ^ 1
+		enemyKingZone := KingZone[enemy][b.KingSquare[enemy]]
+
+		// Squares taken by friendly pieces or attacked by enemy pawns are not counted as mobility
+		mobilityArea := ^b.Occupancy[color] &^ attacks[enemy][PAWN]
+
+		// Outposts need to be in the enemy half and can never be attacked by an enemy pawn
+		outposts := attacks[color][PAWN] &^ pawnAttackSpan(b.Pieces[enemy][PAWN], enemy)
+		if color == WHITE {
+			outposts &= BitBoard(0x0000FFFFFF000000)
+		} else {
+			outposts &= BitBoard(0x000000FFFFFF0000)
+		}
+
+		knights := b.Pieces[color][KNIGHT]
+		for knights != 0 {
+			sq := knights.popSquare()
+			attacked := KNIGHT_MOVES[sq]
+			attacks[color][KNIGHT] |= attacked
+			if attacked&enemyKingZone != 0 {
+				kingAttackUnits[enemy] += params.KingAttackUnits[KNIGHT]
+				kingAttackers[enemy]++
+			}
+			addWeight(&total, &params.KnightMobility[bits.OnesCount64(uint64(attacked&mobilityArea))], color, 1, trace)
+			if outposts&sq.bitBoardPosition() != 0 {
+				addWeight(&total, &params.KnightOutpost, color, 1, trace)
+			}
+		}
+
+		bishops := b.Pieces[color][BISHOP]
+		for bishops != 0 {
+			sq := bishops.popSquare()
+			attacked := getBishopAttacks(sq, occupancy)
+			attacks[color][BISHOP] |= attacked
+			if attacked&enemyKingZone != 0 {
+				kingAttackUnits[enemy] += params.KingAttackUnits[BISHOP]
+				kingAttackers[enemy]++
+			}
+			addWeight(&total, &params.BishopMobility[bits.OnesCount64(uint64(attacked&mobilityArea))], color, 1, trace)
+			if outposts&sq.bitBoardPosition() != 0 {
+				addWeight(&total, &params.BishopOutpost, color, 1, trace)
+			}
+		}
+
+		// The seventh rank from the side of the rook
+		seventh := BitBoard(0x00FF000000000000)
+		if color == BLACK {
+			seventh = BitBoard(0x000000000000FF00)
+		}
+
+		rooks := b.Pieces[color][ROOK]
+		for rooks != 0 {
+			sq := rooks.popSquare()
+			attacked := getRookAttacks(sq, occupancy)
+			attacks[color][ROOK] |= attacked
+			if attacked&enemyKingZone != 0 {
+				kingAttackUnits[enemy] += params.KingAttackUnits[ROOK]
+				kingAttackers[enemy]++
+			}
+			addWeight(&total, &params.RookMobility[bits.OnesCount64(uint64(attacked&mobilityArea))], color, 1, trace)
+			if seventh&sq.bitBoardPosition() != 0 {
+				addWeight(&total, &params.RookOnSeventh, color, 1, trace)
+			}
+		}
+
+		queens := b.Pieces[color][QUEEN]
+		for queens != 0 {
+			sq := queens.popSquare()
+			attacked := getBishopAttacks(sq, occupancy) |
+				getRookAttacks(sq, occupancy)
+			attacks[color][QUEEN] |= attacked
+			if attacked&enemyKingZone != 0 {
+				kingAttackUnits[enemy] += params.KingAttackUnits[QUEEN]
+				kingAttackers[enemy]++
+			}
+			addWeight(&total, &params.QueenMobility[bits.OnesCount64(uint64(attacked&mobilityArea))], color, 1, trace)
+		}
+	}
+
+	// Threats, counted for the attacking side
+	for color := WHITE; color <= BLACK; color++ {
+		enemy := color ^ 1
+		enemyPieces := b.Pieces[enemy][KNIGHT] | b.Pieces[enemy][BISHOP] | b.Pieces[enemy][ROOK] | b.Pieces[enemy][QUEEN]
+		enemyMajors := b.Pieces[enemy][ROOK] | b.Pieces[enemy][QUEEN]
+
+		var allAttacks, enemyAttacks BitBoard
+		for piece := PAWN; piece <= KING; piece++ {
+			allAttacks |= attacks[color][piece]
+			enemyAttacks |= attacks[enemy][piece]
+		}
+
+		byPawn := bits.OnesCount64(uint64(attacks[color][PAWN] & enemyPieces))
+		byMinor := bits.OnesCount64(uint64((attacks[color][KNIGHT] | attacks[color][BISHOP]) & enemyMajors))
+		byRook := bits.OnesCount64(uint64(attacks[color][ROOK] & b.Pieces[enemy][QUEEN]))
+		hanging := bits.OnesCount64(uint64(allAttacks & (enemyPieces | b.Pieces[enemy][PAWN]) &^ enemyAttacks))
+		addWeight(&total, &params.ThreatByPawn, color, byPawn, trace)
+		addWeight(&total, &params.ThreatByMinor, color, byMinor, trace)
+		addWeight(&total, &params.ThreatByRook, color, byRook, trace)
+		addWeight(&total, &params.HangingPiece, color, hanging, trace)
+	}
+
+	b.kingSafetyEval(&total, &attacks, kingAttackUnits, kingAttackers, shieldUnits, trace)
+
+	return total.interpolate(phaseScore)
+}
+
+// Get every square pawns could ever attack as they advance
+func pawnAttackSpan(pawns BitBoard, color Color) BitBoard {
+	span := pawnAttacks(pawns, color)
+	for i := 0; i < 5; i++ {
+		if color == WHITE {
+			span |= span << 8
+		} else {
+			span |= span >> 8
+		}
+	}
+	return span
+}
@@ -0,0 +1,72 @@
+package engine
+
+import "testing"
+
+func TestActivityTerms(t *testing.T) {
+	InitEngine()
+	params := &EVAL_PARAMS
+
+	// Tests setup to be run, with the weight expected to be used and its counts for [white, black]
+	tests := []struct {
+		name     string
+		position FEN
+		weight   *Weight
+		expected [NUM_COLORS]int
+	}{
+		{
+			name:     "Knight outpost defended by a pawn",
+			position: "4k3/8/8/4N3/3P4/8/8/4K3 w - - 0 1",
+			weight:   &params.KnightOutpost,
+			expected: [NUM_COLORS]int{1, 0},
+		},
+		{
+			name:     "No outpost when an enemy pawn can attack it",
+			position: "4k3/5p2/8/4N3/3P4/8/8/4K3 w - - 0 1",
+			weight:   &params.KnightOutpost,
+			expected: [NUM_COLORS]int{0, 0},
+		},
+		{
+			name:     "Pawn attacking a knight",
+			position: "4k3/8/8/3n4/4P3/8/8/4K3 w - - 0 1",
+			weight:   &params.ThreatByPawn,
+			expected: [NUM_COLORS]int{1, 0},
+		},
+		{
+			name:     "Undefended knight is hanging",
+			position: "4k3/8/8/3n4/4P3/8/8/4K3 w - - 0 1",
+			weight:   &params.HangingPiece,
+			expected: [NUM_COLORS]int{1, 0},
+		},
+		{
+			name:     "Rook on the seventh",
+			position: "4k3/R7/8/8/8/8/7r/4K3 w - - 0 1",
+			weight:   &params.RookOnSeventh,
+			expected: [NUM_COLORS]int{1, 1},
+		},
+		{
+			name:     "Knight in the corner has two squares",
+			position: "4k3/8/8/8/8/8/8/N3K3 w - - 0 1",
+			weight:   &params.KnightMobility[2],
+			expected: [NUM_COLORS]int{1, 0},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+
+			trace := evalTrace{}
+			board.activityEval(board.getPhaseScore(), [NUM_COLORS]int{}, trace)
+			var got [NUM_COLORS]int
+			if counts, ok := trace[tc.weight]; ok {
+				got = *counts
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -43,14 +43,13 @@ a1 b1 c1 d1 e1 f1 g1 h1
 */
 
 // Used by board.generateMoves() to get the pseudo-legal queen moves
-func (b *Board) getQueenMoves(moves []Move, moveIdx int) int {
+func (b *Board) getQueenMoves(list *MoveList) {
 	queens := b.Pieces[b.Turn][QUEEN]
 	enemyPieces := b.getEnemyPieces()
 
 	for queens > 0 {
 		start := queens.popSquare()
-		magicIdx := MAGIC_ROOK_INFO[start].getMagicIndex(b.Occupancy[EITHER_COLOR])
-		magicMoves := MAGIC_ROOK_MOVES[start][magicIdx] &^ b.Occupancy[b.Turn]
+		magicMoves := getRookAttacks(start, b.Occupancy[EITHER_COLOR]) &^ b.Occupancy[b.Turn]
 
 		for magicMoves > 0 {
 			target := magicMoves.popSquare()
@@ -60,11 +59,10 @@ func (b *Board) getQueenMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 
-		magicIdx = MAGIC_BISHOP_INFO[start].getMagicIndex(b.Occupancy[EITHER_COLOR])
-		magicMoves = MAGIC_BISHOP_MOVES[start][magicIdx] &^ b.Occupancy[b.Turn]
+		magicMoves = getBishopAttacks(start, b.Occupancy[EITHER_COLOR]) &^ b.Occupancy[b.Turn]
 
 		for magicMoves > 0 {
 			target := magicMoves.popSquare()
@@ -74,22 +72,20 @@ func (b *Board) getQueenMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the pseudo-legal rook moves
-func (b *Board) getRookMoves(moves []Move, moveIdx int) int {
+func (b *Board) getRookMoves(list *MoveList) {
 	rooks := b.Pieces[b.Turn][ROOK]
 	enemyPieces := b.getEnemyPieces()
 
 	for rooks > 0 {
 		start := rooks.popSquare()
-		magicIdx := MAGIC_ROOK_INFO[start].getMagicIndex(b.Occupancy[EITHER_COLOR])
-		magicMoves := MAGIC_ROOK_MOVES[start][magicIdx] &^ b.Occupancy[b.Turn]
+		magicMoves := getRookAttacks(start, b.Occupancy[EITHER_COLOR]) &^ b.Occupancy[b.Turn]
 
 		for magicMoves > 0 {
 			target := magicMoves.popSquare()
@@ -99,22 +95,20 @@ func (b *Board) getRookMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the pseudo-legal bishop moves
-func (b *Board) getBishopMoves(moves []Move, moveIdx int) int {
+func (b *Board) getBishopMoves(list *MoveList) {
 	bishops := b.Pieces[b.Turn][BISHOP]
 	enemyPieces := b.getEnemyPieces()
 
 	for bishops > 0 {
 		start := bishops.popSquare()
-		magicIdx := MAGIC_BISHOP_INFO[start].getMagicIndex(b.Occupancy[EITHER_COLOR])
-		magicMoves := MAGIC_BISHOP_MOVES[start][magicIdx] &^ b.Occupancy[b.Turn]
+		magicMoves := getBishopAttacks(start, b.Occupancy[EITHER_COLOR]) &^ b.Occupancy[b.Turn]
 
 		for magicMoves > 0 {
 			target := magicMoves.popSquare()
@@ -124,11 +118,10 @@ func (b *Board) getBishopMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the pseudo-legal pawn moves
@@ -138,7 +131,7 @@ func (b *Board) getBishopMoves(moves []Move, moveIdx int) int {
 // This is inefficient
 // Should be more like pushes := (whitePawns << 8) & ^occupancy to get all the pawn moves for white one push
 // Todo: Refactor later to make more efficient
-func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
+func (b *Board) getPawnMoves(list *MoveList) {
 	pawns := b.Pieces[b.Turn][PAWN]
 	occupancy := b.Occupancy[EITHER_COLOR]
 	enemyPieces := b.getEnemyPieces()
@@ -153,11 +146,11 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 			// Single Push (Not promotions, those are handled at the end of the code)
 			if occupancy&oneSq.bitBoardPosition() == 0 {
 				if oneSq <= 55 {
-					moveIdx = addMove(moves, start, oneSq, MOVE_CODE_NONE, false, moveIdx)
+					list.addMove(start, oneSq, MOVE_CODE_NONE, false)
 
 					// Double Push (on second rank)
 					if start >= 8 && start <= 15 && (occupancy&twoSq.bitBoardPosition()) == 0 {
-						moveIdx = addMove(moves, start, twoSq, MOVE_CODE_DOUBLE_PAWN_PUSH, false, moveIdx)
+						list.addMove(start, twoSq, MOVE_CODE_DOUBLE_PAWN_PUSH, false)
 					}
 				}
 			}
@@ -179,14 +172,14 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 					if capLeft == b.EPS {
 						code = MOVE_CODE_EN_PASSANT
 					}
-					moveIdx = addMove(moves, start, capLeft, code, false, moveIdx)
+					list.addMove(start, capLeft, code, false)
 				}
 				if canCapRight {
 					code := MOVE_CODE_CAPTURE
 					if capRight == b.EPS {
 						code = MOVE_CODE_EN_PASSANT
 					}
-					moveIdx = addMove(moves, start, capRight, code, false, moveIdx)
+					list.addMove(start, capRight, code, false)
 				}
 			}
 
@@ -194,15 +187,15 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 			if oneSq > 55 {
 				// Push Promotion
 				if (occupancy & oneSq.bitBoardPosition()) == 0 {
-					moveIdx = addMove(moves, start, oneSq, MOVE_CODE_NONE, true, moveIdx)
+					list.addMove(start, oneSq, MOVE_CODE_NONE, true)
 				}
 				// Capture Left Promotion
 				if canCapLeft {
-					moveIdx = addMove(moves, start, capLeft, MOVE_CODE_CAPTURE, true, moveIdx)
+					list.addMove(start, capLeft, MOVE_CODE_CAPTURE, true)
 				}
 				// Capture Right Promotion
 				if canCapRight {
-					moveIdx = addMove(moves, start, capRight, MOVE_CODE_CAPTURE, true, moveIdx)
+					list.addMove(start, capRight, MOVE_CODE_CAPTURE, true)
 				}
 			}
 
@@ -213,9 +206,9 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 			// Single Push (Not promotions, those are handled at the end of the code)
 			if (occupancy & oneSq.bitBoardPosition()) == 0 {
 				if oneSq >= 8 {
-					moveIdx = addMove(moves, start, oneSq, MOVE_CODE_NONE, false, moveIdx)
+					list.addMove(start, oneSq, MOVE_CODE_NONE, false)
 					if start >= 48 && start <= 55 && (occupancy&twoSq.bitBoardPosition()) == 0 {
-						moveIdx = addMove(moves, start, twoSq, MOVE_CODE_DOUBLE_PAWN_PUSH, false, moveIdx)
+						list.addMove(start, twoSq, MOVE_CODE_DOUBLE_PAWN_PUSH, false)
 					}
 				}
 			}
@@ -237,14 +230,14 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 					if capLeft == b.EPS {
 						code = MOVE_CODE_EN_PASSANT
 					}
-					moveIdx = addMove(moves, start, capLeft, code, false, moveIdx)
+					list.addMove(start, capLeft, code, false)
 				}
 				if canCapRight {
 					code := MOVE_CODE_CAPTURE
 					if capRight == b.EPS {
 						code = MOVE_CODE_EN_PASSANT
 					}
-					moveIdx = addMove(moves, start, capRight, code, false, moveIdx)
+					list.addMove(start, capRight, code, false)
 				}
 			}
 
@@ -252,26 +245,25 @@ func (b *Board) getPawnMoves(moves []Move, moveIdx int) int {
 			if oneSq < 8 {
 				// Push Promotion
 				if occupancy&oneSq.bitBoardPosition() == 0 {
-					moveIdx = addMove(moves, start, oneSq, MOVE_CODE_NONE, true, moveIdx)
+					list.addMove(start, oneSq, MOVE_CODE_NONE, true)
 				}
 				// Capture Left Promotion
 				if canCapLeft {
-					moveIdx = addMove(moves, start, capLeft, MOVE_CODE_CAPTURE, true, moveIdx)
+					list.addMove(start, capLeft, MOVE_CODE_CAPTURE, true)
 				}
 				// Capture Right Promotion
 				if canCapRight {
-					moveIdx = addMove(moves, start, capRight, MOVE_CODE_CAPTURE, true, moveIdx)
+					list.addMove(start, capRight, MOVE_CODE_CAPTURE, true)
 				}
 			}
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the pseudo-legal king moves
 // This does not include castling
-func (b *Board) getKingMoves(moves []Move, moveIdx int) int {
+func (b *Board) getKingMoves(list *MoveList) {
 	king := b.Pieces[b.Turn][KING]
 	enemyPieces := b.getEnemyPieces()
 
@@ -285,15 +277,14 @@ func (b *Board) getKingMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the pseudo-legal knight moves
-func (b *Board) getKnightMoves(moves []Move, moveIdx int) int {
+func (b *Board) getKnightMoves(list *MoveList) {
 	knights := b.Pieces[b.Turn][KNIGHT]
 	enemyPieces := b.getEnemyPieces()
 
@@ -307,16 +298,15 @@ func (b *Board) getKnightMoves(moves []Move, moveIdx int) int {
 			if target.bitBoardPosition()&enemyPieces != 0 {
 				code = MOVE_CODE_CAPTURE
 			}
-			moveIdx = addMove(moves, start, target, code, false, moveIdx)
+			list.addMove(start, target, code, false)
 		}
 	}
 
-	return moveIdx
 }
 
 // Used by board.generateMoves() to get the legal castling moves
 // This function checks for king is attacked after the move, which is the main logical difference between legal and pseudo-legal
-func (b *Board) getCastlingMoves(moves []Move, moveIdx int) int {
+func (b *Board) getCastlingMoves(list *MoveList) {
 	occupancy := b.Occupancy[EITHER_COLOR]
 
 	// White can castle kingside
@@ -329,7 +319,7 @@ func (b *Board) getCastlingMoves(moves []Move, moveIdx int) int {
 			// Checking if the king is placed in check on g1 is handled later, when validating all moves against
 			// Illegally putting the king in check
 			if !b.isSquareAttacked(E1, BLACK) && !b.isSquareAttacked(F1, BLACK) {
-				moveIdx = addMove(moves, E1, G1, MOVE_CODE_CASTLE, false, moveIdx)
+				list.addMove(E1, G1, MOVE_CODE_CASTLE, false)
 			}
 		}
 	}
@@ -345,7 +335,7 @@ func (b *Board) getCastlingMoves(moves []Move, moveIdx int) int {
 			// Illegally putting the king in check
 			// TODO: Optimize to avoid repeat calls checking e1 for both queen/kingside castling, though its probably not often
 			if !b.isSquareAttacked(E1, BLACK) && !b.isSquareAttacked(D1, BLACK) {
-				moveIdx = addMove(moves, E1, C1, MOVE_CODE_CASTLE, false, moveIdx)
+				list.addMove(E1, C1, MOVE_CODE_CASTLE, false)
 			}
 		}
 	}
@@ -360,7 +350,7 @@ func (b *Board) getCastlingMoves(moves []Move, moveIdx int) int {
 			// Checking if the king is placed in check on g8 is handled later, when validating all moves against
 			// Illegally putting the king in check
 			if !b.isSquareAttacked(E8, WHITE) && !b.isSquareAttacked(F8, WHITE) {
-				moveIdx = addMove(moves, E8, G8, MOVE_CODE_CASTLE, false, moveIdx)
+				list.addMove(E8, G8, MOVE_CODE_CASTLE, false)
 			}
 		}
 	}
@@ -376,13 +366,12 @@ func (b *Board) getCastlingMoves(moves []Move, moveIdx int) int {
 			// Illegally putting the king in check
 			// TODO: Optimize to avoid repeat calls checking E8 for both queen/kingside castling, though its probably not often
 			if !b.isSquareAttacked(E8, WHITE) && !b.isSquareAttacked(D8, WHITE) {
-				moveIdx = addMove(moves, E8, C8, MOVE_CODE_CASTLE, false, moveIdx)
+				list.addMove(E8, C8, MOVE_CODE_CASTLE, false)
 			}
 		}
 
 	}
 
-	return moveIdx
 }
 
 // Helper function to check if a square is under attack, most useful for checking if king is under attack after a pseudo-legal move
@@ -424,14 +413,12 @@ func (b *Board) isSquareAttacked(sq Square, attackerSide Color) bool {
 
 	// Check Bishop/Queen Diagonal Attacks
 	// Reuse your magic bitboards!
-	bIdx := MAGIC_BISHOP_INFO[sq].getMagicIndex(b.Occupancy[EITHER_COLOR])
-	if (MAGIC_BISHOP_MOVES[sq][bIdx] & (b.Pieces[attackerSide][BISHOP] | b.Pieces[attackerSide][QUEEN])) != 0 {
+	if (getBishopAttacks(sq, b.Occupancy[EITHER_COLOR]) & (b.Pieces[attackerSide][BISHOP] | b.Pieces[attackerSide][QUEEN])) != 0 {
 		return true
 	}
 
 	// Check Rook/Queen Straight Attacks
-	rIdx := MAGIC_ROOK_INFO[sq].getMagicIndex(b.Occupancy[EITHER_COLOR])
-	if (MAGIC_ROOK_MOVES[sq][rIdx] & (b.Pieces[attackerSide][ROOK] | b.Pieces[attackerSide][QUEEN])) != 0 {
+	if (getRookAttacks(sq, b.Occupancy[EITHER_COLOR]) & (b.Pieces[attackerSide][ROOK] | b.Pieces[attackerSide][QUEEN])) != 0 {
 		return true
 	}
 
@@ -443,24 +430,39 @@ func (b *Board) isInCheck(sideToCheck Color) bool {
 	return b.isSquareAttacked(b.KingSquare[sideToCheck], sideToCheck^1)
 }
 
-// Helper function to add a move and increment the counter
-func addMove(moves []Move, start, target Square, code uint8, isPromotion bool, moveIdx int) int {
+// Helper function to add a move to the list, adding one move for each piece it can promote to
+func (l *MoveList) addMove(start, target Square, code uint8, isPromotion bool) {
 	if isPromotion {
 		for _, piece := range []Piece{KNIGHT, BISHOP, ROOK, QUEEN} {
-			moves[moveIdx].start = start
-			moves[moveIdx].target = target
-			moves[moveIdx].promotion = piece
-			moves[moveIdx].code = code
-			moveIdx++
+			l.moves[l.count] = newMove(start, target, piece, code)
+			l.count++
 		}
 	} else {
-		moves[moveIdx].start = start
-		moves[moveIdx].target = target
-		moves[moveIdx].code = code
-		moves[moveIdx].promotion = NO_PIECE
-		moveIdx++
+		l.moves[l.count] = newMove(start, target, NO_PIECE, code)
+		l.count++
+	}
+}
+
+// The moves in the list
+func (l *MoveList) slice() []Move {
+	return l.moves[:l.count]
+}
+
+// Sort the moves in the list by their scores, from highest to lowest
+// Insertion sort, as the lists are short and it keeps the order of moves with equal scores
+func (l *MoveList) sort() {
+	for i := 1; i < l.count; i++ {
+		move := l.moves[i]
+		score := l.scores[i]
+		j := i - 1
+		for j >= 0 && l.scores[j] < score {
+			l.moves[j+1] = l.moves[j]
+			l.scores[j+1] = l.scores[j]
+			j--
+		}
+		l.moves[j+1] = move
+		l.scores[j+1] = score
 	}
-	return moveIdx
 }
 
 // Global magic lookup table for Rooks (reused for queens)
@@ -480,6 +482,22 @@ func (m *Magic) getMagicIndex(occupancy BitBoard) int {
 	return int((uint64(occupancy&m.mask) * m.magic) >> m.shift)
 }
 
+// Get the squares a rook on the square attacks, up to and including the first piece in each direction
+func getRookAttacks(sq Square, occupancy BitBoard) BitBoard {
+	if USE_PEXT {
+		return getPextRookAttacks(sq, occupancy)
+	}
+	return MAGIC_ROOK_MOVES[sq][MAGIC_ROOK_INFO[sq].getMagicIndex(occupancy)]
+}
+
+// Get the squares a bishop on the square attacks, up to and including the first piece in each direction
+func getBishopAttacks(sq Square, occupancy BitBoard) BitBoard {
+	if USE_PEXT {
+		return getPextBishopAttacks(sq, occupancy)
+	}
+	return MAGIC_BISHOP_MOVES[sq][MAGIC_BISHOP_INFO[sq].getMagicIndex(occupancy)]
+}
+
 // Global rook magic info
 var MAGIC_ROOK_INFO [NUM_SQUARES]Magic
 
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"testing"
+	"unsafe"
 )
 
 func TestInitKingMoves(t *testing.T) {
@@ -108,3 +109,45 @@ func TestInitKnightMoves(t *testing.T) {
 		})
 	}
 }
+
+func TestMovePacking(t *testing.T) {
+	// Tests setup to be run, every move has to unpack to what it was packed from
+	tests := []struct {
+		start     Square
+		target    Square
+		promotion Piece
+		code      uint8
+		pcn       string
+	}{
+		{start: E2, target: E4, promotion: NO_PIECE, code: MOVE_CODE_DOUBLE_PAWN_PUSH, pcn: "e2e4"},
+		{start: G1, target: F3, promotion: NO_PIECE, code: MOVE_CODE_NONE, pcn: "g1f3"},
+		{start: E5, target: D6, promotion: NO_PIECE, code: MOVE_CODE_EN_PASSANT, pcn: "e5d6"},
+		{start: E8, target: C8, promotion: NO_PIECE, code: MOVE_CODE_CASTLE, pcn: "e8c8"},
+		{start: H8, target: A1, promotion: NO_PIECE, code: MOVE_CODE_CAPTURE, pcn: "h8a1"},
+		{start: A7, target: A8, promotion: KNIGHT, code: MOVE_CODE_NONE, pcn: "a7a8n"},
+		{start: B7, target: A8, promotion: QUEEN, code: MOVE_CODE_CAPTURE, pcn: "b7a8q"},
+		{start: H2, target: G1, promotion: ROOK, code: MOVE_CODE_CAPTURE, pcn: "h2g1r"},
+		{start: C2, target: C1, promotion: BISHOP, code: MOVE_CODE_NONE, pcn: "c2c1b"},
+	}
+
+	for _, tc := range tests {
+		move := newMove(tc.start, tc.target, tc.promotion, tc.code)
+		if move.start() != tc.start || move.target() != tc.target || move.promotion() != tc.promotion || move.code() != tc.code {
+			t.Errorf("%v: Expected %v %v %v %v, got %v", tc.pcn, tc.start, tc.target, tc.promotion, tc.code, move.toString())
+		}
+		if move.toPCN() != tc.pcn {
+			t.Errorf("Expected %v, got %v", tc.pcn, move.toPCN())
+		}
+		if move == NO_MOVE {
+			t.Errorf("%v: Expected a move, got no move", tc.pcn)
+		}
+	}
+
+	// The moves and TT entries stay small
+	if size := unsafe.Sizeof(Move(0)); size != 2 {
+		t.Errorf("Expected a move to be 2 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(TTEntry{}); size != 8 {
+		t.Errorf("Expected a TT entry to be 8 bytes, got %d", size)
+	}
+}
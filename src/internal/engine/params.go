@@ -0,0 +1,452 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+)
+
+/*
+This file holds the parameters of the evaluation function.
+Every weight the evaluation uses lives in EvalParams, so they can be tuned and saved as one parameter vector.
+The compiled defaults are built from the hand-picked values in eval.go and the tables in pst.go.
+A JSON parameter file can replace any of the defaults when the engine is initialized.
+*/
+
+// A tapered evaluation weight, with a value for the opening and one for the endgame
+// The two are interpolated with the phase score of the position
+type Weight struct {
+	Opening Eval
+	Endgame Eval
+}
+
+// Get the value of the weight at the phase score of the position
+func (w Weight) interpolate(phaseScore int) Eval {
+	return interpolatePhase(phaseScore, w.Opening, w.Endgame)
+}
+
+// Get the percent of a weight
+func (w Weight) scale(percent int) Weight {
+	return Weight{Eval(int(w.Opening) * percent / 100), Eval(int(w.Endgame) * percent / 100)}
+}
+
+// Get the sum of two weights
+func (w Weight) add(other Weight) Weight {
+	return Weight{w.Opening + other.Opening, w.Endgame + other.Endgame}
+}
+
+// Weights are written as [opening, endgame] pairs, to keep the parameter files readable
+func (w Weight) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]Eval{w.Opening, w.Endgame})
+}
+
+func (w *Weight) UnmarshalJSON(data []byte) error {
+	// Decode into a slice, as an array would silently drop or zero fill values
+	var pair []Eval
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("Invalid weight %s; Should be an [opening, endgame] pair", data)
+	}
+	w.Opening = pair[0]
+	w.Endgame = pair[1]
+	return nil
+}
+
+// All the weights of the evaluation function
+// Penalties are stored as negative weights, so every term is added the same way
+type EvalParams struct {
+	// Material value of each piece
+	Material [NUM_PIECES]Weight `json:"material"`
+
+	// Piece square tables, from white's perspective (index 0 is a1), as an offset from the material value
+	PST [NUM_PIECES][NUM_SQUARES]Weight `json:"pst"`
+
+	// Pawn structure
+	DoubledPawn   Weight `json:"doubledPawn"`
+	IsolatedPawn  Weight `json:"isolatedPawn"`
+	BackwardPawn  Weight `json:"backwardPawn"`
+	ConnectedPawn Weight `json:"connectedPawn"`
+	PassedPawn    Weight `json:"passedPawn"`
+
+	// Extra bonus for a passed pawn by its rank, from the side of the pawn (index 1 is the starting rank)
+	PassedPawnRank [8]Weight `json:"passedPawnRank"`
+
+	// King safety, attack units are added up for each king and looked up in the king safety table
+	// Units for each enemy piece attacking the king zone, by piece
+	KingAttackUnits [NUM_PIECES]int `json:"kingAttackUnits"`
+
+	// Units for each safe check the enemy can give, by the piece giving it
+	SafeCheckUnits [NUM_PIECES]int `json:"safeCheckUnits"`
+
+	// Units for each file around the king without a shield pawn, without any friendly pawns, or with an enemy pawn storming it
+	MissingShieldUnits int `json:"missingShieldUnits"`
+	OpenFileUnits      int `json:"openFileUnits"`
+	PawnStormUnits     int `json:"pawnStormUnits"`
+
+	// The non-linear king safety table, indexed by attack units
+	KingSafety [KING_SAFETY_UNITS]Weight `json:"kingSafety"`
+
+	// Pieces on open files, semi-open files are from the side of the piece
+	// Own semi-open means the file has no friendly pawns, opponent semi-open means it has no enemy pawns
+	RookOpenFile         Weight `json:"rookOpenFile"`
+	RookOwnSemiOpenFile  Weight `json:"rookOwnSemiOpenFile"`
+	RookOppSemiOpenFile  Weight `json:"rookOppSemiOpenFile"`
+	QueenOpenFile        Weight `json:"queenOpenFile"`
+	QueenOwnSemiOpenFile Weight `json:"queenOwnSemiOpenFile"`
+	QueenOppSemiOpenFile Weight `json:"queenOppSemiOpenFile"`
+	KingOpenFile         Weight `json:"kingOpenFile"`
+	KingOwnSemiOpenFile  Weight `json:"kingOwnSemiOpenFile"`
+	KingOppSemiOpenFile  Weight `json:"kingOppSemiOpenFile"`
+
+	// Mobility, by the number of squares each piece attacks that are not taken by friendly pieces or attacked by enemy pawns
+	KnightMobility [9]Weight  `json:"knightMobility"`
+	BishopMobility [14]Weight `json:"bishopMobility"`
+	RookMobility   [15]Weight `json:"rookMobility"`
+	QueenMobility  [28]Weight `json:"queenMobility"`
+
+	// Threats, for each enemy piece attacked by a lower value piece, and for enemy pieces attacked but not defended
+	ThreatByPawn  Weight `json:"threatByPawn"`
+	ThreatByMinor Weight `json:"threatByMinor"`
+	ThreatByRook  Weight `json:"threatByRook"`
+	HangingPiece  Weight `json:"hangingPiece"`
+
+	// Knights and bishops on squares defended by a pawn that enemy pawns can never attack
+	KnightOutpost Weight `json:"knightOutpost"`
+	BishopOutpost Weight `json:"bishopOutpost"`
+
+	// Rooks on the seventh rank, from the side of the rook
+	RookOnSeventh Weight `json:"rookOnSeventh"`
+
+	// Other terms
+	BishopPair Weight `json:"bishopPair"`
+	Tempo      Weight `json:"tempo"`
+
+	// The percent of its material a side is willing to give up, and the percent more its attack on the enemy king is worth
+	// These are set by a personality for the side it plays (see Personality.apply), and are 0 for the engine's parameters
+	sacrificePercent  [NUM_COLORS]int
+	kingAttackPercent [NUM_COLORS]int
+
+	// Material plus piece square tables, for both colors, built from the weights above by build()
+	pst [NUM_COLORS][NUM_PIECES][NUM_SQUARES]Weight
+
+	// The weights of the percents above, built by build(), they are not tuned
+	// The material given up by each side, and the extra king safety of each king from the enemy's attack
+	sacrifice  [NUM_COLORS][NUM_PIECES]Weight
+	kingAttack [NUM_COLORS][KING_SAFETY_UNITS]Weight
+}
+
+// The compiled default parameters, and the parameters the engine evaluates with
+var DEFAULT_EVAL_PARAMS EvalParams
+var EVAL_PARAMS EvalParams
+
+// Parameter file loaded by InitEngine, the compiled defaults are used when empty
+var EVAL_PARAMS_FILE string
+
+// Build the default parameters from the hand-picked values
+func defaultEvalParams() EvalParams {
+	var p EvalParams
+
+	// Material, the king is not given a material value as it can never be traded
+	p.Material[PAWN] = Weight{PAWN_VALUE, PAWN_VALUE}
+	p.Material[KNIGHT] = Weight{KNIGHT_VALUE, KNIGHT_VALUE}
+	p.Material[BISHOP] = Weight{BISHOP_VALUE, BISHOP_VALUE}
+	p.Material[ROOK] = Weight{ROOK_VALUE, ROOK_VALUE}
+	p.Material[QUEEN] = Weight{QUEEN_VALUE, QUEEN_VALUE}
+	p.Material[KING] = Weight{0, 0}
+
+	// The tables in pst.go include the material value, so store them as an offset from it
+	openingTables := [NUM_PIECES][NUM_SQUARES]Eval{pstPawnOpening, pstKnightOpening, pstBishopOpening, pstRookOpening, pstQueenOpening, pstKingOpening}
+	endgameTables := [NUM_PIECES][NUM_SQUARES]Eval{pstPawnEndgame, pstKnightEndgame, pstBishopEndgame, pstRookEndgame, pstQueenEndgame, pstKingEndgame}
+	for piece := range NUM_PIECES {
+		for sq := range NUM_SQUARES {
+			p.PST[piece][sq] = Weight{
+				Opening: openingTables[piece][sq] - p.Material[piece].Opening,
+				Endgame: endgameTables[piece][sq] - p.Material[piece].Endgame,
+			}
+		}
+	}
+
+	// Pawn structure
+	p.DoubledPawn = Weight{-15, -36}
+	p.IsolatedPawn = Weight{-19, -31}
+	p.BackwardPawn = Weight{-8, -10}
+	p.ConnectedPawn = Weight{6, 4}
+	p.PassedPawn = Weight{15, 38}
+	p.PassedPawnRank = [8]Weight{{0, 0}, {0, 0}, {0, 2}, {3, 8}, {8, 20}, {15, 40}, {25, 65}, {0, 0}}
+
+	// King safety, the penalty grows with the square of the attack units
+	p.KingAttackUnits = [NUM_PIECES]int{PAWN: 0, KNIGHT: 2, BISHOP: 2, ROOK: 3, QUEEN: 5, KING: 0}
+	p.SafeCheckUnits = [NUM_PIECES]int{PAWN: 0, KNIGHT: 4, BISHOP: 2, ROOK: 3, QUEEN: 4, KING: 0}
+	p.MissingShieldUnits = 2
+	p.OpenFileUnits = 2
+	p.PawnStormUnits = 1
+	for units := range KING_SAFETY_UNITS {
+		penalty := min(units*units*9/10, 500)
+		p.KingSafety[units] = Weight{Eval(-penalty), Eval(-penalty / 4)}
+	}
+
+	// Open files
+	p.RookOpenFile = Weight{19, 26}
+	p.RookOwnSemiOpenFile = Weight{11, 23}
+	p.RookOppSemiOpenFile = Weight{7, 16}
+	p.QueenOpenFile = Weight{9, 17}
+	p.QueenOwnSemiOpenFile = Weight{7, 14}
+	p.QueenOppSemiOpenFile = Weight{4, 10}
+	p.KingOpenFile = Weight{-32, 0}
+	p.KingOwnSemiOpenFile = Weight{-22, 0}
+	p.KingOppSemiOpenFile = Weight{-17, 0}
+
+	// Mobility, centered so a piece with an average number of squares is worth about nothing
+	fillMobility(p.KnightMobility[:], 4, Weight{4, 4})
+	fillMobility(p.BishopMobility[:], 6, Weight{4, 5})
+	fillMobility(p.RookMobility[:], 7, Weight{2, 4})
+	fillMobility(p.QueenMobility[:], 13, Weight{1, 2})
+
+	// Threats
+	p.ThreatByPawn = Weight{30, 25}
+	p.ThreatByMinor = Weight{20, 20}
+	p.ThreatByRook = Weight{25, 15}
+	p.HangingPiece = Weight{15, 20}
+
+	// Outposts
+	p.KnightOutpost = Weight{20, 10}
+	p.BishopOutpost = Weight{10, 5}
+
+	// Rook on the seventh
+	p.RookOnSeventh = Weight{10, 20}
+
+	// Other terms
+	p.BishopPair = Weight{30, 30}
+	p.Tempo = Weight{10, 10}
+
+	p.build()
+	return p
+}
+
+// Fill a mobility table that grows linearly with the number of squares, 0 at the average number of squares
+func fillMobility(table []Weight, average int, perSquare Weight) {
+	for squares := range table {
+		table[squares] = Weight{
+			Opening: Eval(squares-average) * perSquare.Opening,
+			Endgame: Eval(squares-average) * perSquare.Endgame,
+		}
+	}
+}
+
+// Build the tables derived from the weights, must be called after changing the weights
+func (p *EvalParams) build() {
+	for color := range NUM_COLORS {
+		for piece := range NUM_PIECES {
+			p.sacrifice[color][piece] = p.Material[piece].scale(-p.sacrificePercent[color])
+		}
+		for units := range KING_SAFETY_UNITS {
+			p.kingAttack[color][units] = p.KingSafety[units].scale(p.kingAttackPercent[color^1])
+		}
+	}
+
+	for piece := range NUM_PIECES {
+		for sq := range NUM_SQUARES {
+			w := Weight{
+				Opening: p.Material[piece].Opening + p.PST[piece][sq].Opening,
+				Endgame: p.Material[piece].Endgame + p.PST[piece][sq].Endgame,
+			}
+			p.pst[WHITE][piece][sq] = w.add(p.sacrifice[WHITE][piece])
+
+			// XOR 56 flips the rank (0->7, 1->6, etc)
+			p.pst[BLACK][piece][sq^56] = w.add(p.sacrifice[BLACK][piece])
+		}
+	}
+}
+
+// Get a key of the parameters, so searches with other parameters keep their evals apart in the TT and pawn hash
+// The key is a hash of every field of the parameters as they are saved to a file, the weights and the king safety units,
+// and of the percents of the side a personality plays, which are not saved
+func (p *EvalParams) key() ZobristHash {
+	// The parameters are only numbers, so marshalling them can not fail
+	data, _ := json.Marshal(p)
+	hash := fnv.New64a()
+	hash.Write(data)
+	fmt.Fprint(hash, p.sacrificePercent, p.kingAttackPercent)
+	return ZobristHash(hash.Sum64())
+}
+
+// Names of the pieces, for printing weights
+var PIECE_NAMES = [NUM_PIECES]string{"pawn", "knight", "bishop", "rook", "queen", "king"}
+
+// A weight of the parameter vector, with a name for printing
+type namedWeight struct {
+	name   string
+	weight *Weight
+}
+
+// Get the parameter vector, every weight of the evaluation in a fixed order
+func (p *EvalParams) weights() []namedWeight {
+	weights := make([]namedWeight, 0, 512)
+
+	for piece := range NUM_PIECES {
+		weights = append(weights, namedWeight{fmt.Sprintf("material.%v", PIECE_NAMES[piece]), &p.Material[piece]})
+	}
+	for piece := range NUM_PIECES {
+		for sq := range NUM_SQUARES {
+			weights = append(weights, namedWeight{fmt.Sprintf("pst.%v.%v", PIECE_NAMES[piece], Square(sq).toString()), &p.PST[piece][sq]})
+		}
+	}
+
+	weights = append(weights,
+		namedWeight{"doubledPawn", &p.DoubledPawn},
+		namedWeight{"isolatedPawn", &p.IsolatedPawn},
+		namedWeight{"backwardPawn", &p.BackwardPawn},
+		namedWeight{"connectedPawn", &p.ConnectedPawn},
+		namedWeight{"passedPawn", &p.PassedPawn},
+	)
+	for rank := range 8 {
+		weights = append(weights, namedWeight{fmt.Sprintf("passedPawnRank.%d", rank+1), &p.PassedPawnRank[rank]})
+	}
+	weights = append(weights,
+		namedWeight{"rookOpenFile", &p.RookOpenFile},
+		namedWeight{"rookOwnSemiOpenFile", &p.RookOwnSemiOpenFile},
+		namedWeight{"rookOppSemiOpenFile", &p.RookOppSemiOpenFile},
+		namedWeight{"queenOpenFile", &p.QueenOpenFile},
+		namedWeight{"queenOwnSemiOpenFile", &p.QueenOwnSemiOpenFile},
+		namedWeight{"queenOppSemiOpenFile", &p.QueenOppSemiOpenFile},
+		namedWeight{"kingOpenFile", &p.KingOpenFile},
+		namedWeight{"kingOwnSemiOpenFile", &p.KingOwnSemiOpenFile},
+		namedWeight{"kingOppSemiOpenFile", &p.KingOppSemiOpenFile},
+		namedWeight{"threatByPawn", &p.ThreatByPawn},
+		namedWeight{"threatByMinor", &p.ThreatByMinor},
+		namedWeight{"threatByRook", &p.ThreatByRook},
+		namedWeight{"hangingPiece", &p.HangingPiece},
+		namedWeight{"knightOutpost", &p.KnightOutpost},
+		namedWeight{"bishopOutpost", &p.BishopOutpost},
+		namedWeight{"rookOnSeventh", &p.RookOnSeventh},
+		namedWeight{"bishopPair", &p.BishopPair},
+		namedWeight{"tempo", &p.Tempo},
+	)
+
+	// Tables indexed by a count (of squares or attack units)
+	tables := []struct {
+		name  string
+		table []Weight
+	}{
+		{"knightMobility", p.KnightMobility[:]},
+		{"bishopMobility", p.BishopMobility[:]},
+		{"rookMobility", p.RookMobility[:]},
+		{"queenMobility", p.QueenMobility[:]},
+		{"kingSafety", p.KingSafety[:]},
+	}
+	for _, t := range tables {
+		for count := range t.table {
+			weights = append(weights, namedWeight{fmt.Sprintf("%v.%d", t.name, count), &t.table[count]})
+		}
+	}
+
+	return weights
+}
+
+// The size of each table of weights in a parameter file (other than the pst)
+var EVAL_PARAMS_TABLE_SIZES = map[string]int{
+	"material":        int(NUM_PIECES),
+	"passedPawnRank":  8,
+	"knightMobility":  9,
+	"bishopMobility":  14,
+	"rookMobility":    15,
+	"queenMobility":   28,
+	"kingAttackUnits": int(NUM_PIECES),
+	"safeCheckUnits":  int(NUM_PIECES),
+	"kingSafety":      KING_SAFETY_UNITS,
+}
+
+// Load a JSON parameter file, as written by SaveEvalParams
+// Weights missing from the file keep their default value, so a file can change just a few terms
+func LoadEvalParams(path string) (*EvalParams, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the size of the tables first, JSON arrays are silently truncated or zero filled otherwise
+	var tables map[string]json.RawMessage
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("Invalid parameter file %v: %w", path, err)
+	}
+	for name, size := range EVAL_PARAMS_TABLE_SIZES {
+		raw, ok := tables[name]
+		if !ok {
+			continue
+		}
+		var table []json.RawMessage
+		if err := json.Unmarshal(raw, &table); err != nil {
+			return nil, fmt.Errorf("Invalid parameter file %v; %v should be a list: %w", path, name, err)
+		}
+		if len(table) != size {
+			return nil, fmt.Errorf("Invalid parameter file %v; %v should have %d weights, has %d", path, name, size, len(table))
+		}
+	}
+	if raw, ok := tables["pst"]; ok {
+		var pst [][]json.RawMessage
+		if err := json.Unmarshal(raw, &pst); err != nil {
+			return nil, fmt.Errorf("Invalid parameter file %v; pst should be a list of tables: %w", path, err)
+		}
+		if len(pst) != int(NUM_PIECES) {
+			return nil, fmt.Errorf("Invalid parameter file %v; pst should have %d tables, has %d", path, NUM_PIECES, len(pst))
+		}
+		for piece, table := range pst {
+			if len(table) != NUM_SQUARES {
+				return nil, fmt.Errorf("Invalid parameter file %v; pst table of the %v should have %d weights, has %d", path, PIECE_NAMES[piece], NUM_SQUARES, len(table))
+			}
+		}
+	}
+
+	// Decode over the defaults, rejecting misspelt weight names
+	params := defaultEvalParams()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return nil, fmt.Errorf("Invalid parameter file %v: %w", path, err)
+	}
+
+	// Pieces must be worth something, or the evaluation is nonsense
+	for piece := PAWN; piece < KING; piece++ {
+		if params.Material[piece].Opening <= 0 || params.Material[piece].Endgame <= 0 {
+			return nil, fmt.Errorf("Invalid parameter file %v; material of the %v should be positive", path, PIECE_NAMES[piece])
+		}
+	}
+
+	params.build()
+	return &params, nil
+}
+
+// Set the parameters the engine evaluates with, from the parameter file if there is one
+func initEvalParams() {
+	DEFAULT_EVAL_PARAMS = defaultEvalParams()
+	EVAL_PARAMS = DEFAULT_EVAL_PARAMS
+	clearPawnHash()
+	if EVAL_PARAMS_FILE == "" {
+		return
+	}
+
+	params, err := LoadEvalParams(EVAL_PARAMS_FILE)
+	if err != nil {
+		fmt.Printf("Failed to load the evaluation parameters, using the defaults: %v\n", err)
+		return
+	}
+	EVAL_PARAMS = *params
+}
+
+// Used to put each [opening, endgame] pair back on one line after indenting
+var weightPairRegex = regexp.MustCompile(`\[\s*(-?\d+),\s*(-?\d+)\s*\]`)
+
+// Save the parameters as a JSON parameter file
+func SaveEvalParams(path string, p *EvalParams) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = weightPairRegex.ReplaceAll(data, []byte("[$1, $2]"))
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadEvalParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.json")
+
+	// Change a few weights, and check they survive a round trip
+	params := defaultEvalParams()
+	params.Material[KNIGHT] = Weight{310, 290}
+	params.PST[KING][6] = Weight{42, -7}
+	params.Tempo = Weight{12, 4}
+	params.build()
+	if err := SaveEvalParams(path, &params); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	loaded, err := LoadEvalParams(path)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if *loaded != params {
+		t.Errorf("Loaded parameters differ from the saved parameters")
+	}
+}
+
+func TestLoadEvalParamsValidation(t *testing.T) {
+	// Tests setup to be run
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{
+			name: "Partial file keeps the defaults",
+			file: `{"tempo": [20, 5]}`,
+		},
+		{
+			name: "Material table too short",
+			file: `{"material": [[100, 100], [300, 300]]}`,
+			err:  "material should have 6 weights",
+		},
+		{
+			name: "PST table too short",
+			file: `{"pst": [[], [], [], [], [], []]}`,
+			err:  "pst table of the pawn should have 64 weights",
+		},
+		{
+			name: "Weight is not a pair",
+			file: `{"tempo": [20, 5, 1]}`,
+			err:  "Should be an [opening, endgame] pair",
+		},
+		{
+			name: "Unknown weight",
+			file: `{"tempoo": [20, 5]}`,
+			err:  "unknown field",
+		},
+		{
+			name: "Piece worth nothing",
+			file: `{"material": [[100, 100], [0, 0], [300, 300], [500, 500], [900, 900], [0, 0]]}`,
+			err:  "material of the knight should be positive",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "params.json")
+			if err := os.WriteFile(path, []byte(tc.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			params, err := LoadEvalParams(path)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				defaults := defaultEvalParams()
+				if params.Tempo != (Weight{20, 5}) || params.Material != defaults.Material || params.pst != defaults.pst {
+					t.Errorf("Expected only the tempo to change")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestEvalParamsKey(t *testing.T) {
+	// Tests setup to be run, every change of a parameter should change the key
+	tests := []struct {
+		name   string
+		change func(p *EvalParams)
+	}{
+		{name: "Material", change: func(p *EvalParams) { p.Material[KNIGHT].Opening++ }},
+		{name: "PST", change: func(p *EvalParams) { p.PST[KING][6].Endgame-- }},
+		{name: "King safety table", change: func(p *EvalParams) { p.KingSafety[10].Opening++ }},
+		{name: "King attack units", change: func(p *EvalParams) { p.KingAttackUnits[QUEEN]++ }},
+		{name: "Safe check units", change: func(p *EvalParams) { p.SafeCheckUnits[KNIGHT]++ }},
+		{name: "Missing shield units", change: func(p *EvalParams) { p.MissingShieldUnits++ }},
+		{name: "Open file units", change: func(p *EvalParams) { p.OpenFileUnits++ }},
+		{name: "Pawn storm units", change: func(p *EvalParams) { p.PawnStormUnits++ }},
+	}
+
+	defaults := defaultEvalParams()
+	if other := defaultEvalParams(); other.key() != defaults.key() {
+		t.Fatalf("Expected the same parameters to have the same key")
+	}
+	for _, tc := range tests {
+		params := defaultEvalParams()
+		tc.change(&params)
+		if params.key() == defaults.key() {
+			t.Errorf("%v: Expected the key to change with the parameter", tc.name)
+		}
+	}
+}
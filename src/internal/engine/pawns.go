@@ -0,0 +1,156 @@
+package engine
+
+import "math/bits"
+
+/*
+This file holds the pawn structure evaluation and the pawn hash table.
+Pawns move rarely during the search, so the pawn evaluation (and the king shields) is cached
+in a hash table keyed by a Zobrist hash of just the pawns and the king squares.
+The cached value is the opening and endgame sums, as the phase can change without the pawns changing.
+*/
+
+// Pawn hash entry
+type PawnHashEntry struct {
+	key  ZobristHash
+	eval Weight
+
+	// King safety attack units from the pawns around each king
+	shieldUnits [NUM_COLORS]int
+}
+
+// Size of the pawn hash table, the memory will be PAWN_HASH_SIZE * sizeof(PawnHashEntry)
+const PAWN_HASH_SIZE = 1 << 16
+
+var PawnHash []PawnHashEntry
+
+func initPawnHash() {
+	PawnHash = make([]PawnHashEntry, PAWN_HASH_SIZE)
+}
+
+// Clear the pawn hash, needed whenever the evaluation parameters change
+func clearPawnHash() {
+	for i := range PawnHash {
+		PawnHash[i] = PawnHashEntry{}
+	}
+}
+
+// Evaluate the pawn structure, using the pawn hash when not tracing
+// Also returns the king safety attack units from the pawns around each king
+func (b *Board) pawnEval(phaseScore int, trace evalTrace) (Eval, [NUM_COLORS]int) {
+	if trace != nil {
+		return b.computePawnEval(trace).interpolate(phaseScore), b.shieldUnits()
+	}
+
+	// The king squares are part of the key, as the pawns around the kings are evaluated here too
+	// The key of the parameters keeps the evals of other parameters apart
+	key := b.EvalState.PawnKey ^ PIECE_ZOBRIST[WHITE][KING][b.KingSquare[WHITE]] ^ PIECE_ZOBRIST[BLACK][KING][b.KingSquare[BLACK]] ^ b.paramsKey
+	entry := &PawnHash[key&(PAWN_HASH_SIZE-1)]
+	if entry.key != key {
+		entry.eval = b.computePawnEval(nil)
+		entry.shieldUnits = b.shieldUnits()
+		entry.key = key
+	}
+
+	return entry.eval.interpolate(phaseScore), entry.shieldUnits
+}
+
+// Add a weight count times to the opening and endgame sums, from white's perspective
+func addWeight(total *Weight, w *Weight, color Color, count int, trace evalTrace) {
+	if trace != nil {
+		trace.add(w, color, count)
+	}
+	if color == BLACK {
+		count = -count
+	}
+	total.Opening += Eval(count) * w.Opening
+	total.Endgame += Eval(count) * w.Endgame
+}
+
+// Get the squares attacked by pawns
+func pawnAttacks(pawns BitBoard, color Color) BitBoard {
+	if color == WHITE {
+		return ((pawns &^ FileMask[0]) << 7) | ((pawns &^ FileMask[7]) << 9)
+	}
+	return ((pawns &^ FileMask[0]) >> 9) | ((pawns &^ FileMask[7]) >> 7)
+}
+
+// Compute the pawn structure evaluation from scratch, as opening and endgame sums
+func (b *Board) computePawnEval(trace evalTrace) Weight {
+	params := b.evalParams()
+	var total Weight
+
+	for color := WHITE; color <= BLACK; color++ {
+		pawns := b.Pieces[color][PAWN]
+		enemyPawns := b.Pieces[color^1][PAWN]
+		attacks := pawnAttacks(pawns, color)
+		enemyAttacks := pawnAttacks(enemyPawns, color^1)
+
+		// Doubled pawns
+		// Each doubled pawn is a penalty that increases as the game moves towards the engame
+		addWeight(&total, &params.DoubledPawn, color, doubledPawns(pawns), trace)
+
+		isolated, backward, connected := 0, 0, 0
+		remaining := pawns
+		for remaining != 0 {
+			sq := remaining.popSquare()
+			file := int(sq) % 8
+			rank := int(sq) / 8
+
+			// Isolated pawns have no friendly pawns on the files next to them
+			if pawns&AdjacentFileMask[file] == 0 {
+				isolated++
+			}
+
+			// Connected pawns are defended by a pawn, or stand next to one
+			phalanx := pawns & AdjacentFileMask[file] & (BitBoard(0xFF) << (rank * 8))
+			if attacks&sq.bitBoardPosition() != 0 || phalanx != 0 {
+				connected++
+			}
+
+			// Passed pawns have no enemy pawns in front of them, on their file or the files next to them
+			// The bonus increases as the pawn moves up the board
+			passedMask := WhitePassedMask[sq]
+			stop := sq + 8
+			relativeRank := rank
+			if color == BLACK {
+				passedMask = BlackPassedMask[sq]
+				stop = sq - 8
+				relativeRank = 7 - rank
+			}
+			if passedMask&enemyPawns == 0 {
+				addWeight(&total, &params.PassedPawn, color, 1, trace)
+				addWeight(&total, &params.PassedPawnRank[relativeRank], color, 1, trace)
+				continue
+			}
+
+			// Backward pawns can not be defended by a pawn as none are level or behind on the files next to them,
+			// and can not advance safely as the square in front is attacked by an enemy pawn
+			behind := BlackPassedMask[sq]
+			if color == BLACK {
+				behind = WhitePassedMask[sq]
+			}
+			supporters := pawns & AdjacentFileMask[file] & (behind | (BitBoard(0xFF) << (rank * 8)))
+			if supporters == 0 && pawns&AdjacentFileMask[file] != 0 && enemyAttacks&stop.bitBoardPosition() != 0 {
+				backward++
+			}
+		}
+		addWeight(&total, &params.IsolatedPawn, color, isolated, trace)
+		addWeight(&total, &params.BackwardPawn, color, backward, trace)
+		addWeight(&total, &params.ConnectedPawn, color, connected, trace)
+	}
+
+	return total
+}
+
+// Function to get doubled pawns
+func doubledPawns(pawns BitBoard) int {
+	doubled := 0
+
+	for file := range 8 {
+		onFile := pawns & FileMask[file]
+		if bits.OnesCount64(uint64(onFile)) >= 2 {
+			doubled++
+		}
+	}
+	return doubled
+}
@@ -0,0 +1,76 @@
+package engine
+
+import "testing"
+
+func TestPawnStructureTerms(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected counts of each term for [white, black]
+	tests := []struct {
+		name      string
+		position  FEN
+		isolated  [NUM_COLORS]int
+		backward  [NUM_COLORS]int
+		connected [NUM_COLORS]int
+		passed    [NUM_COLORS]int
+	}{
+		{
+			name:     "Isolated passed pawns",
+			position: "4k3/8/8/8/8/8/P1P5/4K3 w - - 0 1",
+			isolated: [NUM_COLORS]int{2, 0},
+			passed:   [NUM_COLORS]int{2, 0},
+		},
+		{
+			name:      "Backward pawn behind a defended passer",
+			position:  "4k3/8/8/4p3/2P5/3P4/8/4K3 w - - 0 1",
+			isolated:  [NUM_COLORS]int{0, 1},
+			backward:  [NUM_COLORS]int{1, 0},
+			connected: [NUM_COLORS]int{1, 0},
+			passed:    [NUM_COLORS]int{1, 0},
+		},
+		{
+			name:      "Starting position",
+			position:  STARTING_POSITION_FEN,
+			connected: [NUM_COLORS]int{8, 8},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+
+			trace := evalTrace{}
+			board.computePawnEval(trace)
+			count := func(w *Weight) [NUM_COLORS]int {
+				if counts, ok := trace[w]; ok {
+					return *counts
+				}
+				return [NUM_COLORS]int{}
+			}
+
+			params := &EVAL_PARAMS
+			if got := count(&params.IsolatedPawn); got != tc.isolated {
+				t.Errorf("Isolated pawns: expected %v, got %v", tc.isolated, got)
+			}
+			if got := count(&params.BackwardPawn); got != tc.backward {
+				t.Errorf("Backward pawns: expected %v, got %v", tc.backward, got)
+			}
+			if got := count(&params.ConnectedPawn); got != tc.connected {
+				t.Errorf("Connected pawns: expected %v, got %v", tc.connected, got)
+			}
+			if got := count(&params.PassedPawn); got != tc.passed {
+				t.Errorf("Passed pawns: expected %v, got %v", tc.passed, got)
+			}
+
+			// The cached eval must match the traced eval
+			phaseScore := board.getPhaseScore()
+			traced, _ := board.pawnEval(phaseScore, evalTrace{})
+			if cached, _ := board.pawnEval(phaseScore, nil); cached != traced {
+				t.Errorf("Cached pawn eval %d does not match the traced eval %d", cached, traced)
+			}
+		})
+	}
+}
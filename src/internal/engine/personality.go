@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/*
+This file holds the personalities of the engine, playing styles for variety when playing against it.
+A personality is an overlay on the evaluation parameters: it scales groups of evaluation terms up or down,
+like caring more about mobility to play actively, or about the pawn structure to play positionally.
+The scaled terms are valued for both sides, so a personality also has terms for only the side it plays:
+its attack on the enemy king can be worth more, without it defending its own king more, and its own material
+can be worth less, so it gives it up for play. The parameters are built for the side the engine searches (see searchParams).
+*/
+
+// A playing style, with the scales of the groups of evaluation terms in percent (groups not listed keep 100)
+// The contempt of the personality is added to the contempt of the search options
+type Personality struct {
+	Description string
+	Scales      map[string]int
+	Contempt    int
+
+	// How much more the attack on the enemy king is worth to the side the personality plays, in percent
+	KingAttack int
+
+	// How much less the material of the side the personality plays is worth, in percent
+	Sacrifice int
+}
+
+// The groups of evaluation terms a personality can scale, by the prefixes of the names of their weights
+var PERSONALITY_GROUPS = map[string][]string{
+	"material":      {"material.knight", "material.bishop", "material.rook", "material.queen"},
+	"pawns":         {"material.pawn"},
+	"pawnStructure": {"doubledPawn", "isolatedPawn", "backwardPawn", "connectedPawn", "passedPawn"},
+	"kingSafety":    {"kingSafety."},
+	"mobility":      {"knightMobility.", "bishopMobility.", "rookMobility.", "queenMobility."},
+	"activity":      {"rookOpenFile", "rookOwnSemiOpenFile", "rookOppSemiOpenFile", "queenOpenFile", "queenOwnSemiOpenFile", "queenOppSemiOpenFile", "threatBy", "hangingPiece", "knightOutpost", "bishopOutpost", "rookOnSeventh"},
+}
+
+// The personalities, by name
+var PERSONALITIES = map[string]Personality{
+	"aggressive": {
+		Description: "Attacks the king, and values active pieces over a sound pawn structure",
+		Scales:      map[string]int{"mobility": 120, "activity": 120, "pawnStructure": 80},
+		Contempt:    30,
+		KingAttack:  50,
+	},
+	"positional": {
+		Description: "Plays for a good pawn structure, outposts and open files",
+		Scales:      map[string]int{"pawnStructure": 150, "activity": 130, "mobility": 110, "kingSafety": 90},
+		Contempt:    10,
+	},
+	"materialistic": {
+		Description: "Grabs material, and only gives it back for a clear gain",
+		Scales:      map[string]int{"material": 115, "pawns": 120, "mobility": 80, "activity": 80, "kingSafety": 80},
+	},
+	"gambiteer": {
+		Description: "Gives up pawns and even pieces for development and an attack",
+		Scales:      map[string]int{"mobility": 140, "activity": 120, "pawnStructure": 70},
+		Contempt:    50,
+		KingAttack:  30,
+		Sacrifice:   20,
+	},
+}
+
+// The names of the personalities, in order
+func PersonalityNames() []string {
+	names := make([]string, 0, len(PERSONALITIES))
+	for name := range PERSONALITIES {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Get a personality by its name
+func ParsePersonality(name string) (Personality, error) {
+	personality, ok := PERSONALITIES[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Personality{}, fmt.Errorf("Invalid personality %q; Should be one of %v", name, strings.Join(PersonalityNames(), ", "))
+	}
+	return personality, nil
+}
+
+// Get the parameters of the personality playing a side, scaling the terms of the parameters it is applied over
+func (personality Personality) apply(params EvalParams, color Color) EvalParams {
+	for _, w := range params.weights() {
+		scale := personality.scale(w.name)
+		if scale == 100 {
+			continue
+		}
+		*w.weight = w.weight.scale(scale)
+	}
+	params.kingAttackPercent[color] = personality.KingAttack
+	params.sacrificePercent[color] = personality.Sacrifice
+
+	params.build()
+	return params
+}
+
+// The scale of a weight, by the group its name is in
+func (personality Personality) scale(name string) int {
+	for group, scale := range personality.Scales {
+		for _, prefix := range PERSONALITY_GROUPS[group] {
+			if strings.HasPrefix(name, prefix) {
+				return scale
+			}
+		}
+	}
+	return 100
+}
+
+// Get the parameters to search with for the side searching and their key
+// The engine's parameters have a key of 0 so they share the TT between searches
+func (o SearchOptions) searchParams(color Color) (*EvalParams, ZobristHash) {
+	if o.evalParams == nil && o.Personality == "" {
+		return &EVAL_PARAMS, 0
+	}
+	params := o.searchEvalParams(EVAL_PARAMS, color)
+	return &params, params.key()
+}
+
+// Get the parameters to search with, the parameters of the options (or the engine's) with the personality of the options over them
+func (o SearchOptions) searchEvalParams(engineParams EvalParams, color Color) EvalParams {
+	params := engineParams
+	if o.evalParams != nil {
+		params = *o.evalParams
+	}
+	if o.Personality != "" {
+		params = PERSONALITIES[o.Personality].apply(params, color)
+	}
+	return params
+}
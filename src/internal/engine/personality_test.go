@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPersonality(t *testing.T) {
+	InitEngine()
+
+	// Every group scaled by a personality has to exist, and every prefix of a group has to match a weight
+	names := DEFAULT_EVAL_PARAMS.weights()
+	for group, prefixes := range PERSONALITY_GROUPS {
+		for _, prefix := range prefixes {
+			found := false
+			for _, w := range names {
+				found = found || strings.HasPrefix(w.name, prefix)
+			}
+			if !found {
+				t.Errorf("Group %v: Expected %v to match a weight", group, prefix)
+			}
+		}
+	}
+	for name, personality := range PERSONALITIES {
+		for group := range personality.Scales {
+			if _, ok := PERSONALITY_GROUPS[group]; !ok {
+				t.Errorf("Personality %v: Expected the group %v to exist", name, group)
+			}
+		}
+	}
+
+	// Tests setup to be run, with a weight of the personality's parameters and its expected value as a percent of the default
+	defaults := DEFAULT_EVAL_PARAMS
+	tests := []struct {
+		personality string
+		weight      func(p *EvalParams) Weight
+		percent     int
+	}{
+		{personality: "aggressive", weight: func(p *EvalParams) Weight { return p.KingSafety[20] }, percent: 100},
+		{personality: "aggressive", weight: func(p *EvalParams) Weight { return p.KnightMobility[8] }, percent: 120},
+		{personality: "aggressive", weight: func(p *EvalParams) Weight { return p.Material[QUEEN] }, percent: 100},
+		{personality: "positional", weight: func(p *EvalParams) Weight { return p.PassedPawnRank[6] }, percent: 150},
+		{personality: "positional", weight: func(p *EvalParams) Weight { return p.KnightOutpost }, percent: 130},
+		{personality: "materialistic", weight: func(p *EvalParams) Weight { return p.Material[KNIGHT] }, percent: 115},
+		{personality: "materialistic", weight: func(p *EvalParams) Weight { return p.pst[BLACK][KNIGHT][G8] }, percent: -1},
+		{personality: "gambiteer", weight: func(p *EvalParams) Weight { return p.Material[PAWN] }, percent: 100},
+		{personality: "gambiteer", weight: func(p *EvalParams) Weight { return p.Tempo }, percent: 100},
+	}
+
+	for _, tc := range tests {
+		personality, err := ParsePersonality(tc.personality)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		params := personality.apply(defaults, WHITE)
+		got := tc.weight(&params)
+
+		// The combined material and pst tables are rebuilt from the scaled weights
+		expected := tc.weight(&defaults)
+		if tc.percent < 0 {
+			expected = Weight{params.Material[KNIGHT].Opening + params.PST[KNIGHT][G1].Opening, params.Material[KNIGHT].Endgame + params.PST[KNIGHT][G1].Endgame}
+		} else {
+			expected = Weight{Eval(int(expected.Opening) * tc.percent / 100), Eval(int(expected.Endgame) * tc.percent / 100)}
+		}
+		if got != expected {
+			t.Errorf("%v: Expected %v, got %v", tc.personality, expected, got)
+		}
+	}
+
+	// The personality's own side is played differently than the other side
+	tests2 := []struct {
+		personality string
+		color       Color
+		weight      func(p *EvalParams) Weight
+		expected    func(p *EvalParams) Weight
+	}{
+		// The enemy king is attacked more, the king of the personality's side is not defended more
+		{personality: "aggressive", color: WHITE, weight: func(p *EvalParams) Weight { return p.kingAttack[BLACK][20] }, expected: func(p *EvalParams) Weight { return p.KingSafety[20].scale(50) }},
+		{personality: "aggressive", color: WHITE, weight: func(p *EvalParams) Weight { return p.kingAttack[WHITE][20] }, expected: func(p *EvalParams) Weight { return Weight{} }},
+		{personality: "aggressive", color: BLACK, weight: func(p *EvalParams) Weight { return p.kingAttack[WHITE][20] }, expected: func(p *EvalParams) Weight { return p.KingSafety[20].scale(50) }},
+		{personality: "aggressive", color: BLACK, weight: func(p *EvalParams) Weight { return p.kingAttack[BLACK][20] }, expected: func(p *EvalParams) Weight { return Weight{} }},
+
+		// The material of the personality's side is worth less, the other side's is worth the same
+		{personality: "gambiteer", color: WHITE, weight: func(p *EvalParams) Weight { return p.pst[WHITE][KNIGHT][G1] }, expected: func(p *EvalParams) Weight {
+			return p.Material[KNIGHT].scale(-20).add(p.Material[KNIGHT]).add(p.PST[KNIGHT][G1])
+		}},
+		{personality: "gambiteer", color: WHITE, weight: func(p *EvalParams) Weight { return p.pst[BLACK][KNIGHT][G8] }, expected: func(p *EvalParams) Weight {
+			return p.Material[KNIGHT].add(p.PST[KNIGHT][G1])
+		}},
+		{personality: "gambiteer", color: BLACK, weight: func(p *EvalParams) Weight { return p.pst[BLACK][PAWN][E7] }, expected: func(p *EvalParams) Weight {
+			return p.Material[PAWN].scale(-20).add(p.Material[PAWN]).add(p.PST[PAWN][E2])
+		}},
+	}
+	for _, tc := range tests2 {
+		personality, _ := ParsePersonality(tc.personality)
+		params := personality.apply(defaults, tc.color)
+		if got, expected := tc.weight(&params), tc.expected(&params); got != expected {
+			t.Errorf("%v playing %d: Expected %v, got %v", tc.personality, tc.color, expected, got)
+		}
+	}
+
+	// An aggressive white likes an attack on the black king more than the engine does
+	attacked, err := FEN("r1bq1rk1/ppp2ppp/2n5/3p2NQ/3P4/2PB4/PP3PPP/R3K2R w KQ - 0 1").toBoard(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	aggressive, _ := ParsePersonality("aggressive")
+	whiteParams := aggressive.apply(defaults, WHITE)
+	symmetricParams := whiteParams
+	symmetricParams.kingAttackPercent = [NUM_COLORS]int{}
+	symmetricParams.build()
+	eval := func(params *EvalParams) Eval {
+		attacked.params, attacked.paramsKey = params, params.key()
+		attacked.initEvalState()
+		return attacked.evaluateTerms(nil)
+	}
+	if eval(&whiteParams) <= eval(&symmetricParams) {
+		t.Errorf("Expected the attack on the black king to be worth more to an aggressive white")
+	}
+
+	// The defaults are not changed by a personality
+	if DEFAULT_EVAL_PARAMS.Material != defaults.Material || DEFAULT_EVAL_PARAMS.KingSafety != defaults.KingSafety {
+		t.Errorf("Expected the default parameters to be unchanged")
+	}
+
+	// Personalities are picked by name in the search options
+	options, err := ParseSearchOptions("depth=3, personality=Gambiteer")
+	if err != nil || options.Personality != "gambiteer" || options.String() != "depth=3,personality=gambiteer" {
+		t.Errorf("Expected the gambiteer personality, got %v (error: %v)", options, err)
+	}
+	if _, err := ParseSearchOptions("personality=reckless"); err == nil {
+		t.Errorf("Expected an error for an unknown personality")
+	}
+
+	// A personality searches with its own parameters, the engine's parameters and TT entries are left alone
+	InitEngine()
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	options = DEFAULT_SEARCH_OPTIONS
+	options.Depth = 3
+	board.search(1, options)
+	entry := probeTT(board.Zobrist)
+	if entry == nil {
+		t.Fatalf("Expected a TT entry for the root")
+	}
+	saved := *entry
+
+	engineParams := EVAL_PARAMS
+	options.Personality = "aggressive"
+	board.search(1, options)
+	if EVAL_PARAMS.KingSafety != engineParams.KingSafety {
+		t.Errorf("Expected the engine's parameters to be unchanged by a personality search")
+	}
+	if entry := probeTT(board.Zobrist); entry == nil || *entry != saved {
+		t.Errorf("Expected the engine's TT entry for the root to be kept, got %+v", entry)
+	}
+}
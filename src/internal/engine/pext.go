@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+/*
+This file holds the PEXT backend of the sliding piece attacks, an alternative to the magic bitboards.
+PEXT (parallel bit extract, BMI2) gathers the occupancy bits under the mask of a square into a dense index, so the
+tables need no magic numbers and have no collisions. Whether it is faster than the magic multiply and shift depends on
+the CPU: PEXT is a single cycle on Intel since Haswell and AMD since Zen 3, but microcoded and very slow on Zen 1 and 2.
+Go has no PEXT intrinsic either, so every lookup is a call to assembly that can not be inlined, where the magic lookup is.
+The magics stay the default and the fallback, compare both on the CPU with: go run ./cmd/engine -action attacks
+*/
+
+// The sliding piece attack backends
+type AttackBackend string
+
+const (
+	ATTACKS_MAGIC AttackBackend = "magic"
+	ATTACKS_PEXT  AttackBackend = "pext"
+)
+
+// Backend selected by InitEngine, the magics are used when empty
+var ATTACK_BACKEND AttackBackend
+
+// Whether the attacks are looked up in the PEXT tables, read by getRookAttacks and getBishopAttacks
+var USE_PEXT bool
+
+// The perft depth of the bench positions when comparing backends, single threaded without a hash table to time move generation
+const ATTACKS_PERFT_DEPTH uint8 = 4
+
+// The perft and bench of the engine with one backend
+type AttackBackendResult struct {
+	Backend       AttackBackend
+	PerftNodes    int
+	PerftDuration time.Duration
+	Bench         BenchResult
+}
+
+// PEXT lookup tables, indexed by the occupancy bits under the mask of the square
+var PEXT_ROOK_MOVES [NUM_SQUARES][4096]BitBoard
+var PEXT_BISHOP_MOVES [NUM_SQUARES][512]BitBoard
+
+// Set the backend of ATTACK_BACKEND, falling back to the magics if it can not be used
+func initAttackBackend() {
+	USE_PEXT = false
+	if ATTACK_BACKEND == "" {
+		return
+	}
+
+	if err := SetAttackBackend(ATTACK_BACKEND); err != nil {
+		fmt.Printf("Failed to set the attack backend, using the magics: %v\n", err)
+	}
+}
+
+/*
+SetAttackBackend switches the sliding piece attacks to the backend, building the PEXT tables the first time they are used.
+The magic tables have to be initialized first, the PEXT tables are built from them.
+Returns an error if the backend is unknown, or is PEXT on a CPU without BMI2.
+*/
+func SetAttackBackend(backend AttackBackend) error {
+	switch backend {
+	case ATTACKS_MAGIC:
+		USE_PEXT = false
+	case ATTACKS_PEXT:
+		if !HAS_PEXT {
+			return fmt.Errorf("Invalid attack backend %v; Should be %v on a CPU without BMI2", backend, ATTACKS_MAGIC)
+		}
+		if PEXT_ROOK_MOVES[0][0] == 0 {
+			initPext()
+		}
+		USE_PEXT = true
+	default:
+		return fmt.Errorf("Invalid attack backend %q; Should be %v or %v", backend, ATTACKS_MAGIC, ATTACKS_PEXT)
+	}
+	return nil
+}
+
+// Look up the rook attacks in the PEXT tables, kept out of getRookAttacks so the magic lookup still inlines
+func getPextRookAttacks(sq Square, occupancy BitBoard) BitBoard {
+	return PEXT_ROOK_MOVES[sq][pext(uint64(occupancy), uint64(MAGIC_ROOK_INFO[sq].mask))]
+}
+
+// Look up the bishop attacks in the PEXT tables
+func getPextBishopAttacks(sq Square, occupancy BitBoard) BitBoard {
+	return PEXT_BISHOP_MOVES[sq][pext(uint64(occupancy), uint64(MAGIC_BISHOP_INFO[sq].mask))]
+}
+
+// Fill the PEXT tables from the magic tables, every occupancy of the mask gives the same attacks either way
+func initPext() {
+	for sq := range Square(NUM_SQUARES) {
+		mask := MAGIC_ROOK_INFO[sq].mask
+		numBits := bits.OnesCount64(uint64(mask))
+		for j := range 1 << numBits {
+			occupancy := SetMaskOccupancy(j, numBits, mask)
+			PEXT_ROOK_MOVES[sq][pext(uint64(occupancy), uint64(mask))] = MAGIC_ROOK_MOVES[sq][MAGIC_ROOK_INFO[sq].getMagicIndex(occupancy)]
+		}
+
+		mask = MAGIC_BISHOP_INFO[sq].mask
+		numBits = bits.OnesCount64(uint64(mask))
+		for j := range 1 << numBits {
+			occupancy := SetMaskOccupancy(j, numBits, mask)
+			PEXT_BISHOP_MOVES[sq][pext(uint64(occupancy), uint64(mask))] = MAGIC_BISHOP_MOVES[sq][MAGIC_BISHOP_INFO[sq].getMagicIndex(occupancy)]
+		}
+	}
+}
+
+// PEXT one bit at a time, for CPUs without the instruction and to check it
+func softwarePext(src, mask uint64) uint64 {
+	result := uint64(0)
+	for bit := uint64(1); mask != 0; bit <<= 1 {
+		if src&mask&-mask != 0 {
+			result |= bit
+		}
+		mask &= mask - 1
+	}
+	return result
+}
+
+/*
+CompareAttackBackends runs a perft of the bench positions and the bench with every backend the CPU supports, magics first.
+The perft counts have to be the same with every backend, the speeds are compared with the nodes per second.
+The engine has to be initialized first, the backend it was using is restored after.
+*/
+func CompareAttackBackends(benchDepth uint8) ([]AttackBackendResult, error) {
+	backends := []AttackBackend{ATTACKS_MAGIC}
+	if HAS_PEXT {
+		backends = append(backends, ATTACKS_PEXT)
+	}
+
+	wasPext := USE_PEXT
+	defer func() { USE_PEXT = wasPext }()
+
+	// The first search of the process is slower (page faults of the TT and the tables), so it is not timed
+	Bench(benchDepth)
+
+	results := make([]AttackBackendResult, 0, len(backends))
+	for _, backend := range backends {
+		if err := SetAttackBackend(backend); err != nil {
+			return results, err
+		}
+
+		result := AttackBackendResult{Backend: backend}
+		for _, position := range BENCH_POSITIONS {
+			perft, err := RunPerft(position, PerftOptions{Depth: ATTACKS_PERFT_DEPTH, Threads: 1})
+			if err != nil {
+				return results, err
+			}
+			result.PerftNodes += perft.Nodes
+			result.PerftDuration += perft.Duration
+		}
+		result.Bench = Bench(benchDepth)
+
+		if len(results) > 0 && result.PerftNodes != results[0].PerftNodes {
+			return results, fmt.Errorf("Invalid %v perft of %d nodes; Should be %d like %v", backend, result.PerftNodes, results[0].PerftNodes, results[0].Backend)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Print the result of a backend, with the nodes per second of the perft and the bench
+func (r AttackBackendResult) String() string {
+	perftNps := float64(r.PerftNodes) / r.PerftDuration.Seconds()
+	benchNps := float64(r.Bench.Nodes) / r.Bench.Duration.Seconds()
+	return fmt.Sprintf("%-6v perft: %d nodes in %v (%.0f nps), bench: %d nodes in %v (%.0f nps)", r.Backend,
+		r.PerftNodes, r.PerftDuration.Round(time.Millisecond), perftNps,
+		r.Bench.Nodes, r.Bench.Duration.Round(time.Millisecond), benchNps)
+}
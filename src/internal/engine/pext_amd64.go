@@ -0,0 +1,10 @@
+package engine
+
+import "golang.org/x/sys/cpu"
+
+// PEXT is part of BMI2, from Haswell on Intel and Excavator on AMD
+var HAS_PEXT = cpu.X86.HasBMI2
+
+// Gather the bits of src under the mask into the low bits, with the PEXT instruction
+// Only called when HAS_PEXT, in pext_amd64.s
+func pext(src, mask uint64) uint64
@@ -0,0 +1,9 @@
+#include "textflag.h"
+
+// func pext(src, mask uint64) uint64
+TEXT ·pext(SB), NOSPLIT, $0-24
+	MOVQ src+0(FP), AX
+	MOVQ mask+8(FP), BX
+	PEXTQ BX, AX, AX
+	MOVQ AX, ret+16(FP)
+	RET
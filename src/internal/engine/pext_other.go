@@ -0,0 +1,11 @@
+//go:build !amd64
+
+package engine
+
+// Only amd64 has PEXT
+var HAS_PEXT = false
+
+// Gather the bits of src under the mask into the low bits, never called without HAS_PEXT
+func pext(src, mask uint64) uint64 {
+	return softwarePext(src, mask)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSoftwarePext(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      uint64
+		mask     uint64
+		expected uint64
+	}{
+		{name: "Empty mask", src: 0xFFFF, mask: 0, expected: 0},
+		{name: "Full mask", src: 0x1234, mask: ^uint64(0), expected: 0x1234},
+		{name: "Gaps in the mask", src: 0b1010_0110, mask: 0b1111_0010, expected: 0b10101},
+		{name: "High bits", src: 1 << 63, mask: 1<<63 | 1, expected: 0b10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := softwarePext(tt.src, tt.mask); got != tt.expected {
+				t.Errorf("Expected %b, got %b", tt.expected, got)
+			}
+			if HAS_PEXT {
+				if got := pext(tt.src, tt.mask); got != tt.expected {
+					t.Errorf("Expected PEXT to give %b, got %b", tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAttackBackends(t *testing.T) {
+	InitEngine()
+	if !HAS_PEXT {
+		if err := SetAttackBackend(ATTACKS_PEXT); err == nil {
+			t.Errorf("Expected an error selecting PEXT on a CPU without BMI2")
+		}
+		t.Skip("The CPU has no PEXT")
+	}
+	if err := SetAttackBackend("rotated"); err == nil {
+		t.Errorf("Expected an error selecting an unknown backend")
+	}
+
+	// Every square gives the same attacks with both backends, whatever the pieces outside the mask
+	rng := rand.New(rand.NewSource(1))
+	defer SetAttackBackend(ATTACKS_MAGIC)
+	for sq := range Square(NUM_SQUARES) {
+		for range 1000 {
+			occupancy := BitBoard(rng.Uint64() & rng.Uint64())
+
+			SetAttackBackend(ATTACKS_MAGIC)
+			rook, bishop := getRookAttacks(sq, occupancy), getBishopAttacks(sq, occupancy)
+			SetAttackBackend(ATTACKS_PEXT)
+			if got := getRookAttacks(sq, occupancy); got != rook {
+				t.Fatalf("Square %d, occupancy %x: Expected rook attacks %x, got %x", sq, occupancy, rook, got)
+			}
+			if got := getBishopAttacks(sq, occupancy); got != bishop {
+				t.Fatalf("Square %d, occupancy %x: Expected bishop attacks %x, got %x", sq, occupancy, bishop, got)
+			}
+		}
+	}
+
+	SetAttackBackend(ATTACKS_MAGIC)
+	results, err := CompareAttackBackends(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Bench.Nodes != results[1].Bench.Nodes {
+		t.Errorf("Expected both backends to search the same nodes, got %v", results)
+	}
+	if USE_PEXT {
+		t.Errorf("Expected the comparison to restore the backend")
+	}
+}
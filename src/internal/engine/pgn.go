@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+This file holds the reader of PGN game collections.
+Only what is needed to replay the games is kept: the tags, the moves of the main line in SAN and the result.
+Comments, variations, numeric annotation glyphs and move numbers are skipped. The games are streamed one at a time,
+so large collections are not read into memory.
+*/
+
+// A game read from a PGN file
+type pgnGame struct {
+	tags   map[string]string
+	moves  []string
+	result string
+}
+
+// The results of a game, which also end its moves
+// A game in progress or with an unknown result ends with *, and has no result
+var PGN_RESULTS = map[string]GameResult{
+	"1-0":     RESULT_WHITE_WINS,
+	"0-1":     RESULT_BLACK_WINS,
+	"1/2-1/2": RESULT_DRAW,
+}
+
+// Read the games of a PGN collection, calling handle for each game in order
+func readPGN(r io.Reader, handle func(game pgnGame) error) error {
+	reader := bufio.NewReader(r)
+	game := pgnGame{tags: map[string]string{}}
+
+	// Hand over the game being read, if it has anything in it
+	flush := func() error {
+		if len(game.tags) == 0 && len(game.moves) == 0 && game.result == "" {
+			return nil
+		}
+		err := handle(game)
+		game = pgnGame{tags: map[string]string{}}
+		return err
+	}
+
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case c == '[':
+			// A tag after the moves starts a new game, even without a result
+			if len(game.moves) > 0 || game.result != "" {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			line, err := reader.ReadString(']')
+			if err != nil {
+				return fmt.Errorf("Invalid PGN tag %q; Should end with ]", line)
+			}
+			name, value, _ := strings.Cut(strings.TrimSuffix(line, "]"), " ")
+			game.tags[name] = strings.Trim(strings.TrimSpace(value), "\"")
+		case c == '{':
+			if _, err := reader.ReadString('}'); err != nil {
+				return fmt.Errorf("Invalid PGN comment; Should end with }")
+			}
+		case c == ';':
+			if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+				return err
+			}
+		case c == '(':
+			if err := skipPGNVariation(reader); err != nil {
+				return err
+			}
+		default:
+			reader.UnreadByte()
+			token, err := readPGNToken(reader)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := PGN_RESULTS[token]; ok || token == "*" {
+				game.result = token
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			if san := cleanPGNMove(token); san != "" {
+				game.moves = append(game.moves, san)
+			}
+		}
+	}
+}
+
+// Read a token of the moves up to the next separator
+func readPGNToken(reader *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if strings.IndexByte(" \t\r\n{}()[];", c) >= 0 {
+			reader.UnreadByte()
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// Skip a variation, which can hold nested variations and comments
+func skipPGNVariation(reader *bufio.Reader) error {
+	depth := 1
+	for depth > 0 {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("Invalid PGN variation; Should end with )")
+		}
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '{':
+			if _, err := reader.ReadString('}'); err != nil {
+				return fmt.Errorf("Invalid PGN comment; Should end with }")
+			}
+		}
+	}
+	return nil
+}
+
+// Strip the move number and annotations from a token, returns empty if it is not a move
+// Ex. "12.Nf3!?" is Nf3, "12..." and "$1" are not moves
+func cleanPGNMove(token string) string {
+	if strings.HasPrefix(token, "$") {
+		return ""
+	}
+	if i := strings.LastIndexByte(token, '.'); i >= 0 {
+		token = token[i+1:]
+	}
+	return strings.TrimRight(token, "!?+#")
+}
+
+// Find the legal move of a SAN move in a board
+func (b *Board) moveFromSAN(san string) (Move, error) {
+	// Castling is the same for both colors, and is sometimes written with zeros
+	switch strings.ReplaceAll(san, "0", "O") {
+	case "O-O":
+		if b.Turn == WHITE {
+			return b.moveFromPCN("e1g1")
+		}
+		return b.moveFromPCN("e8g8")
+	case "O-O-O":
+		if b.Turn == WHITE {
+			return b.moveFromPCN("e1c1")
+		}
+		return b.moveFromPCN("e8c8")
+	}
+
+	// Promotions are sometimes written without the =, like e8Q
+	if n := len(san); n > 2 && strings.IndexByte("NBRQ", san[n-1]) >= 0 && san[n-2] >= '1' && san[n-2] <= '8' {
+		san = san[:n-1] + "=" + san[n-1:]
+	}
+
+	pcn, err := b.SanToPCN(san)
+	if err != nil {
+		return NO_MOVE, err
+	}
+	return b.moveFromPCN(pcn)
+}
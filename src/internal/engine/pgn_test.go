@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadPGN(t *testing.T) {
+	InitEngine()
+
+	pgn := `[Event "Club game"]
+[White "A"]
+[Black "B"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 {the main line} Nc6 (2... d6 3. d4 (3. Bc4) exd4) 3. Bb5 $1 a6?!
+4. Ba4 Nf6 5. O-O Be7 1-0
+
+[Event "Unfinished"]
+[Result "*"]
+
+1.d4 d5 2.c4 ; a comment to the end of the line
+dxc4 *
+
+[Event "No result token"]
+[FEN "8/P6k/8/8/8/8/8/K7 w - - 0 1"]
+
+1. a8=Q+ Kg6
+
+[Event "Last"]
+1. c4 1/2-1/2`
+
+	// Tests setup to be run, with the expected games in order
+	tests := []struct {
+		event  string
+		result string
+		moves  string
+	}{
+		{event: "Club game", result: "1-0", moves: "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7"},
+		{event: "Unfinished", result: "*", moves: "d4 d5 c4 dxc4"},
+		{event: "No result token", result: "", moves: "a8=Q Kg6"},
+		{event: "Last", result: "1/2-1/2", moves: "c4"},
+	}
+
+	var games []pgnGame
+	err := readPGN(strings.NewReader(pgn), func(game pgnGame) error {
+		games = append(games, game)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(games) != len(tests) {
+		t.Fatalf("Expected %d games, got %d", len(tests), len(games))
+	}
+
+	for i, tc := range tests {
+		game := games[i]
+		if game.tags["Event"] != tc.event || game.result != tc.result || !slices.Equal(game.moves, strings.Fields(tc.moves)) {
+			t.Errorf("Expected %v %v %v, got %v %v %v", tc.event, tc.result, tc.moves, game.tags["Event"], game.result, game.moves)
+		}
+	}
+
+	// Unclosed comments and variations are errors
+	for _, bad := range []string{"1. e4 {unclosed", "1. e4 (1. d4", "[Event \"unclosed"} {
+		if err := readPGN(strings.NewReader(bad), func(pgnGame) error { return nil }); err == nil {
+			t.Errorf("Expected an error reading %q", bad)
+		}
+	}
+}
+
+func TestMoveFromSAN(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected PCN, or empty for an illegal move
+	tests := []struct {
+		fen      FEN
+		san      string
+		expected string
+	}{
+		{fen: STARTING_POSITION_FEN, san: "e4", expected: "e2e4"},
+		{fen: STARTING_POSITION_FEN, san: "Nf3", expected: "g1f3"},
+		{fen: STARTING_POSITION_FEN, san: "e5"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", san: "O-O", expected: "e1g1"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", san: "0-0-0", expected: "e1c1"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", san: "O-O", expected: "e8g8"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", san: "O-O-O", expected: "e8c8"},
+		{fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", san: "a8=Q", expected: "a7a8q"},
+		{fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", san: "a8N", expected: "a7a8n"},
+		{fen: "4k3/8/8/8/8/8/8/R3K2R w - - 0 1", san: "Rad1", expected: "a1d1"},
+		{fen: "4k3/8/8/8/8/8/8/R3K2R w - - 0 1", san: "O-O"},
+	}
+
+	for _, tc := range tests {
+		board, err := tc.fen.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Failed to build %v: %v", tc.fen, err)
+		}
+
+		move, err := board.moveFromSAN(tc.san)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("%v %v: Expected an illegal move, got %v", tc.fen, tc.san, move.toPCN())
+			}
+			continue
+		}
+		if err != nil || move.toPCN() != tc.expected {
+			t.Errorf("%v %v: Expected %v, got %v (error: %v)", tc.fen, tc.san, tc.expected, move.toPCN(), err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+)
+
+/*
+This file holds the opening book, read from books in the Polyglot .bin format.
+A book is a list of entries sorted by the Polyglot key of a position, each with a move and a weight.
+The Polyglot key is a Zobrist hash like ours, but it uses the fixed random numbers of the format (see polyglot_random.go),
+so books made by other programs can be probed. While the position is in the book, its moves are played without searching,
+picked at random by their weight so the engine does not always play the same opening.
+*/
+
+// The book file loaded when the engine is initialized, no book is used when empty
+var BOOK_PATH string
+
+// Only play the move with the highest weight, instead of picking one at random by the weights
+var BOOK_BEST_ONLY bool
+
+// The book that is loaded, nil when there is none
+var BOOK *Book
+
+// The offsets of the castling, en passant and turn random numbers
+const (
+	POLYGLOT_CASTLING_OFFSET   = 768
+	POLYGLOT_EN_PASSANT_OFFSET = 772
+	POLYGLOT_TURN_OFFSET       = 780
+)
+
+// The Polyglot key of the starting position, used to check the random numbers are the standard ones
+const POLYGLOT_START_KEY uint64 = 0x463B96181691FC9C
+
+// Each entry is 16 bytes, big endian: the key, the move, the weight and a learning value
+const POLYGLOT_ENTRY_SIZE = 16
+
+// The promotion pieces in the order of a Polyglot move, 0 is no promotion
+var POLYGLOT_PROMOTIONS = [5]string{"", "n", "b", "r", "q"}
+
+type BookEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// An opening book, with the entries sorted by key
+type Book struct {
+	entries []BookEntry
+}
+
+// A legal move from the book, with its weight
+type BookMove struct {
+	move   Move
+	weight int
+}
+
+// Load a Polyglot book from a file
+func LoadBook(path string) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%POLYGLOT_ENTRY_SIZE != 0 {
+		return nil, fmt.Errorf("Invalid book %v; Should be entries of %d bytes", path, POLYGLOT_ENTRY_SIZE)
+	}
+
+	entries := make([]BookEntry, 0, len(data)/POLYGLOT_ENTRY_SIZE)
+	for i := 0; i < len(data); i += POLYGLOT_ENTRY_SIZE {
+		entries = append(entries, BookEntry{
+			Key:    binary.BigEndian.Uint64(data[i:]),
+			Move:   binary.BigEndian.Uint16(data[i+8:]),
+			Weight: binary.BigEndian.Uint16(data[i+10:]),
+			Learn:  binary.BigEndian.Uint32(data[i+12:]),
+		})
+	}
+
+	return newBook(entries), nil
+}
+
+// Setup a book from its entries, which should already be sorted by key, but are sorted if not
+func newBook(entries []BookEntry) *Book {
+	if !slices.IsSortedFunc(entries, compareBookEntries) {
+		slices.SortStableFunc(entries, compareBookEntries)
+	}
+	return &Book{entries: entries}
+}
+
+func compareBookEntries(a, b BookEntry) int {
+	return cmp.Compare(a.Key, b.Key)
+}
+
+// The entries of a position, found with a binary search
+func (book *Book) lookup(key uint64) []BookEntry {
+	first := sort.Search(len(book.entries), func(i int) bool {
+		return book.entries[i].Key >= key
+	})
+	last := first
+	for last < len(book.entries) && book.entries[last].Key == key {
+		last++
+	}
+	return book.entries[first:last]
+}
+
+// Return the Polyglot key of a board
+func (b *Board) polyglotKey() uint64 {
+	var key uint64
+
+	// Hash in the pieces, the kinds alternate black then white for each piece
+	for color := range NUM_COLORS {
+		for piece := range NUM_PIECES {
+			kind := 2 * int(piece)
+			if Color(color) == WHITE {
+				kind++
+			}
+
+			pieceBits := b.Pieces[color][piece]
+			for pieceBits > 0 {
+				sq := pieceBits.popSquare()
+				key ^= POLYGLOT_RANDOM[64*kind+int(sq)]
+			}
+		}
+	}
+
+	// Hash in the castling rights
+	for i, right := range []uint8{CASTLE_WK, CASTLE_WQ, CASTLE_BK, CASTLE_BQ} {
+		if b.CR&right != 0 {
+			key ^= POLYGLOT_RANDOM[POLYGLOT_CASTLING_OFFSET+i]
+		}
+	}
+
+	// Hash in the en passant file, but only if a pawn of the side to move can capture en passant
+	if b.EPS != NO_SQUARE && pawnAttacks(b.EPS.bitBoardPosition(), 1-b.Turn)&b.Pieces[b.Turn][PAWN] != 0 {
+		key ^= POLYGLOT_RANDOM[POLYGLOT_EN_PASSANT_OFFSET+int(b.EPS%8)]
+	}
+
+	// Hash in the move
+	if b.Turn == WHITE {
+		key ^= POLYGLOT_RANDOM[POLYGLOT_TURN_OFFSET]
+	}
+
+	return key
+}
+
+// Find the legal move of a Polyglot move, which is false if the move is not legal in the position
+func (b *Board) polyglotMove(move uint16) (Move, bool) {
+	target := Square(move & 0x3F)
+	start := Square((move >> 6) & 0x3F)
+	promotion := int((move >> 12) & 0x7)
+	if promotion >= len(POLYGLOT_PROMOTIONS) {
+		return NO_MOVE, false
+	}
+
+	// Castling is written as the king taking its own rook
+	if b.MailBox[start] == KING {
+		switch {
+		case start == E1 && target == H1, start == E8 && target == H8:
+			target = start + 2
+		case start == E1 && target == A1, start == E8 && target == A8:
+			target = start - 2
+		}
+	}
+
+	legal, err := b.moveFromPCN(start.toString() + target.toString() + POLYGLOT_PROMOTIONS[promotion])
+	if err != nil {
+		return NO_MOVE, false
+	}
+	return legal, true
+}
+
+// The legal moves of the book in a position, from the highest weight to the lowest
+// Moves with a weight of 0 are never played, and illegal moves (from a key collision) are skipped
+func (b *Board) bookMoves(book *Book) []BookMove {
+	if book == nil {
+		return nil
+	}
+
+	var moves []BookMove
+	for _, entry := range book.lookup(b.polyglotKey()) {
+		if entry.Weight == 0 {
+			continue
+		}
+		if move, ok := b.polyglotMove(entry.Move); ok {
+			moves = append(moves, BookMove{move: move, weight: int(entry.Weight)})
+		}
+	}
+
+	slices.SortStableFunc(moves, func(a, b BookMove) int {
+		return cmp.Compare(b.weight, a.weight)
+	})
+	return moves
+}
+
+// Pick a move of the book, at random by weight or the best one, returns -1 when there are no moves
+func pickBookMove(moves []BookMove, bestOnly bool) int {
+	if len(moves) == 0 {
+		return -1
+	}
+	if bestOnly {
+		return 0
+	}
+
+	total := 0
+	for _, move := range moves {
+		total += move.weight
+	}
+	roll := rand.Intn(total)
+	for i, move := range moves {
+		roll -= move.weight
+		if roll < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// Probe the loaded book, returning the move to play first and then the other book moves
+// The moves are not evaluated, so their evals are 0
+func (b *Board) probeBook(numberOfMoves int) []MoveEval {
+	moves := b.bookMoves(BOOK)
+	picked := pickBookMove(moves, BOOK_BEST_ONLY)
+	if picked < 0 {
+		return nil
+	}
+
+	moveEvals := make([]MoveEval, 0, len(moves))
+	moveEvals = append(moveEvals, MoveEval{move: moves[picked].move})
+	for i, move := range moves {
+		if i != picked {
+			moveEvals = append(moveEvals, MoveEval{move: move.move})
+		}
+	}
+	return moveEvals[:min(max(numberOfMoves, 1), len(moveEvals))]
+}
+
+// Check the random numbers give the standard key of the starting position
+func polyglotRandomValid() bool {
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		return false
+	}
+	return board.polyglotKey() == POLYGLOT_START_KEY
+}
+
+// Load the book of BOOK_PATH, if there is one
+func initBook() {
+	BOOK = nil
+	if BOOK_PATH == "" {
+		return
+	}
+
+	// Without the standard random numbers every position would be looked up with the wrong key
+	if !polyglotRandomValid() {
+		fmt.Printf("Failed to load the opening book: Invalid Polyglot random numbers; The key of the starting position should be %016x\n", POLYGLOT_START_KEY)
+		return
+	}
+
+	book, err := LoadBook(BOOK_PATH)
+	if err != nil {
+		fmt.Printf("Failed to load the opening book: %v\n", err)
+		return
+	}
+	BOOK = book
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	InitEngine()
+	if !polyglotRandomValid() {
+		t.Errorf("Expected the key of the starting position to be %016x", POLYGLOT_START_KEY)
+	}
+
+	// The keys of the Polyglot format specification, after playing the moves from the starting position
+	tests := []struct {
+		moves    []string
+		expected uint64
+	}{
+		{moves: []string{}, expected: 0x463B96181691FC9C},
+		{moves: []string{"e2e4"}, expected: 0x823C9B50FD114196},
+		{moves: []string{"e2e4", "d7d5"}, expected: 0x0756B94461C50FB0},
+		{moves: []string{"e2e4", "d7d5", "e4e5"}, expected: 0x662FAFB965DB29D4},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5"}, expected: 0x22A48B5A8E47FF78},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, expected: 0x652A607CA3F242C1},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, expected: 0x00FDD303C946BDD9},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, expected: 0x3C8123EA7B067637},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, expected: 0x5C3F9B829B279560},
+	}
+
+	for _, tc := range tests {
+		board, err := STARTING_POSITION_FEN.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Failed to build the starting position: %v", err)
+		}
+		for _, pcn := range tc.moves {
+			move, err := board.moveFromPCN(pcn)
+			if err != nil {
+				t.Fatalf("%v: %v", tc.moves, err)
+			}
+			board.makeMove(move)
+		}
+
+		if got := board.polyglotKey(); got != tc.expected {
+			t.Errorf("%v: Expected %016x, got %016x", tc.moves, tc.expected, got)
+		}
+	}
+}
+
+func TestPolyglotMove(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the move written as Polyglot squares (rank * 8 + file) and the expected PCN
+	tests := []struct {
+		fen       FEN
+		start     Square
+		target    Square
+		promotion uint16
+		expected  string
+	}{
+		{fen: STARTING_POSITION_FEN, start: E2, target: E4, expected: "e2e4"},
+		{fen: STARTING_POSITION_FEN, start: G1, target: F3, expected: "g1f3"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", start: E1, target: H1, expected: "e1g1"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", start: E1, target: A1, expected: "e1c1"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", start: E8, target: H8, expected: "e8g8"},
+		{fen: "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", start: E8, target: A8, expected: "e8c8"},
+		{fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", start: A7, target: A8, promotion: 4, expected: "a7a8q"},
+		{fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", start: A7, target: A8, promotion: 1, expected: "a7a8n"},
+		{fen: STARTING_POSITION_FEN, start: E2, target: E5},
+		{fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", start: A7, target: A8, promotion: 7},
+	}
+
+	for _, tc := range tests {
+		board, err := tc.fen.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Failed to build %v: %v", tc.fen, err)
+		}
+
+		move, ok := board.polyglotMove(tc.promotion<<12 | uint16(tc.start)<<6 | uint16(tc.target))
+		if tc.expected == "" {
+			if ok {
+				t.Errorf("%v %v%v: Expected an illegal move, got %v", tc.fen, tc.start.toString(), tc.target.toString(), move.toPCN())
+			}
+			continue
+		}
+		if !ok || move.toPCN() != tc.expected {
+			t.Errorf("%v: Expected %v, got %v (legal: %v)", tc.fen, tc.expected, move.toPCN(), ok)
+		}
+
+		// Converting the move back gives the same Polyglot move
+		if got := move.toPolyglot(); got != tc.promotion<<12|uint16(tc.start)<<6|uint16(tc.target) {
+			t.Errorf("%v: Expected %v to convert back, got %04x", tc.fen, tc.expected, got)
+		}
+	}
+}
+
+func TestBook(t *testing.T) {
+	InitEngine()
+	defer func() { BOOK = nil; BOOK_PATH = ""; BOOK_BEST_ONLY = false }()
+
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		t.Fatalf("Failed to build the starting position: %v", err)
+	}
+	key := board.polyglotKey()
+	polyglotMove := func(start, target Square) uint16 { return uint16(start)<<6 | uint16(target) }
+
+	// The entries are written out of order, the book sorts them when loaded
+	entries := []BookEntry{
+		{Key: key + 1, Move: polyglotMove(A2, A3), Weight: 1000},
+		{Key: key, Move: polyglotMove(D2, D4), Weight: 10},
+		{Key: key, Move: polyglotMove(E2, E4), Weight: 30},
+		{Key: key, Move: polyglotMove(A2, A4), Weight: 0},
+		{Key: key, Move: polyglotMove(E2, E5), Weight: 1000},
+		{Key: key - 1, Move: polyglotMove(H2, H3), Weight: 1000},
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := writeBook(path, entries); err != nil {
+		t.Fatalf("Failed to write the book: %v", err)
+	}
+	BOOK, err = LoadBook(path)
+	if err != nil {
+		t.Fatalf("Failed to load the book: %v", err)
+	}
+
+	// Only the legal moves with a weight are played, best first
+	moves := board.bookMoves(BOOK)
+	if len(moves) != 2 || moves[0].move.toPCN() != "e2e4" || moves[1].move.toPCN() != "d2d4" {
+		t.Fatalf("Expected the book moves e2e4 and d2d4, got %v", moves)
+	}
+
+	BOOK_BEST_ONLY = true
+	for range 10 {
+		if moveEvals := board.probeBook(5); len(moveEvals) != 2 || moveEvals[0].move.toPCN() != "e2e4" {
+			t.Fatalf("Expected e2e4 to be played first, got %v", moveEvals)
+		}
+	}
+
+	// Picking by weight plays both moves, e2e4 about 3 times as often
+	BOOK_BEST_ONLY = false
+	played := map[string]int{}
+	for range 400 {
+		moveEvals := board.probeBook(1)
+		if len(moveEvals) != 1 {
+			t.Fatalf("Expected a single move, got %v", moveEvals)
+		}
+		played[moveEvals[0].move.toPCN()]++
+	}
+	if played["e2e4"] < 200 || played["d2d4"] < 50 || played["e2e4"]+played["d2d4"] != 400 {
+		t.Errorf("Expected e2e4 about 300 times and d2d4 about 100 times, got %v", played)
+	}
+
+	// The engine loads the book at startup and plays from it without searching
+	BOOK_PATH = path
+	BOOK_BEST_ONLY = true
+	initBook()
+	if BOOK == nil {
+		t.Fatalf("Expected the book to be loaded")
+	}
+	response, err := Evalute(STARTING_POSITION_FEN, nil, 1, SearchOptions{Depth: 2})
+	if err != nil || len(response.MoveEvals) != 1 || response.MoveEvals[0].move.toPCN() != "e2e4" || response.nodes != 0 {
+		t.Errorf("Expected e2e4 to be played from the book, got %v (%v)", response, err)
+	}
+	BOOK_BEST_ONLY = false
+
+	// Positions out of the book are searched
+	BOOK = newBook([]BookEntry{{Key: key + 1, Move: polyglotMove(E2, E4), Weight: 1}})
+	if moveEvals := board.probeBook(1); moveEvals != nil {
+		t.Errorf("Expected no book moves, got %v", moveEvals)
+	}
+
+	// Books have to be made of whole entries
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the book: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-1], 0644); err != nil {
+		t.Fatalf("Failed to write the book: %v", err)
+	}
+	if _, err := LoadBook(path); err == nil {
+		t.Errorf("Expected an error loading a cut short book")
+	}
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+This file holds pondering, searching on the opponent's time.
+After the engine plays a move, it guesses the opponent's reply and searches the position after it in the background.
+If the opponent plays the expected reply (a ponder hit), the background search becomes the real search, and gets the
+move time from then on, so the time the opponent spent thinking is a free head start. On a miss the search is thrown away.
+The engine searches one position at a time (the TT is shared), so there is at most one
+ponder search, and starting any other search stops it first.
+*/
+
+// Lets a search be stopped from another goroutine
+type searchControl struct {
+	stop atomic.Bool
+}
+
+// A search of the position after the expected reply, running in the background
+type Ponder struct {
+	// The reply the search expects, and the position after it
+	Reply   string
+	zobrist ZobristHash
+
+	options SearchOptions
+	control *searchControl
+
+	// Closed when the search returns, the results are only read after
+	done    chan struct{}
+	results BoardSearchResults
+}
+
+// The running ponder search, nil when the engine is not pondering
+// PONDER_LOCK is held while it is read or set, for the whole of StartPonder, PonderHit and StopPonder
+var PONDER *Ponder
+var PONDER_LOCK sync.Mutex
+
+// The depth searched to guess the reply, when the TT does not know it
+const PONDER_REPLY_DEPTH = 3
+
+/*
+StartPonder starts pondering on the position after the engine's move, with the opponent to move.
+The reply expected is the best move the TT has for the opponent, from the search of the engine's move.
+Returns the reply pondered on, in PCN, or an error if the position is invalid or the game is over.
+*/
+func StartPonder(position FEN, history []FEN, options SearchOptions) (string, error) {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+	stopPonder()
+
+	board, err := position.toBoard(history)
+	if err != nil {
+		return "", err
+	}
+
+	reply, ok := board.expectedReply(options)
+	if !ok {
+		return "", fmt.Errorf("Invalid position to ponder on; Should have a legal move for the side to move")
+	}
+	startPonder(board, reply, options)
+	return reply.toPCN(), nil
+}
+
+/*
+PonderOn starts pondering on the position after the engine's move, like StartPonder, but on a reply that is given.
+This is UCI's go ponder, where the GUI picks the reply. Returns an error if the position is invalid or the reply is not legal.
+*/
+func PonderOn(position FEN, history []FEN, reply string, options SearchOptions) error {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+	stopPonder()
+
+	board, err := position.toBoard(history)
+	if err != nil {
+		return err
+	}
+
+	move, err := board.moveFromPCN(reply)
+	if err != nil {
+		return err
+	}
+	startPonder(board, move, options)
+	return nil
+}
+
+/*
+ExpectedReply gets the reply the engine expects in the position after its move, in PCN, false if the game is over.
+This is the reply StartPonder ponders on, for UCI's bestmove to tell the GUI which move to ponder on.
+*/
+func ExpectedReply(position FEN, history []FEN, options SearchOptions) (string, bool) {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+	stopPonder()
+
+	board, err := position.toBoard(history)
+	if err != nil {
+		return "", false
+	}
+	reply, ok := board.expectedReply(options)
+	if !ok {
+		return "", false
+	}
+	return reply.toPCN(), true
+}
+
+// Start the ponder search of the position after the reply, with PONDER_LOCK held and no ponder search running
+func startPonder(board *Board, reply Move, options SearchOptions) {
+	board.makeMove(reply)
+
+	// The search ponders without a time limit, the move time only starts on a hit
+	p := &Ponder{
+		Reply:   reply.toPCN(),
+		zobrist: board.Zobrist,
+		options: options,
+		control: &searchControl{},
+		done:    make(chan struct{}),
+	}
+	ponderOptions := options
+	ponderOptions.MoveTime = 0
+	ponderOptions.control = p.control
+
+	go func() {
+		defer close(p.done)
+		p.results = board.search(MAX_NUMBER_OF_MOVES_IN_A_POSITION, ponderOptions)
+	}()
+
+	PONDER = p
+}
+
+/*
+PonderHit is called with the position after the opponent's reply.
+If it is the position pondered on, the ponder search becomes the real search: it gets the move time of its options
+from now, and its results are returned like Evalute's. Otherwise the ponder search is stopped and thrown away, and
+false is returned, the position has to be searched with Evalute.
+*/
+func PonderHit(position FEN, numberOfMoves int) (*EvaluateResponse, bool) {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+
+	p := PONDER
+	if p == nil {
+		return nil, false
+	}
+
+	board, err := position.toBoard(nil)
+	if err != nil || board.Zobrist != p.zobrist {
+		stopPonder()
+		return nil, false
+	}
+
+	// The opening book is played from without searching, like in Evalute
+	start := time.Now()
+	if moveEvals := board.probeBook(numberOfMoves); moveEvals != nil {
+		stopPonder()
+		return &EvaluateResponse{
+			MoveEvals: moveEvals,
+			duration:  time.Since(start),
+		}, true
+	}
+
+	// Wait for the search to finish its depth, or stop it at the move time and take the last depth it completed
+	if p.options.MoveTime > 0 {
+		select {
+		case <-p.done:
+		case <-time.After(p.options.MoveTime):
+			p.control.stop.Store(true)
+			<-p.done
+		}
+	} else {
+		<-p.done
+	}
+	PONDER = nil
+	return p.response(numberOfMoves, start)
+}
+
+/*
+PonderResults stops the ponder search and returns the results of the last depth it completed, false if the engine is not pondering.
+This is UCI's stop while pondering, where the GUI still expects a move even though it throws it away.
+*/
+func PonderResults(numberOfMoves int) (*EvaluateResponse, bool) {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+
+	p := PONDER
+	if p == nil {
+		return nil, false
+	}
+	start := time.Now()
+	stopPonder()
+	return p.response(numberOfMoves, start)
+}
+
+// Get the results of a ponder search that has returned, like Evalute's, false if it has no moves
+func (p *Ponder) response(numberOfMoves int, start time.Time) (*EvaluateResponse, bool) {
+	if len(p.results.MoveEvals) == 0 {
+		return nil, false
+	}
+	return &EvaluateResponse{
+		MoveEvals: p.results.MoveEvals[:min(max(numberOfMoves, 1), len(p.results.MoveEvals))],
+		duration:  time.Since(start),
+		nodes:     p.results.Nodes,
+	}, true
+}
+
+// Stop the ponder search, if there is one, and wait for it to return
+func StopPonder() {
+	PONDER_LOCK.Lock()
+	defer PONDER_LOCK.Unlock()
+	stopPonder()
+}
+
+// Stop the ponder search, with PONDER_LOCK held
+func stopPonder() {
+	p := PONDER
+	if p == nil {
+		return
+	}
+
+	p.control.stop.Store(true)
+	<-p.done
+	PONDER = nil
+}
+
+// Get the reply the engine expects from the side to move, with the evaluation parameters of the options
+// This is the move of the TT entry of the position, else the best move of a shallow search
+func (b *Board) expectedReply(options SearchOptions) (Move, bool) {
+	moves := b.generateLegalMoves()
+	if len(moves) == 0 {
+		return NO_MOVE, false
+	}
+
+	// The entry was stored by the search of the engine's move, with the engine as the root side
+	_, paramsKey := options.searchParams(b.Turn ^ 1)
+	if entry := probeTT(b.Zobrist ^ ttKey(paramsKey, options.searchContempt(), b.Turn^1)); entry != nil {
+		for _, move := range moves {
+			if move == entry.move {
+				return move, true
+			}
+		}
+	}
+
+	replyOptions := DEFAULT_SEARCH_OPTIONS
+	replyOptions.Depth = PONDER_REPLY_DEPTH
+	replyOptions.evalParams, replyOptions.Personality = options.evalParams, options.Personality
+	result := b.iterativeSearch(replyOptions)
+	if len(result.moves) == 0 {
+		return NO_MOVE, false
+	}
+	return bestRootMove(result), true
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPonder(t *testing.T) {
+	InitEngine()
+	BOOK = nil
+
+	// After 1. e4, the engine ponders on a reply from black
+	position := FEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	options := DEFAULT_SEARCH_OPTIONS
+	options.Depth = 4
+
+	// Tests setup to be run, with if the position reached is the one pondered on
+	tests := []struct {
+		name string
+		hit  bool
+	}{
+		{name: "hit", hit: true},
+		{name: "miss", hit: false},
+	}
+
+	for _, tc := range tests {
+		ClearTT()
+		reply, err := StartPonder(position, nil, options)
+		if err != nil {
+			t.Fatalf("%v: Unexpected error: %v", tc.name, err)
+		}
+
+		board, err := position.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Failed to build %v: %v", position, err)
+		}
+		move, _ := board.moveFromPCN(reply)
+		if !tc.hit {
+			// Any other legal reply misses
+			for _, other := range board.generateLegalMoves() {
+				if other.toPCN() != reply {
+					move = other
+					break
+				}
+			}
+		}
+		if !board.isMoveLegal(move) {
+			t.Fatalf("%v: Expected the reply %v to be legal", tc.name, reply)
+		}
+		board.makeMove(move)
+
+		response, hit := PonderHit(board.toFEN(), 3)
+		if hit != tc.hit {
+			t.Fatalf("%v: Expected a hit %v, got %v", tc.name, tc.hit, hit)
+		}
+		if PONDER != nil {
+			t.Errorf("%v: Expected the ponder search to be done", tc.name)
+		}
+		if tc.hit && len(response.MoveEvals) != 3 {
+			t.Errorf("%v: Expected 3 moves, got %d", tc.name, len(response.MoveEvals))
+		}
+	}
+
+	// With a move time, a hit stops the search at the time and returns the last depth it completed
+	options.Depth = 10
+	options.MoveTime = 50 * time.Millisecond
+	ClearTT()
+	reply, err := StartPonder(position, nil, options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	board, _ := position.toBoard(nil)
+	move, _ := board.moveFromPCN(reply)
+	board.makeMove(move)
+	start := time.Now()
+	response, hit := PonderHit(board.toFEN(), 1)
+	if !hit || len(response.MoveEvals) != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected a move within the move time, got %v in %v", response, time.Since(start))
+	}
+
+	// A search stops the ponder search before it starts
+	if _, err := StartPonder(position, nil, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := Evalute(STARTING_POSITION_FEN, nil, 1, SearchOptions{Depth: 2}); err != nil || PONDER != nil {
+		t.Errorf("Expected the ponder search to be stopped by a search (error: %v)", err)
+	}
+
+	// There is nothing to ponder on when the game is over
+	if _, err := StartPonder("k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", nil, options); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	StopPonder()
+	if _, err := StartPonder("k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", nil, options); err == nil {
+		t.Errorf("Expected an error for a stalemate")
+	}
+
+	// The ponder search can be stopped and hit from other goroutines at the same time
+	if _, err := StartPonder(position, nil, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Go(StopPonder)
+	wg.Go(func() { PonderHit(board.toFEN(), 1) })
+	wg.Go(StopPonder)
+	wg.Wait()
+	if PONDER != nil {
+		t.Errorf("Expected the ponder search to be done")
+	}
+	ClearTT()
+}
@@ -1,7 +1,15 @@
 package engine
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,6 +18,10 @@ This file holds the Perft test, which can be called via the command line.
 This is used for validating the engine's correctness when it comes to move generation and searching.
 This function does not need tests, as its the test itself.
 All tests are taken from: https://www.chessprogramming.org/Perft_Results
+
+Besides the built in tests, any position can be counted, divided by the root moves to diff against a reference engine
+when a move generation bug appears, and EPD perft suites can be run (ex. perftsuite.epd, "<fen> ;D1 20 ;D2 400 ...").
+The root moves are counted in parallel, and a hash table of counted subtrees can be shared between them.
 */
 type PerftTest struct {
 	name          string
@@ -18,7 +30,279 @@ type PerftTest struct {
 	expectedNodes int
 }
 
-func Perft() {
+// Options for counting the positions of a perft
+type PerftOptions struct {
+	// The depth to count to, in an EPD suite only the counts up to the depth are checked (0 checks them all)
+	Depth uint8
+
+	// Print the count of every root move
+	Divide bool
+
+	// The number of goroutines counting the root moves, defaults to the number of CPUs
+	Threads int
+
+	// The size of the hash table of counted subtrees in MB, 0 for no hash table
+	HashMB int
+}
+
+// The default perft options
+var DEFAULT_PERFT_OPTIONS = PerftOptions{
+	HashMB: 64,
+}
+
+// The count of a perft, with the count of each root move
+type PerftResult struct {
+	Nodes    int
+	Divide   []PerftDivide
+	Duration time.Duration
+}
+
+// The count of a root move
+type PerftDivide struct {
+	Move  string
+	Nodes int
+}
+
+// An entry of the perft hash table, the check is the zobrist xor the data so torn writes are never read as a hit
+// The data is the count in the upper 56 bits, and the depth in the lower 8
+type perftHashEntry struct {
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+// The size of a perft hash entry in bytes
+const PERFT_HASH_ENTRY_SIZE = 16
+
+// A hash table of counted subtrees, shared between the goroutines of a perft
+type perftHash []perftHashEntry
+
+// Make a perft hash table of the size in MB, rounded down to a power of two number of entries
+func newPerftHash(sizeMB int) perftHash {
+	if sizeMB <= 0 {
+		return nil
+	}
+
+	entries := 1
+	for entries*2*PERFT_HASH_ENTRY_SIZE <= sizeMB<<20 {
+		entries *= 2
+	}
+	return make(perftHash, entries)
+}
+
+// Get the count of a subtree, if it is in the table
+func (h perftHash) probe(zobrist ZobristHash, depth uint8) (int, bool) {
+	entry := &h[uint64(zobrist)&uint64(len(h)-1)]
+	data := entry.data.Load()
+	if entry.check.Load()^data != uint64(zobrist) || uint8(data) != depth {
+		return 0, false
+	}
+	return int(data >> 8), true
+}
+
+// Store the count of a subtree
+func (h perftHash) store(zobrist ZobristHash, depth uint8, nodes int) {
+	entry := &h[uint64(zobrist)&uint64(len(h)-1)]
+	data := uint64(nodes)<<8 | uint64(depth)
+	entry.data.Store(data)
+	entry.check.Store(uint64(zobrist) ^ data)
+}
+
+// Count the positions at the depth of a position, splitting the root moves between goroutines
+func RunPerft(position FEN, options PerftOptions) (PerftResult, error) {
+	return runPerft(position, options, newPerftHash(options.HashMB))
+}
+
+// Count the positions at the depth of a position, with a hash table that can be reused between positions
+func runPerft(position FEN, options PerftOptions, hash perftHash) (PerftResult, error) {
+	start := time.Now()
+	board, err := position.toBoard(nil)
+	if err != nil {
+		return PerftResult{}, err
+	}
+	if options.Depth == 0 {
+		return PerftResult{Nodes: 1, Duration: time.Since(start)}, nil
+	}
+	if int(options.Depth) >= MAX_PLY {
+		return PerftResult{}, fmt.Errorf("Invalid perft depth %d; Should be less than %d", options.Depth, MAX_PLY)
+	}
+
+	rootMoves := board.generateLegalMoves()
+	divide := make([]PerftDivide, len(rootMoves))
+
+	threads := options.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+
+	// Each goroutine counts the next root move not taken, on its own board
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(threads, len(rootMoves)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			board, _ := position.toBoard(nil)
+			moveStack := make([]MoveList, options.Depth)
+
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(rootMoves) {
+					return
+				}
+				unmake, _ := board.makeMove(rootMoves[i])
+				divide[i] = PerftDivide{Move: rootMoves[i].toPCN(), Nodes: board.perftHashed(options.Depth-1, moveStack, hash)}
+				board.unMakeMove(unmake)
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := PerftResult{Divide: divide}
+	for _, d := range divide {
+		result.Nodes += d.Nodes
+	}
+	slices.SortFunc(result.Divide, func(a, b PerftDivide) int {
+		return strings.Compare(a.Move, b.Move)
+	})
+	result.Duration = time.Since(start)
+
+	if options.Divide {
+		for _, d := range result.Divide {
+			fmt.Printf("%v: %d\n", d.Move, d.Nodes)
+		}
+		fmt.Printf("\nMoves: %d\nNodes: %d\nTime: %v\n", len(result.Divide), result.Nodes, result.Duration)
+	}
+	return result, nil
+}
+
+// Count the positions at the depth, with the counts of subtrees in the hash table when there is one
+func (b *Board) perftHashed(depth uint8, moveStack []MoveList, hash perftHash) int {
+	if hash == nil {
+		return b.perftNegamax(depth, moveStack).nodes
+	}
+	if depth == 0 {
+		return 1
+	}
+	if nodes, ok := hash.probe(b.Zobrist, depth); ok {
+		return nodes
+	}
+
+	nodes := 0
+	moves := &moveStack[depth]
+	b.generatePseudoLegalMoves(moves)
+	for _, move := range moves.slice() {
+		unmake, isLegal := b.makeMove(move)
+		if isLegal {
+			nodes += b.perftHashed(depth-1, moveStack, hash)
+		}
+		b.unMakeMove(unmake)
+	}
+
+	hash.store(b.Zobrist, depth, nodes)
+	return nodes
+}
+
+// A position of an EPD perft suite, with its expected counts by depth
+type PerftSuiteEntry struct {
+	Position FEN
+	Expected map[uint8]int
+}
+
+// Read an EPD perft suite, a position per line followed by its counts (ex. "<fen> ;D1 20 ;D2 400")
+// The position can have 4 fields, without the move counters
+func ReadPerftSuite(path string) ([]PerftSuiteEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []PerftSuiteEntry
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, ";")
+		position := strings.Fields(fields[0])
+		if len(position) == 4 {
+			position = append(position, "0", "1")
+		}
+		entry := PerftSuiteEntry{Position: FEN(strings.Join(position, " ")), Expected: map[uint8]int{}}
+
+		for _, field := range fields[1:] {
+			parts := strings.Fields(field)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], "D") {
+				return nil, fmt.Errorf("Invalid perft count %q on line %d; Should be like D1 20", strings.TrimSpace(field), line)
+			}
+			depth, err := strconv.ParseUint(parts[0][1:], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid perft depth %q on line %d; Should be a number", parts[0], line)
+			}
+			nodes, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid perft count %q on line %d; Should be a number", parts[1], line)
+			}
+			entry.Expected[uint8(depth)] = nodes
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Run an EPD perft suite, returns if every count matched
+// Only the counts up to the depth of the options are checked, or all of them when it is 0
+func RunPerftSuite(path string, options PerftOptions) (bool, error) {
+	entries, err := ReadPerftSuite(path)
+	if err != nil {
+		return false, err
+	}
+
+	hash := newPerftHash(options.HashMB)
+	passed := true
+	failed := 0
+	for i, entry := range entries {
+		depths := make([]uint8, 0, len(entry.Expected))
+		for depth := range entry.Expected {
+			if options.Depth == 0 || depth <= options.Depth {
+				depths = append(depths, depth)
+			}
+		}
+		slices.Sort(depths)
+
+		for _, depth := range depths {
+			depthOptions := options
+			depthOptions.Depth = depth
+			depthOptions.Divide = false
+			result, err := runPerft(entry.Position, depthOptions, hash)
+			if err != nil {
+				return false, err
+			}
+
+			expected := entry.Expected[depth]
+			if result.Nodes != expected {
+				passed = false
+				failed++
+				fmt.Printf("FAIL %d: %v depth %d: expected %d, got %d\n", i+1, entry.Position, depth, expected, result.Nodes)
+			} else {
+				fmt.Printf("ok   %d: %v depth %d: %d (%v)\n", i+1, entry.Position, depth, result.Nodes, result.Duration)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d positions, %d failed counts\n", len(entries), failed)
+	return passed, nil
+}
+
+// Run the built in perft tests, returns if every count matched
+func Perft(options PerftOptions) bool {
 	fmt.Println("Starting PREFT test.")
 
 	// The Perft tests to run
@@ -58,32 +342,29 @@ func Perft() {
 	// Init the engine
 	InitEngine()
 
+	hash := newPerftHash(options.HashMB)
+	passed := true
 	for _, test := range tests {
-		// Allocate the moveStack
-		moveStack := make([][]Move, MAX_PLY)
-		for i := range moveStack {
-			moveStack[i] = make([]Move, 256)
-		}
+		testOptions := options
+		testOptions.Depth = test.depth
+		testOptions.Divide = false
 
-		// Setup board from test position
-		board, err := test.position.toBoard(nil)
+		// Search
+		result, err := runPerft(test.position, testOptions, hash)
 		if err != nil {
 			fmt.Println(err)
-			return
+			return false
 		}
-
-		// Search
-		start := time.Now()
-		result := board.perftNegamax(test.depth, moveStack)
-		searchTime := time.Since(start)
-		mnps := (float64(result.nodes) / searchTime.Seconds()) / 1000000
+		mnps := (float64(result.Nodes) / result.Duration.Seconds()) / 1000000
+		passed = passed && test.expectedNodes == result.Nodes
 
 		// Print results
 		fmt.Printf("%v\n", test.name)
-		fmt.Printf("Total search time: %v\n", searchTime)
+		fmt.Printf("Total search time: %v\n", result.Duration)
 		fmt.Printf("Million nodes per second: %.3f\n", mnps)
-		fmt.Printf("Total nodes searched: %v\n", result.nodes)
+		fmt.Printf("Total nodes searched: %v\n", result.Nodes)
 		fmt.Printf("Expected nodes: %v\n", test.expectedNodes)
-		fmt.Printf("Passed test: %v\n\n\n", test.expectedNodes == result.nodes)
+		fmt.Printf("Passed test: %v\n\n\n", test.expectedNodes == result.Nodes)
 	}
+	return passed
 }
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunPerft(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, the counts have to match with and without the hash table, and with any number of threads
+	tests := []struct {
+		position FEN
+		depth    uint8
+		expected int
+		moves    int
+	}{
+		{position: STARTING_POSITION_FEN, depth: 3, expected: 8902, moves: 20},
+		{position: "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", depth: 3, expected: 97862, moves: 48},
+		{position: "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", depth: 5, expected: 674624, moves: 14},
+	}
+
+	for _, tc := range tests {
+		for _, options := range []PerftOptions{
+			{Depth: tc.depth, Threads: 1},
+			{Depth: tc.depth, Threads: 4},
+			{Depth: tc.depth, Threads: 4, HashMB: 1},
+		} {
+			result, err := RunPerft(tc.position, options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Nodes != tc.expected || len(result.Divide) != tc.moves {
+				t.Errorf("%v %+v: Expected %d nodes over %d moves, got %d over %d", tc.position, options, tc.expected, tc.moves, result.Nodes, len(result.Divide))
+			}
+		}
+	}
+
+	// The divide of the starting position, the root moves are sorted
+	result, err := RunPerft(STARTING_POSITION_FEN, PerftOptions{Depth: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Divide[0] != (PerftDivide{Move: "a2a3", Nodes: 20}) {
+		t.Errorf("Expected a2a3 to lead with 20 nodes, got %+v", result.Divide[0])
+	}
+
+	// An EPD suite fails on a wrong count, and only checks the counts up to the depth
+	dir := t.TempDir()
+	path := filepath.Join(dir, "perftsuite.epd")
+	suite := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - ;D1 20 ;D2 400 ;D3 8902\n" +
+		"4k3/8/8/8/8/8/8/4K2R w K - ;D1 15 ;D2 66 ;D3 1197 ;D4 1\n"
+	if err := os.WriteFile(path, []byte(suite), 0o644); err != nil {
+		t.Fatalf("Failed to write the suite: %v", err)
+	}
+	if passed, err := RunPerftSuite(path, PerftOptions{Depth: 3, HashMB: 1}); err != nil || !passed {
+		t.Errorf("Expected the suite to pass to depth 3 (error: %v)", err)
+	}
+	if passed, err := RunPerftSuite(path, PerftOptions{HashMB: 1}); err != nil || passed {
+		t.Errorf("Expected the suite to fail at depth 4 (error: %v)", err)
+	}
+
+	if err := os.WriteFile(path, []byte("4k3/8/8/8/8/8/8/4K2R w K - ;D1 fifteen\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write the suite: %v", err)
+	}
+	if _, err := ReadPerftSuite(path); err == nil {
+		t.Errorf("Expected an error for an invalid count")
+	}
+}
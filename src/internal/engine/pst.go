@@ -1,7 +1,8 @@
 package engine
 
 /*
-This file holds the global Piece-Square Tables (psts) for the evaluation function
+This file holds the hand-picked Piece-Square Tables (psts) for the evaluation function
+These are the defaults of the pst weights in EvalParams, see defaultEvalParams() in params.go
 */
 
 /*
@@ -169,43 +170,3 @@ var pstKingEndgame = [NUM_SQUARES]Eval{
 	370, 400, 430, 450, 450, 430, 400, 370,
 	340, 370, 400, 420, 420, 400, 370, 340,
 }
-
-// Takes a white table and returns the black equivalent (flipped vertically)
-func flipPST(table [NUM_SQUARES]Eval) [NUM_SQUARES]Eval {
-	var newTable [NUM_SQUARES]Eval
-	for i := 0; i < 64; i++ {
-		// XOR 56 flips the rank (0->7, 1->6, etc)
-		newTable[i^56] = table[i]
-	}
-	return newTable
-}
-
-// Master PST
-var PST [2][NUM_COLORS][NUM_PIECES][NUM_SQUARES]Eval
-
-// Init pst tables, to be called on engine startup
-func initPST() {
-	// Define White's Tables
-	// OPENING
-	PST[OPENING][WHITE][PAWN] = pstPawnOpening
-	PST[OPENING][WHITE][KNIGHT] = pstKnightOpening
-	PST[OPENING][WHITE][BISHOP] = pstBishopOpening
-	PST[OPENING][WHITE][ROOK] = pstRookOpening
-	PST[OPENING][WHITE][QUEEN] = pstQueenOpening
-	PST[OPENING][WHITE][KING] = pstKingOpening
-
-	// ENDGAME
-	PST[ENDGAME][WHITE][PAWN] = pstPawnEndgame
-	PST[ENDGAME][WHITE][KNIGHT] = pstKnightEndgame
-	PST[ENDGAME][WHITE][BISHOP] = pstBishopEndgame
-	PST[ENDGAME][WHITE][ROOK] = pstRookEndgame
-	PST[ENDGAME][WHITE][QUEEN] = pstQueenEndgame
-	PST[ENDGAME][WHITE][KING] = pstKingEndgame
-
-	// Generate Black's tables by flipping White's
-	for state := range 2 {
-		for piece := range int(NUM_PIECES) {
-			PST[state][BLACK][piece] = flipPST(PST[state][WHITE][piece])
-		}
-	}
-}
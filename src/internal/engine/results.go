@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+This file holds the results store for the benchmark and strength tests.
+Every run is saved to a JSON file keyed by the git revision and configuration it was run with,
+so a change can be compared against the previous build instead of eyeballing two terminals.
+*/
+
+// Suite names used to key the stored runs
+const (
+	SUITE_BENCHMARK = "benchmark"
+	SUITE_STRENGTH  = "strengthtest"
+)
+
+// The result of the engine on a single position of a suite
+type PositionResult struct {
+	ID     string `json:"id"`
+	Theme  string `json:"theme"`
+	Move   string `json:"move"`
+	Eval   Eval   `json:"eval"`
+	Points int    `json:"points"`
+	Best   bool   `json:"best"`
+	Nodes  int    `json:"nodes"`
+	TimeMs int64  `json:"timeMs"`
+}
+
+// A full run of a suite, for one revision and configuration
+type TestRun struct {
+	Suite     string           `json:"suite"`
+	Revision  string           `json:"revision"`
+	Config    string           `json:"config"`
+	Timestamp time.Time        `json:"timestamp"`
+	Positions []PositionResult `json:"positions"`
+}
+
+// Totals of a run, used when printing and comparing runs
+type TestRunTotals struct {
+	Points    int
+	BestMoves int
+	Nodes     int
+	TimeMs    int64
+}
+
+// Sum up the results of all positions in the run
+func (r *TestRun) totals() TestRunTotals {
+	var totals TestRunTotals
+	for _, p := range r.Positions {
+		totals.Points += p.Points
+		totals.Nodes += p.Nodes
+		totals.TimeMs += p.TimeMs
+		if p.Best {
+			totals.BestMoves++
+		}
+	}
+	return totals
+}
+
+// Nodes per second of the totals, 0 if no time was recorded
+func (t TestRunTotals) nps() float64 {
+	if t.TimeMs == 0 {
+		return 0
+	}
+	return float64(t.Nodes) / (float64(t.TimeMs) / 1000.0)
+}
+
+// Points of the run, grouped by the theme of the positions
+func (r *TestRun) themePoints() map[string]int {
+	points := make(map[string]int)
+	for _, p := range r.Positions {
+		points[p.Theme] += p.Points
+	}
+	return points
+}
+
+// Get the theme of a test from its id, the STS ids are of the form "Undermine.001"
+func themeFromID(id string) string {
+	idx := strings.LastIndex(id, ".")
+	if idx == -1 {
+		return id
+	}
+	return id[:idx]
+}
+
+// Load all the runs saved in the results file
+// A missing file is not an error, it just means no runs have been saved yet
+func LoadTestRuns(path string) ([]TestRun, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []TestRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("Invalid results file %v: %w", path, err)
+	}
+	return runs, nil
+}
+
+// Save a run into the results file
+// A run with the same suite, revision and configuration is replaced, so re-running a build keeps one entry
+func SaveTestRun(path string, run *TestRun) error {
+	runs, err := LoadTestRuns(path)
+	if err != nil {
+		return err
+	}
+
+	runs = slices.DeleteFunc(runs, func(r TestRun) bool {
+		return r.Suite == run.Suite && r.Revision == run.Revision && r.Config == run.Config
+	})
+	runs = append(runs, *run)
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Find a saved run by its key
+func FindTestRun(runs []TestRun, suite, revision, config string) (*TestRun, error) {
+	for i := range runs {
+		if runs[i].Suite == suite && runs[i].Revision == revision && runs[i].Config == config {
+			return &runs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("No %v run found for revision %v with config %v", suite, revision, config)
+}
+
+// CompareTestRuns prints the difference between a base run and a head run
+// It returns true if the head run regressed the suite (fewer points or fewer best moves)
+func CompareTestRuns(base, head *TestRun) bool {
+	baseTotals := base.totals()
+	headTotals := head.totals()
+
+	fmt.Printf("Comparing %v runs\n", base.Suite)
+	fmt.Printf("Base: %v (%v) on %v\n", base.Revision, base.Config, base.Timestamp.Format(time.RFC3339))
+	fmt.Printf("Head: %v (%v) on %v\n\n", head.Revision, head.Config, head.Timestamp.Format(time.RFC3339))
+
+	// Points per theme
+	basePoints := base.themePoints()
+	headPoints := head.themePoints()
+	themes := make([]string, 0, len(basePoints))
+	for theme := range basePoints {
+		themes = append(themes, theme)
+	}
+	for theme := range headPoints {
+		if _, ok := basePoints[theme]; !ok {
+			themes = append(themes, theme)
+		}
+	}
+	slices.Sort(themes)
+
+	fmt.Printf("---------------------\nPoints per theme\n---------------------\n")
+	for _, theme := range themes {
+		fmt.Printf("%-45v %6d -> %6d (%+d)\n", theme, basePoints[theme], headPoints[theme], headPoints[theme]-basePoints[theme])
+	}
+	fmt.Printf("%-45v %6d -> %6d (%+d)\n\n", "Total", baseTotals.Points, headTotals.Points, headTotals.Points-baseTotals.Points)
+
+	// Best move rate, nodes and speed
+	baseRate := 0.0
+	if len(base.Positions) > 0 {
+		baseRate = float64(baseTotals.BestMoves) / float64(len(base.Positions)) * 100
+	}
+	headRate := 0.0
+	if len(head.Positions) > 0 {
+		headRate = float64(headTotals.BestMoves) / float64(len(head.Positions)) * 100
+	}
+	fmt.Printf("---------------------\nTotals\n---------------------\n")
+	fmt.Printf("Best move rate: %.2f%% -> %.2f%% (%+.2f)\n", baseRate, headRate, headRate-baseRate)
+	fmt.Printf("Total nodes: %d -> %d (%+d)\n", baseTotals.Nodes, headTotals.Nodes, headTotals.Nodes-baseTotals.Nodes)
+	fmt.Printf("Mn/s: %.3f -> %.3f\n\n", baseTotals.nps()/1_000_000.0, headTotals.nps()/1_000_000.0)
+
+	// Positions that flipped between correct and incorrect
+	baseByID := make(map[string]PositionResult, len(base.Positions))
+	for _, p := range base.Positions {
+		baseByID[p.ID] = p
+	}
+	lost := make([]string, 0)
+	gained := make([]string, 0)
+	for _, p := range head.Positions {
+		b, ok := baseByID[p.ID]
+		if !ok {
+			continue
+		}
+		if b.Best && !p.Best {
+			lost = append(lost, fmt.Sprintf("%v: %v -> %v (%d -> %d points)", p.ID, b.Move, p.Move, b.Points, p.Points))
+		}
+		if !b.Best && p.Best {
+			gained = append(gained, fmt.Sprintf("%v: %v -> %v (%d -> %d points)", p.ID, b.Move, p.Move, b.Points, p.Points))
+		}
+	}
+
+	fmt.Printf("---------------------\nFlipped from correct to incorrect (%d)\n---------------------\n", len(lost))
+	for _, l := range lost {
+		fmt.Println(l)
+	}
+	fmt.Printf("\n---------------------\nFlipped from incorrect to correct (%d)\n---------------------\n", len(gained))
+	for _, g := range gained {
+		fmt.Println(g)
+	}
+	fmt.Println()
+
+	regressed := headTotals.Points < baseTotals.Points || headTotals.BestMoves < baseTotals.BestMoves
+	if regressed {
+		fmt.Println("Result: REGRESSION")
+	} else {
+		fmt.Println("Result: no regression")
+	}
+
+	return regressed
+}
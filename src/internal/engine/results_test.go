@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestThemeFromID(t *testing.T) {
+	// Tests setup to be run
+	tests := []struct {
+		name   string
+		id     string
+		result string
+	}{
+		{
+			name:   "STS1 id",
+			id:     "Undermine.001",
+			result: "Undermine",
+		},
+		{
+			name:   "STS2 id with version and spaces",
+			id:     "STS(v2.2) Open Files and Diagonals.001",
+			result: "STS(v2.2) Open Files and Diagonals",
+		},
+		{
+			name:   "Id without a number",
+			id:     "Position",
+			result: "Position",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := themeFromID(tc.id); result != tc.result {
+				t.Errorf("themeFromID(%q) = %q, expected %q", tc.id, result, tc.result)
+			}
+		})
+	}
+}
+
+func TestSaveTestRunReplacesSameKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+
+	// Save two runs for different revisions, then re-run the first revision
+	first := &TestRun{Suite: SUITE_BENCHMARK, Revision: "aaa", Config: "default", Positions: []PositionResult{{ID: "Undermine.001", Points: 10, Best: true}}}
+	second := &TestRun{Suite: SUITE_BENCHMARK, Revision: "bbb", Config: "default", Positions: []PositionResult{{ID: "Undermine.001", Points: 3}}}
+	rerun := &TestRun{Suite: SUITE_BENCHMARK, Revision: "aaa", Config: "default", Positions: []PositionResult{{ID: "Undermine.001", Points: 5}}}
+	for _, run := range []*TestRun{first, second, rerun} {
+		if err := SaveTestRun(path, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := LoadTestRuns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs saved, found %d", len(runs))
+	}
+
+	run, err := FindTestRun(runs, SUITE_BENCHMARK, "aaa", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Positions[0].Points != 5 {
+		t.Errorf("Expected the re-run to replace the first run, found %d points", run.Positions[0].Points)
+	}
+
+	if _, err := FindTestRun(runs, SUITE_BENCHMARK, "aaa", "other"); err == nil {
+		t.Errorf("Expected no run for an unknown config")
+	}
+}
+
+func TestCompareTestRunsDetectsRegression(t *testing.T) {
+	base := &TestRun{Suite: SUITE_BENCHMARK, Positions: []PositionResult{
+		{ID: "Undermine.001", Theme: "Undermine", Points: 10, Best: true},
+		{ID: "Undermine.002", Theme: "Undermine", Points: 3},
+	}}
+	head := &TestRun{Suite: SUITE_BENCHMARK, Positions: []PositionResult{
+		{ID: "Undermine.001", Theme: "Undermine", Points: 2},
+		{ID: "Undermine.002", Theme: "Undermine", Points: 3},
+	}}
+
+	if !CompareTestRuns(base, head) {
+		t.Errorf("Expected losing a best move to be a regression")
+	}
+	if CompareTestRuns(head, base) {
+		t.Errorf("Expected gaining a best move to not be a regression")
+	}
+}
@@ -1,9 +1,257 @@
 package engine
 
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
 /*
 This file contains all the code related to searching
 */
 
+// Options that control how the engine searches a position
+type SearchOptions struct {
+	// The maximum depth to search to
+	Depth uint8
+
+	// The time limit of the search, 0 means no limit
+	// With a time limit the search deepens iteratively and returns the last depth it completed
+	MoveTime time.Duration
+
+	// The most nodes to search, 0 means no limit
+	// Like the time limit, the first depth of an iterative search is always completed
+	Nodes int
+
+	// Turns off late move reduction, useful for testing if it gains strength
+	DisableLMR bool
+
+	// The Elo to play at with a skill level, 0 (or an Elo above the skill levels) plays at full strength
+	Elo int
+
+	// Evaluation parameter file to play with in a match, the engine's parameters are used when empty
+	EvalFile   string
+	evalParams *EvalParams
+
+	// The personality to play with (see PERSONALITIES), over the evaluation parameters
+	Personality string
+
+	// How much worse than equal a draw is for the engine, in centipawns
+	// Positive contempt avoids repetitions and stalemates unless the engine is losing by more, negative contempt seeks them
+	Contempt int
+
+	// Stops the search from another goroutine, set when pondering (see StartPonder)
+	control *searchControl
+}
+
+// The default options the engine searches with
+var DEFAULT_SEARCH_OPTIONS = SearchOptions{
+	Depth: 7,
+}
+
+// Parse search options from a comma separated list of key=value pairs, starting from the defaults
+// Ex. "depth=5,movetime=200,lmr=false" searches to depth 5, for at most 200ms, without late move reduction
+// An "eval=params.json" option evaluates with a parameter file, to test tuned parameters against the current ones
+// A "personality=aggressive" option plays with a personality, over the parameters
+func ParseSearchOptions(spec string) (SearchOptions, error) {
+	options := DEFAULT_SEARCH_OPTIONS
+	for pair := range strings.SplitSeq(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return options, fmt.Errorf("Invalid search option %q; Should be key=value", pair)
+		}
+
+		switch key {
+		case "depth":
+			depth, err := strconv.Atoi(value)
+			if err != nil || depth < 1 || depth > 10 {
+				return options, fmt.Errorf("Invalid search option %q; Depth should be between 1 and 10", pair)
+			}
+			options.Depth = uint8(depth)
+		case "movetime":
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms < 0 {
+				return options, fmt.Errorf("Invalid search option %q; Move time should be a number of milliseconds", pair)
+			}
+			options.MoveTime = time.Duration(ms) * time.Millisecond
+		case "nodes":
+			nodes, err := strconv.Atoi(value)
+			if err != nil || nodes < 0 {
+				return options, fmt.Errorf("Invalid search option %q; Nodes should be a positive number", pair)
+			}
+			options.Nodes = nodes
+		case "elo":
+			elo, err := strconv.Atoi(value)
+			if err != nil || elo < 0 {
+				return options, fmt.Errorf("Invalid search option %q; Elo should be a positive number", pair)
+			}
+			options.Elo = elo
+		case "lmr":
+			lmr, err := strconv.ParseBool(value)
+			if err != nil {
+				return options, fmt.Errorf("Invalid search option %q; LMR should be true or false", pair)
+			}
+			options.DisableLMR = !lmr
+		case "eval":
+			params, err := LoadEvalParams(value)
+			if err != nil {
+				return options, err
+			}
+			options.EvalFile = value
+			options.evalParams = params
+		case "contempt":
+			contempt, err := strconv.Atoi(value)
+			if err != nil || contempt < -MAX_CONTEMPT || contempt > MAX_CONTEMPT {
+				return options, fmt.Errorf("Invalid search option %q; Contempt should be between -%d and %d", pair, MAX_CONTEMPT, MAX_CONTEMPT)
+			}
+			options.Contempt = contempt
+		case "personality":
+			if _, err := ParsePersonality(value); err != nil {
+				return options, err
+			}
+			options.Personality = strings.ToLower(strings.TrimSpace(value))
+		default:
+			return options, fmt.Errorf("Invalid search option %q; Unknown option %v", pair, key)
+		}
+	}
+
+	return options, nil
+}
+
+// Format the options the same way they are parsed
+func (o SearchOptions) String() string {
+	spec := fmt.Sprintf("depth=%d", o.Depth)
+	if o.MoveTime > 0 {
+		spec += fmt.Sprintf(",movetime=%d", o.MoveTime.Milliseconds())
+	}
+	if o.Nodes > 0 {
+		spec += fmt.Sprintf(",nodes=%d", o.Nodes)
+	}
+	if o.DisableLMR {
+		spec += ",lmr=false"
+	}
+	if o.Elo > 0 {
+		spec += fmt.Sprintf(",elo=%d", o.Elo)
+	}
+	if o.EvalFile != "" {
+		spec += ",eval=" + o.EvalFile
+	}
+	if o.Personality != "" {
+		spec += ",personality=" + o.Personality
+	}
+	if o.Contempt != 0 {
+		spec += fmt.Sprintf(",contempt=%d", o.Contempt)
+	}
+	return spec
+}
+
+// The largest contempt, a draw is never scored like a won or lost position
+const MAX_CONTEMPT = 500
+
+// How many nodes are searched between checks of the clock
+const TIME_CHECK_INTERVAL = 2048
+
+// The state of a single search, threaded through the recursive search
+// This is allocated once at the start of the search
+type SearchState struct {
+	moveStack     []MoveList
+	killers       Killers
+	cutoffHistory CutoffHeuristic
+	options       SearchOptions
+
+	// The evaluation parameters of the search, and their key
+	params    *EvalParams
+	paramsKey ZobristHash
+
+	// XORed into the TT keys, so the evals of other parameters and other contempt are not mixed up (see ttKey)
+	ttKey ZobristHash
+
+	// Draws are scored by the contempt from the perspective of the side to move at the root
+	contempt  Eval
+	rootColor Color
+
+	// Stops the search when set from another goroutine
+	control *searchControl
+
+	// Used to stop the search when the time or node limit is reached
+	deadline  time.Time
+	timeCheck int
+	nodeLimit int
+	nodes     int
+	stopped   bool
+}
+
+// Allocate the state for a new search
+func newSearchState(options SearchOptions) *SearchState {
+	return &SearchState{
+		moveStack: make([]MoveList, MAX_PLY),
+		options:   options,
+		control:   options.control,
+		contempt:  options.searchContempt(),
+	}
+}
+
+// Get the contempt of the options, with the contempt of their personality
+func (o SearchOptions) searchContempt() Eval {
+	return Eval(o.Contempt + PERSONALITIES[o.Personality].Contempt)
+}
+
+// Get the key XORed into the TT keys of a search, the key of its parameters and, with contempt, of the root side
+// Draws are scored from the root side, so with contempt the entries of the two sides are kept apart in the TT
+func ttKey(paramsKey ZobristHash, contempt Eval, rootColor Color) ZobristHash {
+	if contempt == 0 {
+		return paramsKey
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte{byte(contempt), byte(contempt >> 8), byte(rootColor)})
+	return paramsKey ^ ZobristHash(hash.Sum64())
+}
+
+// The eval of a draw for the side to move
+// A draw is worth -contempt to the root side, and so +contempt to its opponent, keeping the sign right through negamax
+func (s *SearchState) drawEval(turn Color) Eval {
+	if turn == s.rootColor {
+		return -s.contempt
+	}
+	return s.contempt
+}
+
+// Check if the search ran out of time or nodes, only looking at the clock every TIME_CHECK_INTERVAL calls
+func (s *SearchState) checkTime() bool {
+	if s.stopped {
+		return true
+	}
+	if s.control != nil && s.control.stop.Load() {
+		s.stopped = true
+		return true
+	}
+	s.nodes++
+	if s.nodeLimit > 0 && s.nodes > s.nodeLimit {
+		s.stopped = true
+		return true
+	}
+	if s.deadline.IsZero() {
+		return false
+	}
+
+	s.timeCheck++
+	if s.timeCheck < TIME_CHECK_INTERVAL {
+		return false
+	}
+	s.timeCheck = 0
+	if time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
 // Root search is the starting search for the chess engine, before it goes into its alpha-beta-negamax
 // Here certain setup steps can take place, like multi-threading, if needed outside the main recursion
 // It also handles validating search safety, so a depth of like 100 isn't run on the engine
@@ -14,6 +262,43 @@ type RootSearchResult struct {
 }
 
 func (b *Board) rootSearch(depth uint8, multithread bool) RootSearchResult {
+	options := DEFAULT_SEARCH_OPTIONS
+	options.Depth = depth
+	return b.searchRoot(depth, newSearchState(options))
+}
+
+// Iterative search deepens one ply at a time up to the depth of the options, until the time limit is reached
+// The TT move from the previous depth is searched first, which keeps deeper searches cheap
+// It returns the results of the last depth that completed
+func (b *Board) iterativeSearch(options SearchOptions) RootSearchResult {
+	options = options.limitedBySkill()
+	s := newSearchState(options)
+
+	var result RootSearchResult
+	for depth := uint8(1); depth <= max(options.Depth, 1); depth++ {
+		// The first depth is always completed, so there is always a move to play
+		if depth == 2 && options.MoveTime > 0 {
+			s.deadline = time.Now().Add(options.MoveTime)
+		}
+		if depth == 2 && options.Nodes > 0 {
+			s.nodeLimit = s.nodes + options.Nodes
+		}
+
+		depthResult := b.searchRoot(depth, s)
+		if s.stopped {
+			result.nodes += depthResult.nodes
+			break
+		}
+
+		depthResult.nodes += result.nodes
+		result = depthResult
+	}
+
+	return result
+}
+
+// Search all the root moves to a fixed depth
+func (b *Board) searchRoot(depth uint8, s *SearchState) RootSearchResult {
 
 	// Validate depth is reasonable
 	if depth == 0 {
@@ -22,17 +307,23 @@ func (b *Board) rootSearch(depth uint8, multithread bool) RootSearchResult {
 		depth = 10
 	}
 
-	// Allocate the moveStack
-	moveStack := make([][]Move, MAX_PLY)
-	for i := range moveStack {
-		moveStack[i] = make([]Move, 256)
+	// Evaluate with the parameters of the search, rebuilding the incremental eval with them
+	// A personality plays the side searching, so the parameters are built for it on the first depth
+	if s.params == nil {
+		s.params, s.paramsKey = s.options.searchParams(b.Turn)
 	}
+	b.params, b.paramsKey = s.params, s.paramsKey
+	b.initEvalState()
 
-	// Setting up the killer moves
-	var killers Killers
+	// Draws are scored from the perspective of the side searching
+	s.rootColor = b.Turn
+	s.ttKey = ttKey(s.paramsKey, s.contempt, s.rootColor)
 
-	// Setting up the history cutoff heuristic
-	var cutoffHistory CutoffHeuristic
+	// Every move from a tablebase position reaches the same tablebase or one it captures into,
+	// so the root moves are scored exactly by probing them and there is no need to search deeper
+	if _, ok := b.tablebaseEval(0); ok {
+		depth = 1
+	}
 
 	// Setup the search
 	nodes := 1
@@ -43,23 +334,18 @@ func (b *Board) rootSearch(depth uint8, multithread bool) RootSearchResult {
 
 	// Check the TT table
 	// This is not to prevent the entire root search, but to help move ordering
-	var ttEntry *TTEntry = nil
-
-	// Compute the TT key
-	key := b.Zobrist & (TT_SIZE - 1)
-
-	// Get the entry and return hit
-	entry := &TT[key]
-	if entry.zobrist == b.Zobrist {
-		ttEntry = entry
-	}
+	ttEntry := probeTT(b.Zobrist ^ s.ttKey)
 
 	// Generate the pseudo legal moves to play, populating this depths move in the movestack
-	moves := moveStack[ply]
-	numberOfMoves := b.generatePseudoLegalMovesWithOrdering(moves, ttEntry, nil, nil, nil)
-	results := make([]MoveEval, 0, numberOfMoves)
+	moves := &s.moveStack[ply]
+	b.generatePseudoLegalMovesWithOrdering(moves, ttEntry, nil, nil, nil)
+
+	// In the Syzygy tablebases, only the moves keeping the best result are searched
+	moves.count = b.filterSyzygyRootMoves(moves.slice())
+	results := make([]MoveEval, 0, moves.count)
+	bestMove := NO_MOVE
 	legalMovesFound := false
-	for _, move := range moves[:numberOfMoves] {
+	for _, move := range moves.slice() {
 
 		// Make the move and see if it was legal
 		unmake, isLegal := b.makeMove(move)
@@ -70,22 +356,36 @@ func (b *Board) rootSearch(depth uint8, multithread bool) RootSearchResult {
 
 		// Search the new position and get the results
 		legalMovesFound = true
-		result := b.abnegamax(ply+1, depth-1, -beta, -alpha, moveStack, &killers, &cutoffHistory)
+		result := b.abnegamax(ply+1, depth-1, -beta, -alpha, s)
 		b.unMakeMove(unmake)
+		nodes += result.nodes
+
+		// If the search was stopped the result can not be trusted
+		if s.stopped {
+			break
+		}
+
 		resultEval := -result.best.eval
 		results = append(results, MoveEval{
 			eval: resultEval,
 			move: move,
 		})
-		nodes += result.nodes
 		if resultEval > bestEval {
 			bestEval = resultEval
-			if resultEval > alpha {
+			bestMove = move
+
+			// A skill level picks between the best few moves, so every root move is searched with the full window to get its exact eval
+			if resultEval > alpha && !s.options.hasSkill() {
 				alpha = resultEval
 			}
 		}
 	}
 
+	// Store the best root move, so the next iteration of an iterative search searches it first
+	if legalMovesFound && !s.stopped {
+		updateTT(b.Zobrist^s.ttKey, bestEval, TT_EXACT, depth, bestMove)
+	}
+
 	// Handle checkmate/stalemate
 	if !legalMovesFound {
 		// If not in check, then stalement, else MIN_EVAL is correct
@@ -107,7 +407,12 @@ type SearchResult struct {
 	best  MoveEval
 }
 
-func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack [][]Move, killers *Killers, cutoffHistory *CutoffHeuristic) SearchResult {
+func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, s *SearchState) SearchResult {
+
+	// Stop searching if the time limit was reached, the caller will throw away the result
+	if s.checkTime() {
+		return SearchResult{nodes: 1}
+	}
 
 	// checking for 3-fold repition
 	// if it is, the game is a draw
@@ -115,27 +420,46 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 		return SearchResult{
 			nodes: 1,
 			best: MoveEval{
-				move: Move{},
-				eval: Eval(0),
+				move: NO_MOVE,
+				eval: s.drawEval(b.Turn),
 			},
 		}
 	}
 
+	// King and pawn against king is solved by the bitbase, so there is nothing to search
+	if b.isKPK() {
+		eval, _ := b.endgameEval()
+		if b.Turn == BLACK {
+			eval = -eval
+		}
+		return SearchResult{
+			nodes: 1,
+			best:  MoveEval{eval: eval},
+		}
+	}
+
+	// Positions in a tablebase are solved, so there is nothing to search
+	if eval, ok := b.tablebaseEval(ply); ok {
+		return SearchResult{
+			nodes: 1,
+			best:  MoveEval{eval: eval},
+		}
+	}
+
+	// Positions in the Syzygy tablebases are solved too, but only their result is known
+	if eval, ok := b.syzygyEval(ply); ok {
+		return SearchResult{
+			nodes: 1,
+			best:  MoveEval{eval: eval},
+		}
+	}
+
 	// saving original alpha for TT tables
 	originalAlpha := alpha
 	originalBeta := beta
 
 	// Check the TT table
-	var ttEntry *TTEntry = nil
-
-	// Compute the TT key
-	key := b.Zobrist & (TT_SIZE - 1)
-
-	// Get the entry and return hit
-	entry := &TT[key]
-	if entry.zobrist == b.Zobrist {
-		ttEntry = entry
-	}
+	ttEntry := probeTT(b.Zobrist ^ s.ttKey)
 
 	// Check if tt was found and was depth of equal or greater
 	if ttEntry != nil && ttEntry.depth >= depth {
@@ -188,26 +512,26 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 
 	// If at base condition, quiescence search
 	if depth == 0 {
-		return b.quiescence(ply+1, alpha, beta, moveStack)
+		return b.quiescence(ply+1, alpha, beta, s.moveStack)
 	}
 
 	// Setup the search
 	nodes := 1
 	bestEval := MIN_EVAL
-	bestMove := Move{}
+	bestMove := NO_MOVE
 
 	// Two ply killers are killer moves from the previous position for this color
 	var twoPlyKillers *[2]Move
 	if ply >= 2 {
-		twoPlyKillers = &(*killers)[ply-1]
+		twoPlyKillers = &s.killers[ply-1]
 	}
-	thisKillers := (*killers)[ply]
+	thisKillers := s.killers[ply]
 
 	// Generate the pseudo legal moves to play, populating this plys move in the movestack
-	moves := moveStack[ply]
-	numberOfMoves := b.generatePseudoLegalMovesWithOrdering(moves, ttEntry, &thisKillers, twoPlyKillers, cutoffHistory)
+	moves := &s.moveStack[ply]
+	b.generatePseudoLegalMovesWithOrdering(moves, ttEntry, &thisKillers, twoPlyKillers, &s.cutoffHistory)
 	legalMovesFound := false
-	for i, move := range moves[:numberOfMoves] {
+	for i, move := range moves.slice() {
 
 		// Make the move and see if it was legal
 		unmake, isLegal := b.makeMove(move)
@@ -220,7 +544,7 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 		// Speeds up search 10x, costs 0.80 points on the benchmark test
 		betaSearch := beta
 		reduction := uint8(0)
-		if i > 10 && depth > 2 && move.code != MOVE_CODE_CAPTURE && move != thisKillers[0] && move != thisKillers[1] {
+		if !s.options.DisableLMR && i > 10 && depth > 2 && move.code() != MOVE_CODE_CAPTURE && move != thisKillers[0] && move != thisKillers[1] {
 			reduction = 1
 			betaSearch = alpha + 1
 
@@ -231,16 +555,21 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 
 		// Search the new position and get the results
 		legalMovesFound = true
-		result := b.abnegamax(ply+1, depth-1-reduction, -betaSearch, -alpha, moveStack, killers, cutoffHistory)
+		result := b.abnegamax(ply+1, depth-1-reduction, -betaSearch, -alpha, s)
 		resultEval := -result.best.eval
 		nodes += result.nodes
 
 		// If the engine reduced and the engine exceeded alpha, the engine needs to research at a full depth
 		if resultEval > alpha && resultEval < beta && reduction > 0 {
-			result = b.abnegamax(ply+1, depth-1, -beta, -alpha, moveStack, killers, cutoffHistory)
+			result = b.abnegamax(ply+1, depth-1, -beta, -alpha, s)
 		}
 
 		b.unMakeMove(unmake)
+
+		// If the search was stopped the result can not be trusted, and must not be stored in the TT
+		if s.stopped {
+			return SearchResult{nodes: nodes}
+		}
 		if resultEval > bestEval {
 			bestEval = resultEval
 			bestMove = move
@@ -256,14 +585,14 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 
 			// Update killers
 			// Make sure it is not a capture
-			if move.code != MOVE_CODE_CAPTURE && move.code != MOVE_CODE_EN_PASSANT {
-				if killers[ply][0] != move {
-					killers[ply][1] = killers[ply][0]
-					killers[ply][0] = move
+			if move.code() != MOVE_CODE_CAPTURE && move.code() != MOVE_CODE_EN_PASSANT {
+				if s.killers[ply][0] != move {
+					s.killers[ply][1] = s.killers[ply][0]
+					s.killers[ply][0] = move
 				}
 
 				// Update history of cutoffs as well (if not capture)
-				cutoffHistory[b.Turn][move.start][move.target] += int(depth) * int(depth)
+				s.cutoffHistory[b.Turn][move.fromTo()] += int(depth) * int(depth)
 			}
 			break
 		}
@@ -273,7 +602,7 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 	if !legalMovesFound {
 		// If not in check, then stalement
 		if !b.isInCheck(b.Turn) {
-			bestEval = 0
+			bestEval = s.drawEval(b.Turn)
 		} else {
 			// If is in check, then take the MIN_EVAL and add the ply to it to prioritize faster mates
 			bestEval += Eval(ply)
@@ -291,7 +620,7 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 			ttFlag = TT_EXACT
 		}
 		// Store the value in the TT table
-		updateTT(b.Zobrist, bestEval, ttFlag, depth, bestMove)
+		updateTT(b.Zobrist^s.ttKey, bestEval, ttFlag, depth, bestMove)
 	}
 
 	return SearchResult{
@@ -306,7 +635,7 @@ func (b *Board) abnegamax(ply uint8, depth uint8, alpha, beta Eval, moveStack []
 // quiescence is the final search for a "quiet" position the engine takes, after reaching the base condition of abnegamax
 // A quiet position is one without any captures
 // todo: should be upgraded to check for checks as well
-func (b *Board) quiescence(ply uint8, alpha, beta Eval, moveStack [][]Move) SearchResult {
+func (b *Board) quiescence(ply uint8, alpha, beta Eval, moveStack []MoveList) SearchResult {
 
 	// First, evalute the stand pat score of the position, the evaluation before doing any more captures
 	standPat := b.eval()
@@ -331,7 +660,7 @@ func (b *Board) quiescence(ply uint8, alpha, beta Eval, moveStack [][]Move) Sear
 	// check ply, if it exceeds or equals MAX_PLY then just evalute
 	// this is just a safety net against really weird conditions, very unlikely to happen
 	nodes := 1
-	bestMove := Move{}
+	bestMove := NO_MOVE
 	if ply >= MAX_PLY {
 		return SearchResult{
 			nodes: 1,
@@ -342,18 +671,18 @@ func (b *Board) quiescence(ply uint8, alpha, beta Eval, moveStack [][]Move) Sear
 		}
 	}
 
-	moves := moveStack[ply]
-	numberOfMoves := b.generatePseudoLegalMovesWithOrdering(moves, nil, nil, nil, nil)
-	for _, move := range moves[:numberOfMoves] {
+	moves := &moveStack[ply]
+	b.generatePseudoLegalMovesWithOrdering(moves, nil, nil, nil, nil)
+	for _, move := range moves.slice() {
 
 		// Make sure the move was a capture
-		if move.code != MOVE_CODE_CAPTURE || move.code == MOVE_CODE_EN_PASSANT {
+		if move.code() != MOVE_CODE_CAPTURE || move.code() == MOVE_CODE_EN_PASSANT {
 			continue
 		}
 
 		// Delta pruning
 		// If the capture for free, plus stand pat and a margin does not exceed alpha, do not search
-		if standPat+PIECE_VALUES[b.MailBox[move.target]]+DELTA_MARGIN <= alpha {
+		if standPat+PIECE_VALUES[b.MailBox[move.target()]]+DELTA_MARGIN <= alpha {
 			continue
 		}
 
@@ -397,7 +726,7 @@ type PerftNegamaxResult struct {
 	nodes int
 }
 
-func (b *Board) perftNegamax(depth uint8, moveStack [][]Move) PerftNegamaxResult {
+func (b *Board) perftNegamax(depth uint8, moveStack []MoveList) PerftNegamaxResult {
 
 	// Reaching a terminal condition
 	if depth == 0 {
@@ -408,10 +737,10 @@ func (b *Board) perftNegamax(depth uint8, moveStack [][]Move) PerftNegamaxResult
 
 	// Generate the pseudo legal moves to play, populating this depths move in the movestack
 	nodes := 0
-	moves := moveStack[depth]
-	numberOfMoves := b.generatePseudoLegalMoves(moves)
+	moves := &moveStack[depth]
+	b.generatePseudoLegalMoves(moves)
 
-	for _, move := range moves[:numberOfMoves] {
+	for _, move := range moves.slice() {
 		unmake, isLegal := b.makeMove(move)
 		if !isLegal {
 			b.unMakeMove(unmake)
@@ -0,0 +1,79 @@
+package engine
+
+import "testing"
+
+func TestContempt(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected eval of the root move that stalemates, from the side to move
+	// Qc7 stalemates the black king, and Qc8 (from black's side) the white king
+	tests := []struct {
+		fen         FEN
+		move        string
+		contempt    int
+		personality string
+		expected    Eval
+	}{
+		{fen: "k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", move: "c1c7", contempt: 0, expected: 0},
+		{fen: "k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", move: "c1c7", contempt: 100, expected: -100},
+		{fen: "k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", move: "c1c7", contempt: -100, expected: 100},
+		{fen: "k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", move: "c1c7", contempt: 20, personality: "aggressive", expected: -50},
+		{fen: "2q5/8/8/8/8/1k6/8/K7 b - - 0 1", move: "c8c2", contempt: 100, expected: -100},
+	}
+
+	for _, tc := range tests {
+		board, err := tc.fen.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Failed to build %v: %v", tc.fen, err)
+		}
+
+		options := DEFAULT_SEARCH_OPTIONS
+		options.Contempt = tc.contempt
+		options.Personality = tc.personality
+		ClearTT()
+		result := board.searchRoot(2, newSearchState(options))
+
+		found := false
+		for _, moveEval := range result.moves {
+			if moveEval.move.toPCN() != tc.move {
+				continue
+			}
+			found = true
+			if moveEval.eval != tc.expected {
+				t.Errorf("%v contempt %d %v: Expected %v to be scored %d, got %d", tc.fen, tc.contempt, tc.personality, tc.move, tc.expected, moveEval.eval)
+			}
+		}
+		if !found {
+			t.Errorf("%v: Expected %v to be searched", tc.fen, tc.move)
+		}
+	}
+	ClearTT()
+
+	// A draw is worth the opposite to each side
+	s := newSearchState(SearchOptions{Contempt: 30})
+	s.rootColor = BLACK
+	if s.drawEval(BLACK) != -30 || s.drawEval(WHITE) != 30 {
+		t.Errorf("Expected a draw to be -30 for the root side and 30 for the other, got %d and %d", s.drawEval(BLACK), s.drawEval(WHITE))
+	}
+
+	// With contempt the draws in the TT depend on the root side, so each side has its own entries
+	if ttKey(0, 0, WHITE) != 0 || ttKey(0, 0, BLACK) != 0 {
+		t.Errorf("Expected no key without contempt, so the entries are shared by both sides")
+	}
+	if ttKey(0, 30, WHITE) == ttKey(0, 30, BLACK) || ttKey(0, 30, WHITE) == ttKey(0, 40, WHITE) {
+		t.Errorf("Expected the contempt and the root side to change the key")
+	}
+
+	// A draw scored with white as the root side is not read back with black as the root side
+	board, err := FEN("k7/8/1K6/8/8/8/8/2Q5 w - - 0 1").toBoard(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	options := DEFAULT_SEARCH_OPTIONS
+	options.Contempt = 100
+	board.searchRoot(2, newSearchState(options))
+	if probeTT(board.Zobrist^ttKey(0, 100, WHITE)) == nil || probeTT(board.Zobrist^ttKey(0, 100, BLACK)) != nil {
+		t.Errorf("Expected the root entry to only be found with white as the root side")
+	}
+	ClearTT()
+}
@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+This file holds the self-play match runner.
+Two engine configurations play each other in-process from a set of openings, each opening twice with the colours reversed,
+and the match is reported as an Elo difference with error bars and an SPRT decision.
+Games are played one at a time, as the TT is global and is cleared before every move so the engines do not share it.
+*/
+
+// Options for a self-play match
+type MatchOptions struct {
+	// The two engines playing, results are reported from the first engines perspective
+	Engines [2]SearchOptions
+
+	// The starting positions of the games, each is played twice with colours reversed
+	// If empty, the default opening lines are used
+	Openings []FEN
+
+	// The maximum number of games to play, the match can stop earlier if the SPRT finishes
+	Games int
+
+	// Games longer than this are adjudicated as a draw
+	MaxPlies int
+
+	// The SPRT bounds, nil to play all the games
+	SPRT *SPRTOptions
+}
+
+// Results of a single game
+type GameResult uint8
+
+const (
+	RESULT_WHITE_WINS GameResult = iota
+	RESULT_BLACK_WINS
+	RESULT_DRAW
+)
+
+// Converts a GameResult to the PGN result string
+func (r GameResult) toString() string {
+	switch r {
+	case RESULT_WHITE_WINS:
+		return "1-0"
+	case RESULT_BLACK_WINS:
+		return "0-1"
+	}
+	return "1/2-1/2"
+}
+
+// Final results of a match, from the perspective of the first engine
+type MatchResult struct {
+	Wins     int
+	Draws    int
+	Losses   int
+	Elo      float64
+	EloError float64
+	Decision string
+}
+
+// Default maximum length of a game before adjudicating a draw
+const DEFAULT_MAX_PLIES = 400
+
+// Openings used when no openings file is given, as moves from the starting position
+// These are mainstream, roughly balanced lines so neither colour starts with an advantage
+var MATCH_OPENINGS = []string{
+	"e2e4 e7e5 g1f3 b8c6 f1b5 a7a6",
+	"e2e4 e7e5 g1f3 b8c6 f1c4 f8c5",
+	"e2e4 c7c5 g1f3 d7d6 d2d4 c5d4 f3d4 g8f6 b1c3",
+	"e2e4 c7c5 b1c3 b8c6 g2g3",
+	"e2e4 e7e6 d2d4 d7d5 b1c3 g8f6",
+	"e2e4 c7c6 d2d4 d7d5 e4e5 c8f5",
+	"e2e4 d7d5 e4d5 d8d5 b1c3 d5a5",
+	"d2d4 d7d5 c2c4 e7e6 b1c3 g8f6",
+	"d2d4 d7d5 c2c4 c7c6 g1f3 g8f6",
+	"d2d4 g8f6 c2c4 g7g6 b1c3 f8g7 e2e4 d7d6",
+	"d2d4 g8f6 c2c4 e7e6 b1c3 f8b4",
+	"d2d4 g8f6 c2c4 e7e6 g1f3 b7b6",
+	"c2c4 e7e5 b1c3 g8f6 g1f3 b8c6",
+	"c2c4 c7c5 g1f3 g8f6 b1c3 b8c6",
+	"g1f3 d7d5 g2g3 g8f6 f1g2 c7c6",
+	"d2d4 d7d5 g1f3 g8f6 c1f4 e7e6",
+}
+
+// Play the default opening lines out, and get the FENs of the positions they reach
+func defaultOpenings() ([]FEN, error) {
+	openings := make([]FEN, 0, len(MATCH_OPENINGS))
+	for _, line := range MATCH_OPENINGS {
+		board, err := STARTING_POSITION_FEN.toBoard(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for pcn := range strings.FieldsSeq(line) {
+			move, err := board.moveFromPCN(pcn)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid opening %q: %w", line, err)
+			}
+			board.makeMove(move)
+		}
+		openings = append(openings, board.toFEN())
+	}
+
+	return openings, nil
+}
+
+// Load openings from a file, one FEN or EPD line per line
+// EPD lines only have 4 fields, so the move counters are added to them
+func LoadOpenings(path string) ([]FEN, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	openings := make([]FEN, 0, 100)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Drop any EPD operations after the position
+		fields := strings.Fields(strings.Split(line, ";")[0])
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("Invalid opening %q; Should have at least 4 FEN fields", line)
+		}
+		if len(fields) < 6 || !isNumber(fields[4]) || !isNumber(fields[5]) {
+			fields = append(fields[:4], "0", "1")
+		}
+
+		fen := FEN(strings.Join(fields[:6], " "))
+		if _, err := fen.toBoard(nil); err != nil {
+			return nil, fmt.Errorf("Invalid opening %q: %w", line, err)
+		}
+		openings = append(openings, fen)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(openings) == 0 {
+		return nil, fmt.Errorf("No openings found in %v", path)
+	}
+	return openings, nil
+}
+
+// Helper to check if a string is a non-negative integer
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Pick the move to play from the results of a root search
+func bestRootMove(result RootSearchResult) Move {
+	best := result.moves[0]
+	for _, moveEval := range result.moves[1:] {
+		if moveEval.eval > best.eval {
+			best = moveEval
+		}
+	}
+	return best.move
+}
+
+// Play a single game between two engines, adjudicating it with the draw rules
+// Returns the result and the reason the game ended
+func playGame(opening FEN, white, black SearchOptions, maxPlies int) (GameResult, string, error) {
+	board, err := opening.toBoard(nil)
+	if err != nil {
+		return RESULT_DRAW, "", err
+	}
+
+	for ply := 0; ; ply++ {
+		// Checkmate and stalemate take priority, a mate on the fiftieth move still counts
+		if len(board.generateLegalMoves()) == 0 {
+			if !board.isInCheck(board.Turn) {
+				return RESULT_DRAW, "stalemate", nil
+			}
+			if board.Turn == WHITE {
+				return RESULT_BLACK_WINS, "checkmate", nil
+			}
+			return RESULT_WHITE_WINS, "checkmate", nil
+		}
+
+		// Draw rules
+		if board.isThreeFold() {
+			return RESULT_DRAW, "threefold repetition", nil
+		}
+		if board.HMC >= 100 {
+			return RESULT_DRAW, "fifty move rule", nil
+		}
+		if board.isInsufficientMaterial() {
+			return RESULT_DRAW, "insufficient material", nil
+		}
+		if ply >= maxPlies {
+			return RESULT_DRAW, "adjudicated after max plies", nil
+		}
+
+		// Search with the engine to move, on a clear TT
+		options := white
+		if board.Turn == BLACK {
+			options = black
+		}
+		ClearTT()
+		clearPawnHash()
+		result := board.iterativeSearch(options)
+		board.makeMove(pickRootMove(result, options))
+	}
+}
+
+// RunMatch plays a self-play match between two engine configurations
+func RunMatch(options MatchOptions) MatchResult {
+	// Init the engine
+	InitEngine()
+
+	if len(options.Openings) == 0 {
+		openings, err := defaultOpenings()
+		if err != nil {
+			panic(err)
+		}
+		options.Openings = openings
+	}
+	if options.MaxPlies <= 0 {
+		options.MaxPlies = DEFAULT_MAX_PLIES
+	}
+	if options.Games <= 0 {
+		options.Games = 2 * len(options.Openings)
+	}
+
+	fmt.Println("Starting self-play match.")
+	fmt.Printf("Engine A: %v\n", options.Engines[0])
+	fmt.Printf("Engine B: %v\n", options.Engines[1])
+	fmt.Printf("Openings: %d, max games: %d\n\n", len(options.Openings), options.Games)
+
+	var result MatchResult
+	result.Decision = SPRT_CONTINUE
+	for game := range options.Games {
+		// Each opening is played twice, with engine A as white and then as black
+		opening := options.Openings[(game/2)%len(options.Openings)]
+		aIsWhite := game%2 == 0
+		white, black := options.Engines[0], options.Engines[1]
+		if !aIsWhite {
+			white, black = black, white
+		}
+
+		gameResult, reason, err := playGame(opening, white, black, options.MaxPlies)
+		if err != nil {
+			fmt.Printf("Game %d failed: %v\n", game+1, err)
+			continue
+		}
+
+		// Score from engine A's perspective
+		switch {
+		case gameResult == RESULT_DRAW:
+			result.Draws++
+		case (gameResult == RESULT_WHITE_WINS) == aIsWhite:
+			result.Wins++
+		default:
+			result.Losses++
+		}
+
+		whiteName, blackName := "A", "B"
+		if !aIsWhite {
+			whiteName, blackName = "B", "A"
+		}
+		result.Elo, result.EloError = EstimateElo(result.Wins, result.Draws, result.Losses)
+		fmt.Printf("Game %d (%v vs %v): %v by %v | +%d =%d -%d | Elo %.1f +/- %.1f\n",
+			game+1, whiteName, blackName, gameResult.toString(), reason,
+			result.Wins, result.Draws, result.Losses, result.Elo, result.EloError)
+
+		// Stop as soon as the SPRT has an answer
+		if options.SPRT != nil {
+			result.Decision = options.SPRT.decide(result.Wins, result.Draws, result.Losses)
+			if result.Decision != SPRT_CONTINUE {
+				break
+			}
+		}
+	}
+
+	// Print final results
+	fmt.Printf("---------------------\nFinal Results\n---------------------\n")
+	fmt.Printf("Games: %d (+%d =%d -%d)\n", result.Wins+result.Draws+result.Losses, result.Wins, result.Draws, result.Losses)
+	fmt.Printf("Elo difference (A - B): %.1f +/- %.1f (95%%)\n", result.Elo, result.EloError)
+	if options.SPRT != nil {
+		llr, lower, upper := options.SPRT.llr(result.Wins, result.Draws, result.Losses)
+		fmt.Printf("SPRT [%.1f, %.1f]: LLR %.2f (%.2f, %.2f) -> %v\n", options.SPRT.Elo0, options.SPRT.Elo1, llr, lower, upper, result.Decision)
+	}
+	fmt.Println()
+
+	return result
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+/*
+This file holds the skill levels, to play at the strength of a player instead of at full strength.
+A skill level limits how deep and how many nodes the engine searches, adds noise to the evals of the root moves,
+and picks between the best few root moves at random, favouring the better ones. Each level is labelled with the Elo
+it was designed for, but is played at the Elo CalibrateSkill measured for it, which plays the levels against fixed depth
+reference engines. Levels between them are interpolated, so any Elo in the measured range can be played.
+*/
+
+// The settings of a skill level
+type Skill struct {
+	// The Elo the level was designed for
+	Elo int
+
+	// The most the engine searches, to the depth or the number of nodes
+	Depth uint8
+	Nodes int
+
+	// The standard deviation of the noise added to the evals of the root moves, in centipawns
+	Noise float64
+
+	// How many of the best root moves can be played
+	Candidates int
+
+	// How likely worse moves are to be played, the chance of a move is exp(-loss / temperature) (loss in centipawns)
+	Temperature float64
+
+	// The Elo CalibrateSkill last measured for the level, 0 if it was not measured
+	// The level is played for this Elo instead of its label, so the Elo asked for is the Elo played at
+	Measured int
+}
+
+// The Elo the level plays at, the Elo measured for it or its label if it was not measured
+func (s Skill) playsAt() int {
+	if s.Measured > 0 {
+		return s.Measured
+	}
+	return s.Elo
+}
+
+// The skill levels, from the weakest to the strongest
+// The Elos the levels play at should grow from level to level, Elos are interpolated between them
+// Elos above the strongest level play at full strength, and Elos below the weakest play at the weakest level
+// Measured with the defaults of: go run ./cmd/engine -action calibrate (32 games a level, about +/- 120 Elo)
+// The measured Elos are only as good as the Elos of SKILL_REFERENCES, which are not anchored to an outside rating yet
+var SKILL_LEVELS = []Skill{
+	{Elo: 400, Depth: 1, Nodes: 200, Noise: 200, Candidates: 6, Temperature: 150, Measured: 607},
+	{Elo: 800, Depth: 2, Nodes: 1000, Noise: 120, Candidates: 5, Temperature: 80, Measured: 679},
+	{Elo: 1200, Depth: 3, Nodes: 5000, Noise: 60, Candidates: 4, Temperature: 40, Measured: 1100},
+	{Elo: 1500, Depth: 4, Nodes: 20000, Noise: 30, Candidates: 3, Temperature: 20, Measured: 1244},
+	{Elo: 1800, Depth: 5, Nodes: 80000, Noise: 10, Candidates: 2, Temperature: 8, Measured: 1766},
+}
+
+// Get the skill level of an Elo, interpolating between the levels that play at the Elos around it
+func skillForElo(elo int) Skill {
+	if elo <= SKILL_LEVELS[0].playsAt() {
+		return SKILL_LEVELS[0]
+	}
+
+	for i, upper := range SKILL_LEVELS[1:] {
+		if elo > upper.playsAt() {
+			continue
+		}
+		if elo == upper.playsAt() {
+			return upper
+		}
+		lower := SKILL_LEVELS[i]
+		t := float64(elo-lower.playsAt()) / float64(upper.playsAt()-lower.playsAt())
+		lerp := func(a, b float64) float64 { return a + (b-a)*t }
+
+		// The nodes grow exponentially with the strength, so they are interpolated geometrically
+		return Skill{
+			Elo:         elo,
+			Depth:       uint8(math.Round(lerp(float64(lower.Depth), float64(upper.Depth)))),
+			Nodes:       int(math.Round(float64(lower.Nodes) * math.Pow(float64(upper.Nodes)/float64(lower.Nodes), t))),
+			Noise:       lerp(lower.Noise, upper.Noise),
+			Candidates:  int(math.Round(lerp(float64(lower.Candidates), float64(upper.Candidates)))),
+			Temperature: lerp(lower.Temperature, upper.Temperature),
+		}
+	}
+
+	return SKILL_LEVELS[len(SKILL_LEVELS)-1]
+}
+
+// Check if the options play at a skill level, instead of at full strength
+func (o SearchOptions) hasSkill() bool {
+	return o.Elo > 0 && o.Elo <= SKILL_LEVELS[len(SKILL_LEVELS)-1].playsAt()
+}
+
+// Limit the depth and nodes of the options to their skill level
+func (o SearchOptions) limitedBySkill() SearchOptions {
+	if !o.hasSkill() {
+		return o
+	}
+
+	skill := skillForElo(o.Elo)
+	o.Depth = min(o.Depth, skill.Depth)
+	if o.Nodes == 0 || o.Nodes > skill.Nodes {
+		o.Nodes = skill.Nodes
+	}
+	return o
+}
+
+// Pick the move to play from the results of a root search, the best one unless the options play at a skill level
+func pickRootMove(result RootSearchResult, options SearchOptions) Move {
+	if options.hasSkill() {
+		return pickSkillMove(result.moves, skillForElo(options.Elo))
+	}
+	return bestRootMove(result)
+}
+
+// Pick a root move like a player of the skill level would
+func pickSkillMove(moves []MoveEval, skill Skill) Move {
+	// Misjudge the moves with the noise
+	noisy := make([]MoveEval, len(moves))
+	for i, moveEval := range moves {
+		noise := Eval(rand.NormFloat64() * skill.Noise)
+		noisy[i] = MoveEval{move: moveEval.move, eval: min(max(moveEval.eval+noise, MIN_EVAL), MAX_EVAL)}
+	}
+	slices.SortStableFunc(noisy, func(a, b MoveEval) int {
+		return cmp.Compare(b.eval, a.eval)
+	})
+
+	// Pick one of the best moves, the further a move is behind the best the less likely it is
+	candidates := noisy[:min(max(skill.Candidates, 1), len(noisy))]
+	chances := make([]float64, len(candidates))
+	total := 0.0
+	for i, candidate := range candidates {
+		loss := float64(candidates[0].eval - candidate.eval)
+		chances[i] = math.Exp(-loss / max(skill.Temperature, 1))
+		total += chances[i]
+	}
+
+	roll := rand.Float64() * total
+	for i, candidate := range candidates {
+		roll -= chances[i]
+		if roll < 0 {
+			return candidate.move
+		}
+	}
+	return candidates[0].move
+}
+
+// Options for calibrating the skill levels
+type CalibrationOptions struct {
+	// The Elos to play, defaults to the Elos the skill levels play at, so each level is measured again
+	// Other Elos are played by interpolating the levels, which checks they play at the Elo asked for
+	Elos []int
+
+	// The number of games against the reference engine of each Elo, and their maximum length
+	Games    int
+	MaxPlies int
+}
+
+// The reference engines the skill levels are measured against, searching to a fixed depth at full strength
+// Their Elos are estimates, and are what anchor the measured Elos of the skill levels
+// To anchor them, play the references against an engine with a known rating and put the measured Elos here
+var SKILL_REFERENCES = []struct {
+	Depth uint8
+	Elo   int
+}{
+	{Depth: 1, Elo: 900},
+	{Depth: 2, Elo: 1200},
+	{Depth: 3, Elo: 1450},
+	{Depth: 4, Elo: 1700},
+	{Depth: 5, Elo: 1900},
+}
+
+// The Elo measured for a skill level
+type Calibration struct {
+	Elo        int
+	Reference  uint8
+	Measured   float64
+	ErrorRange float64
+}
+
+// Calibrate the skill levels, each Elo plays a match against the reference engine closest to it
+// The Elo measured is the Elo of the reference with the Elo difference of the match
+func CalibrateSkill(options CalibrationOptions) []Calibration {
+	elos := options.Elos
+	if len(elos) == 0 {
+		for _, skill := range SKILL_LEVELS {
+			elos = append(elos, skill.playsAt())
+		}
+	}
+
+	calibrations := make([]Calibration, 0, len(elos))
+	for _, elo := range elos {
+		reference := SKILL_REFERENCES[0]
+		for _, r := range SKILL_REFERENCES[1:] {
+			if math.Abs(float64(r.Elo-elo)) < math.Abs(float64(reference.Elo-elo)) {
+				reference = r
+			}
+		}
+
+		level := DEFAULT_SEARCH_OPTIONS
+		level.Elo = elo
+		fixed := DEFAULT_SEARCH_OPTIONS
+		fixed.Depth = reference.Depth
+		result := RunMatch(MatchOptions{
+			Engines:  [2]SearchOptions{level, fixed},
+			Games:    options.Games,
+			MaxPlies: options.MaxPlies,
+		})
+
+		calibrations = append(calibrations, Calibration{
+			Elo:        elo,
+			Reference:  reference.Depth,
+			Measured:   float64(reference.Elo) + result.Elo,
+			ErrorRange: result.EloError,
+		})
+	}
+
+	fmt.Printf("---------------------\nSkill Calibration\n---------------------\n")
+	for _, c := range calibrations {
+		fmt.Printf("Elo %4d: measured %.0f +/- %.0f (against depth %d)\n", c.Elo, c.Measured, c.ErrorRange, c.Reference)
+	}
+	return calibrations
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestSkillForElo(t *testing.T) {
+	// Tests setup to be run, with the expected skill level of the Elo
+	tests := []struct {
+		elo      int
+		expected Skill
+	}{
+		{elo: 100, expected: SKILL_LEVELS[0]},
+		{elo: 607, expected: SKILL_LEVELS[0]},
+		{elo: 643, expected: Skill{Elo: 643, Depth: 2, Nodes: 447, Noise: 160, Candidates: 6, Temperature: 115}},
+		{elo: 679, expected: SKILL_LEVELS[1]},
+		{elo: 1100, expected: SKILL_LEVELS[2]},
+		{elo: 1505, expected: Skill{Elo: 1505, Depth: 5, Nodes: 40000, Noise: 20, Candidates: 3, Temperature: 14}},
+		{elo: 1766, expected: SKILL_LEVELS[4]},
+	}
+
+	for _, tc := range tests {
+		if got := skillForElo(tc.elo); got != tc.expected {
+			t.Errorf("Elo %d: Expected %+v, got %+v", tc.elo, tc.expected, got)
+		}
+	}
+
+	// The levels play at the Elos measured for them, which grow from level to level
+	for i, skill := range SKILL_LEVELS[1:] {
+		if skill.playsAt() <= SKILL_LEVELS[i].playsAt() {
+			t.Errorf("Expected level %d to play above %d, got %d", i+1, SKILL_LEVELS[i].playsAt(), skill.playsAt())
+		}
+	}
+
+	// Only Elos in the range of the skill levels limit the search
+	for elo, expected := range map[int]bool{0: false, 100: true, 1766: true, 1767: false} {
+		options := DEFAULT_SEARCH_OPTIONS
+		options.Elo = elo
+		if got := options.hasSkill(); got != expected {
+			t.Errorf("Elo %d: Expected a skill level %v, got %v", elo, expected, got)
+		}
+		if limited := options.limitedBySkill(); expected != (limited.Nodes > 0) {
+			t.Errorf("Elo %d: Expected a node limit %v, got %+v", elo, expected, limited)
+		}
+	}
+}
+
+func TestPickSkillMove(t *testing.T) {
+	InitEngine()
+
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		t.Fatalf("Failed to build the starting position: %v", err)
+	}
+	moves := board.generateLegalMoves()
+	moveEvals := []MoveEval{
+		{move: moves[0], eval: 0},
+		{move: moves[1], eval: 50},
+		{move: moves[2], eval: -10},
+		{move: moves[3], eval: -900},
+	}
+
+	// Without noise and with one candidate, the best move is always played
+	for range 20 {
+		if move := pickSkillMove(moveEvals, Skill{Candidates: 1}); move != moves[1] {
+			t.Fatalf("Expected the best move %v, got %v", moves[1].toPCN(), move.toPCN())
+		}
+	}
+
+	// With more candidates, worse moves are played, but not the ones past the candidates
+	played := map[Move]int{}
+	for range 300 {
+		played[pickSkillMove(moveEvals, Skill{Candidates: 3, Temperature: 100})]++
+	}
+	if played[moves[1]] <= played[moves[0]] || played[moves[0]] == 0 || played[moves[2]] == 0 || played[moves[3]] != 0 {
+		t.Errorf("Expected the best 3 moves to be played, the best most often, got %v", played)
+	}
+}
+
+func TestSearchNodeLimit(t *testing.T) {
+	InitEngine()
+
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		t.Fatalf("Failed to build the starting position: %v", err)
+	}
+
+	// The first depth is always completed, even past the limit, so there is a move to play
+	options := DEFAULT_SEARCH_OPTIONS
+	options.Nodes = 1
+	ClearTT()
+	result := board.iterativeSearch(options)
+	if len(result.moves) != 20 {
+		t.Fatalf("Expected the 20 root moves of the first depth, got %d", len(result.moves))
+	}
+
+	// A skill level searches every root move with the full window, and stops at its node limit
+	options.Nodes = 0
+	options.Elo = 400
+	ClearTT()
+	result = board.iterativeSearch(options)
+	if len(result.moves) != 20 || result.nodes > 1000 {
+		t.Errorf("Expected the 20 root moves within the node limit, got %d moves in %d nodes", len(result.moves), result.nodes)
+	}
+	for _, moveEval := range result.moves {
+		if moveEval.eval < -100 || moveEval.eval > 100 {
+			t.Errorf("Expected an exact eval of %v close to 0, got %d", moveEval.move.toPCN(), moveEval.eval)
+		}
+	}
+
+	// The options round trip through their string
+	options = DEFAULT_SEARCH_OPTIONS
+	options.Nodes = 5000
+	options.Elo = 1200
+	parsed, err := ParseSearchOptions(options.String())
+	if err != nil || parsed != options {
+		t.Errorf("Expected %v to parse back, got %v (error: %v)", options, parsed, err)
+	}
+}
@@ -3,9 +3,6 @@ package engine
 import (
 	"cmp"
 	"fmt"
-	"log"
-	"os"
-	"runtime/pprof"
 	"slices"
 	"time"
 )
@@ -23,7 +20,8 @@ type StrengthTestTest struct {
 	rounds        int
 }
 
-func StrengthTest() {
+// Returns the results of the run, to be saved and compared against other builds
+func StrengthTest() *TestRun {
 	fmt.Println("Starting strength test.")
 	fmt.Println()
 
@@ -111,20 +109,14 @@ func StrengthTest() {
 		},
 	}
 
-	// Setup profiler
-	f, err := os.Create("cpu.prof")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
-	if err := pprof.StartCPUProfile(f); err != nil {
-		log.Fatal(err)
-	}
-	defer pprof.StopCPUProfile()
-
 	// Strength test totals
 	totalNodes := 0
 	totalSearchTime := 0
+	run := &TestRun{
+		Suite:     SUITE_STRENGTH,
+		Timestamp: time.Now(),
+		Positions: make([]PositionResult, 0, len(positions)),
+	}
 
 	for pi, position := range positions {
 		// Setup the starting board
@@ -135,7 +127,7 @@ func StrengthTest() {
 		nodes := 0
 		bestEval := Eval(0)
 		rounds := position.rounds
-		bestMove := Move{}
+		bestMove := NO_MOVE
 		for rounds > 0 {
 
 			// Clear TT (otherwise the entire search gets cached essentially)
@@ -177,6 +169,27 @@ func StrengthTest() {
 		// Update totals
 		totalNodes += nodes
 		totalSearchTime += int(aggSearchTime)
+
+		// Record the position result
+		// The move counts as best if it matches stockfish, the stockfish move may not parse (it is hand written)
+		isBest := false
+		if stockfishPCN, err := board.SanToPCN(position.stockfishMove); err == nil {
+			isBest = stockfishPCN == bestMove.toPCN()
+		}
+		points := 0
+		if isBest {
+			points = 10
+		}
+		run.Positions = append(run.Positions, PositionResult{
+			ID:     fmt.Sprintf("Position.%03d", pi+1),
+			Theme:  "Position",
+			Move:   bestMove.toPCN(),
+			Eval:   bestEval,
+			Points: points,
+			Best:   isBest,
+			Nodes:  nodes,
+			TimeMs: aggSearchTime,
+		})
 	}
 
 	// Print final results
@@ -188,4 +201,6 @@ func StrengthTest() {
 	fmt.Printf("Average Nodes: %d\n", avgTotalNodes)
 	fmt.Printf("Average Search Time: %d\n", avgTotalSearchTime)
 	fmt.Printf("Average Mn/s: %.3f\n\n", mnps)
+
+	return run
 }
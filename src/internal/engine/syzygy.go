@@ -0,0 +1,1130 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+This file probes Syzygy tablebases, the standard tablebases of endgames of up to 7 pieces, with or without pawns.
+Win/draw/loss files (.rtbw) hold the result of every position, counting the fifty move rule: a cursed win is a win
+the fifty move rule turns into a draw, and a blessed loss is a loss it saves. Distance to zeroing files (.rtbz) hold
+how many plies it takes to the next capture or pawn move (or mate) with best play, which is what makes progress in a won position.
+The files are compressed, and a single value is decompressed each probe, following the reference implementation of the format.
+Files are mapped into memory the first time they are probed, so only the pages probed are read, and the OS shares them
+between processes. Nothing is probed when no directory is configured.
+The search cuts off at positions in the win/draw/loss tables, and only searches the root moves that keep the best result.
+*/
+
+// Directories of Syzygy tablebases to probe, separated like the PATH environment variable, none are probed when empty
+var SYZYGY_PATH string
+
+// The most pieces (including the kings) of the Syzygy tables found, 0 when there are none
+var SYZYGY_MAX_PIECES int
+
+// The most pieces a Syzygy table can have
+const SYZYGY_PIECES = 7
+
+// File extensions and magic numbers of the win/draw/loss and distance to zeroing files
+const SYZYGY_WDL_EXTENSION = ".rtbw"
+const SYZYGY_DTZ_EXTENSION = ".rtbz"
+
+var SYZYGY_WDL_MAGIC = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+var SYZYGY_DTZ_MAGIC = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+
+// The results of the win/draw/loss tables, from the perspective of the side to move
+const (
+	WDL_LOSS = iota - 2
+	WDL_BLESSED_LOSS
+	WDL_DRAW
+	WDL_CURSED_WIN
+	WDL_WIN
+)
+
+// The evaluation of a tablebase win in the search, above any evaluation but below the mate scores
+const SYZYGY_WIN = MAX_EVAL - 2*MAX_PLY
+
+// Flags of the values of a file
+const (
+	SYZYGY_FLAG_STM          = 1   // The side to move of a distance to zeroing file, they only hold one
+	SYZYGY_FLAG_MAPPED       = 2   // Distances are looked up in a map
+	SYZYGY_FLAG_WIN_PLIES    = 4   // Distances of wins are in plies, instead of moves
+	SYZYGY_FLAG_LOSS_PLIES   = 8   // Distances of losses are in plies, instead of moves
+	SYZYGY_FLAG_WIDE         = 16  // The map holds 16 bit distances
+	SYZYGY_FLAG_SINGLE_VALUE = 128 // Every position has the same value
+)
+
+// The maps of a distance to zeroing file are stored in the order win, loss, cursed win, blessed loss, this is the map of each result
+var SYZYGY_DTZ_MAPS = [5]int{1, 3, 0, 2, 0}
+
+// Lookup tables of the position indexes
+var SYZYGY_MAP_PAWNS [NUM_SQUARES]int             // Squares a2-h7 from 47 down, the leading pawn is the one with the highest
+var SYZYGY_MAP_B1H1H7 [NUM_SQUARES]int            // Squares below the a1-h8 diagonal to 0...27
+var SYZYGY_MAP_A1D1D4 [NUM_SQUARES]int            // Squares of the a1-d1-d4 triangle to 0...9, the diagonal last
+var SYZYGY_MAP_KK [10][NUM_SQUARES]int            // The 462 placements of two kings with the first in the triangle
+var SYZYGY_BINOMIAL [6][NUM_SQUARES]uint64        // The ways to choose k of n squares
+var SYZYGY_LEAD_PAWN_INDEX [6][NUM_SQUARES]uint64 // The first index of the leading pawns with the leading one on a square
+var SYZYGY_LEAD_PAWNS_SIZE [6][4]uint64           // The number of placements of the leading pawns on each file
+
+// The Syzygy tables found, by the material signature of the boards they hold
+var SYZYGY_TABLES = map[materialSignature]*syzygyTable{}
+
+// An endgame of the Syzygy tablebases, like KRPvKR, with its two files
+type syzygyTable struct {
+	code            string
+	key             materialSignature // The signature of the boards with the first side white
+	key2            materialSignature // The signature of the boards with the first side black
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [NUM_COLORS]int // The pawns of the leading side, then the other side
+	wdl             syzygyFile
+	dtz             syzygyFile
+}
+
+// A file of a table, read the first time it is probed
+type syzygyFile struct {
+	path   string
+	isDTZ  bool
+	once   sync.Once
+	data   []byte
+	err    error
+	dtzMap int
+
+	// The values by the file of the leading pawn (only the a file without pawns) and the side to move
+	pairs [4][NUM_COLORS]syzygyPairs
+}
+
+// The compressed values of one file of the leading pawn and side to move
+// The values are compressed by recursive pairing, replacing the most common pairs of symbols by a new symbol, then Huffman coded into blocks
+type syzygyPairs struct {
+	flags           uint8
+	blockSize       int
+	span            int // A sparse index entry is kept every span values
+	numBlocks       int
+	maxSymLen       int
+	minSymLen       int // Or the value of every position, with SYZYGY_FLAG_SINGLE_VALUE
+	lowestSym       []byte
+	base            []uint64
+	btree           []byte  // The two symbols each symbol expands to, 12 bits each
+	symLen          []uint8 // The number of values each symbol expands to, minus one
+	sparseIndex     []byte
+	sparseIndexSize int
+	blockLength     []byte
+	blockLengthSize int
+	blocks          []byte
+	mapIndex        [4]int
+
+	// The pieces in the order they are encoded, and the groups they are encoded in
+	pieces      [SYZYGY_PIECES]uint8
+	groupIndex  [SYZYGY_PIECES + 1]uint64
+	groupLength [SYZYGY_PIECES + 1]int
+}
+
+// Setup the lookup tables of the position indexes
+func initSyzygyIndexes() {
+	code := 0
+	for sq := range Square(NUM_SQUARES) {
+		if diagonalOffset(sq) < 0 {
+			SYZYGY_MAP_B1H1H7[sq] = code
+			code++
+		}
+	}
+
+	// The triangle below the diagonal first, then the diagonal
+	code = 0
+	var diagonal []Square
+	for sq := range Square(28) {
+		switch {
+		case sq%8 > 3:
+		case diagonalOffset(sq) < 0:
+			SYZYGY_MAP_A1D1D4[sq] = code
+			code++
+		case diagonalOffset(sq) == 0:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		SYZYGY_MAP_A1D1D4[sq] = code
+		code++
+	}
+
+	// The kings can not touch, and with the first on the diagonal the second is not above it
+	// Both kings on the diagonal are encoded last
+	code = 0
+	var bothOnDiagonal [][2]int
+	for index := range 10 {
+		for first := range Square(28) {
+			if SYZYGY_MAP_A1D1D4[first] != index || (index == 0 && first != 1) {
+				continue
+			}
+			for second := range Square(NUM_SQUARES) {
+				switch {
+				case (KING_MOVES[first]|first.bitBoardPosition())&second.bitBoardPosition() != 0:
+				case diagonalOffset(first) == 0 && diagonalOffset(second) > 0:
+				case diagonalOffset(first) == 0 && diagonalOffset(second) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, [2]int{index, int(second)})
+				default:
+					SYZYGY_MAP_KK[index][second] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, kings := range bothOnDiagonal {
+		SYZYGY_MAP_KK[kings[0]][kings[1]] = code
+		code++
+	}
+
+	SYZYGY_BINOMIAL[0][0] = 1
+	for n := 1; n < NUM_SQUARES; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			SYZYGY_BINOMIAL[k][n] = 0
+			if k > 0 {
+				SYZYGY_BINOMIAL[k][n] += SYZYGY_BINOMIAL[k-1][n-1]
+			}
+			if k < n {
+				SYZYGY_BINOMIAL[k][n] += SYZYGY_BINOMIAL[k][n-1]
+			}
+		}
+	}
+
+	// With the leading pawn on a square, the other pawns can not be nearer the edge or further back
+	available := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for file := range 4 {
+			index := uint64(0)
+			for rank := 1; rank <= 6; rank++ {
+				sq := Square(rank*8 + file)
+				if leadPawns == 1 {
+					SYZYGY_MAP_PAWNS[sq] = available
+					SYZYGY_MAP_PAWNS[sq^7] = available - 1
+					available -= 2
+				}
+				SYZYGY_LEAD_PAWN_INDEX[leadPawns][sq] = index
+				index += SYZYGY_BINOMIAL[leadPawns-1][SYZYGY_MAP_PAWNS[sq]]
+			}
+			SYZYGY_LEAD_PAWNS_SIZE[leadPawns][file] = index
+		}
+	}
+}
+
+// How far a square is above the a1-h8 diagonal
+func diagonalOffset(sq Square) int {
+	return int(sq)/8 - int(sq)%8
+}
+
+// Setup a table from its code, like "KRvKP"
+func newSyzygyTable(code string) (*syzygyTable, error) {
+	white, black, found := strings.Cut(code, "v")
+	if !found || !strings.HasPrefix(white, "K") || !strings.HasPrefix(black, "K") || strings.Trim(white[1:]+black[1:], "QRBNP") != "" {
+		return nil, fmt.Errorf("Invalid Syzygy table %q; Should be a king and pieces for each side, like KRvKP", code)
+	}
+	if len(white)+len(black) > SYZYGY_PIECES {
+		return nil, fmt.Errorf("Invalid Syzygy table %q; Should be at most %d pieces", code, SYZYGY_PIECES)
+	}
+
+	t := &syzygyTable{
+		code:       code,
+		key:        signatureOf(white+black, WHITE),
+		key2:       signatureOf(white+black, BLACK),
+		pieceCount: len(white) + len(black),
+		hasPawns:   strings.Contains(code, "P"),
+	}
+	for _, side := range []string{white, black} {
+		for _, c := range "QRBNP" {
+			if strings.Count(side, string(c)) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+
+	// The leading side is the one with the fewest pawns, as long as it has some
+	whitePawns, blackPawns := strings.Count(white, "P"), strings.Count(black, "P")
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		t.pawnCount = [NUM_COLORS]int{whitePawns, blackPawns}
+	} else {
+		t.pawnCount = [NUM_COLORS]int{blackPawns, whitePawns}
+	}
+	return t, nil
+}
+
+// Map a file, the first time it is needed
+func (f *syzygyFile) load(t *syzygyTable) bool {
+	f.once.Do(func() {
+		f.data, f.err = mapFile(f.path)
+		if f.err == nil {
+			f.err = f.parse(t)
+		}
+		if f.err != nil {
+			f.unload()
+			fmt.Printf("Failed to load the Syzygy table: %v\n", f.err)
+		}
+	})
+	return f.err == nil
+}
+
+// Unmap a file, it can not be probed after
+func (f *syzygyFile) unload() {
+	if err := unmapFile(f.data); err != nil {
+		fmt.Printf("Failed to unmap the Syzygy table: %v\n", err)
+	}
+	f.data = nil
+	f.pairs = [4][NUM_COLORS]syzygyPairs{}
+}
+
+// Read the layout of the values of a file
+func (f *syzygyFile) parse(t *syzygyTable) (err error) {
+	data := f.data
+	magic := SYZYGY_WDL_MAGIC
+	if f.isDTZ {
+		magic = SYZYGY_DTZ_MAGIC
+	}
+	if len(data) < len(magic)+1 || [4]byte(data[:4]) != magic {
+		return fmt.Errorf("Invalid Syzygy file %v; Not a Syzygy tablebase", f.path)
+	}
+
+	// A corrupt file can point anywhere, so reading out of it is an error instead of a crash
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("Invalid Syzygy file %v; The file is cut short or corrupt", f.path)
+		}
+	}()
+
+	const split, hasPawns = 1, 2
+	if (data[4]&hasPawns != 0) != t.hasPawns || (data[4]&split != 0) != (t.key != t.key2) {
+		return fmt.Errorf("Invalid Syzygy file %v; Should hold the endgame %v", f.path, t.code)
+	}
+	pos := 5
+
+	// Win/draw/loss files hold both sides to move (unless the sides have the same pieces), distance to zeroing files one
+	sides := 1
+	if !f.isDTZ && t.key != t.key2 {
+		sides = 2
+	}
+	files := 1
+	if t.hasPawns {
+		files = 4
+	}
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+
+	// The order of the pieces, and the order their groups are encoded in
+	for file := range files {
+		order := [NUM_COLORS][2]int{{int(data[pos] & 0xF), 0xF}, {int(data[pos] >> 4), 0xF}}
+		if bothPawns {
+			order[0][1], order[1][1] = int(data[pos+1]&0xF), int(data[pos+1]>>4)
+			pos++
+		}
+		pos++
+		for k := range t.pieceCount {
+			f.pairs[file][0].pieces[k] = data[pos] & 0xF
+			f.pairs[file][1].pieces[k] = data[pos] >> 4
+			pos++
+		}
+		for side := range sides {
+			t.setGroups(&f.pairs[file][side], order[side], file)
+		}
+	}
+	pos += pos & 1
+
+	for file := range files {
+		for side := range sides {
+			pos = f.pairs[file][side].setSizes(data, pos)
+		}
+	}
+	if f.isDTZ {
+		pos = f.setDTZMaps(pos, files)
+	}
+	for file := range files {
+		for side := range sides {
+			p := &f.pairs[file][side]
+			p.sparseIndex = data[pos : pos+6*p.sparseIndexSize]
+			pos += 6 * p.sparseIndexSize
+		}
+	}
+	for file := range files {
+		for side := range sides {
+			p := &f.pairs[file][side]
+			p.blockLength = data[pos : pos+2*p.blockLengthSize]
+			pos += 2 * p.blockLengthSize
+		}
+	}
+	for file := range files {
+		for side := range sides {
+			p := &f.pairs[file][side]
+			pos = (pos + 63) &^ 63
+			p.blocks = data[pos:]
+			pos += p.numBlocks * p.blockSize
+		}
+	}
+	if pos > len(data) {
+		return fmt.Errorf("Invalid Syzygy file %v; The file is cut short", f.path)
+	}
+	return nil
+}
+
+// Set the groups the pieces are encoded in, and where each group starts in the index
+// Identical pieces are encoded together, and so are the leading pieces (or pawns)
+func (t *syzygyTable) setGroups(p *syzygyPairs, order [2]int, file int) {
+	firstLength := 2
+	if t.hasPawns {
+		firstLength = 0
+	} else if t.hasUniquePieces {
+		firstLength = 3
+	}
+
+	n := 0
+	p.groupLength[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLength--
+		if firstLength > 0 || p.pieces[i] == p.pieces[i-1] {
+			p.groupLength[n]++
+		} else {
+			n++
+			p.groupLength[n] = 1
+		}
+	}
+	n++
+	p.groupLength[n] = 0
+
+	// The leading group is encoded at order[0], and the other side's pawns at order[1]
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := NUM_SQUARES - p.groupLength[0]
+	if bothPawns {
+		next = 2
+		freeSquares -= p.groupLength[1]
+	}
+	index := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch k {
+		case order[0]:
+			p.groupIndex[0] = index
+			switch {
+			case t.hasPawns:
+				index *= SYZYGY_LEAD_PAWNS_SIZE[p.groupLength[0]][file]
+			case t.hasUniquePieces:
+				index *= 31332
+			default:
+				index *= 462
+			}
+		case order[1]:
+			p.groupIndex[1] = index
+			index *= SYZYGY_BINOMIAL[p.groupLength[1]][48-p.groupLength[0]]
+		default:
+			p.groupIndex[next] = index
+			index *= SYZYGY_BINOMIAL[p.groupLength[next]][freeSquares]
+			freeSquares -= p.groupLength[next]
+			next++
+		}
+	}
+	p.groupIndex[n] = index
+}
+
+// Read the sizes and the Huffman code of the values, returns the position after them
+func (p *syzygyPairs) setSizes(data []byte, pos int) int {
+	p.flags = data[pos]
+	if p.flags&SYZYGY_FLAG_SINGLE_VALUE != 0 {
+		p.minSymLen = int(data[pos+1])
+		return pos + 2
+	}
+
+	size := p.groupIndex[slices.Index(p.groupLength[:], 0)]
+	p.blockSize = 1 << data[pos+1]
+	p.span = 1 << data[pos+2]
+	p.sparseIndexSize = int((size + uint64(p.span) - 1) / uint64(p.span))
+	p.numBlocks = int(binary.LittleEndian.Uint32(data[pos+4:]))
+	p.blockLengthSize = p.numBlocks + int(data[pos+3])
+	p.maxSymLen = int(data[pos+8])
+	p.minSymLen = int(data[pos+9])
+	pos += 10
+
+	// The code is canonical with the longest codes the lowest, so the lowest code of each length follows from the lowest symbol of each length
+	lengths := p.maxSymLen - p.minSymLen + 1
+	p.lowestSym = data[pos : pos+2*lengths]
+	pos += 2 * lengths
+	p.base = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		p.base[i] = (p.base[i+1] + uint64(p.lowest(i)) - uint64(p.lowest(i+1))) / 2
+	}
+	for i := range p.base {
+		p.base[i] <<= 64 - i - p.minSymLen
+	}
+
+	symbols := int(binary.LittleEndian.Uint16(data[pos:]))
+	pos += 2
+	p.btree = data[pos : pos+3*symbols]
+	p.symLen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for sym := range symbols {
+		if !visited[sym] {
+			p.symLen[sym] = p.setSymLen(sym, visited)
+		}
+	}
+	return pos + 3*symbols + symbols&1
+}
+
+// Get the number of values a symbol expands to, minus one
+func (p *syzygyPairs) setSymLen(sym int, visited []bool) uint8 {
+	visited[sym] = true
+	right := p.right(sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := p.left(sym)
+	if !visited[left] {
+		p.symLen[left] = p.setSymLen(left, visited)
+	}
+	if !visited[right] {
+		p.symLen[right] = p.setSymLen(right, visited)
+	}
+	return p.symLen[left] + p.symLen[right] + 1
+}
+
+// The symbols a symbol expands to, a symbol that does not expand is a value (on the left)
+func (p *syzygyPairs) left(sym int) int {
+	return int(p.btree[3*sym+1]&0xF)<<8 | int(p.btree[3*sym])
+}
+
+func (p *syzygyPairs) right(sym int) int {
+	return int(p.btree[3*sym+2])<<4 | int(p.btree[3*sym+1]>>4)
+}
+
+// The lowest symbol of the i-th shortest code length
+func (p *syzygyPairs) lowest(i int) int {
+	return int(binary.LittleEndian.Uint16(p.lowestSym[2*i:]))
+}
+
+// The number of values in a block, minus one
+func (p *syzygyPairs) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(p.blockLength[2*block:]))
+}
+
+// Read 4 big endian bytes of the blocks, reading past the end of the file as zeros
+func (p *syzygyPairs) read32(pos int) uint64 {
+	var word [4]byte
+	if pos < len(p.blocks) {
+		copy(word[:], p.blocks[pos:])
+	}
+	return uint64(binary.BigEndian.Uint32(word[:]))
+}
+
+// Read the maps of the distances, returns the position after them
+func (f *syzygyFile) setDTZMaps(pos, files int) int {
+	f.dtzMap = pos
+	for file := range files {
+		p := &f.pairs[file][0]
+		if p.flags&SYZYGY_FLAG_MAPPED == 0 {
+			continue
+		}
+		for i := range p.mapIndex {
+			if p.flags&SYZYGY_FLAG_WIDE != 0 {
+				pos += pos & 1
+				p.mapIndex[i] = (pos-f.dtzMap)/2 + 1
+				pos += 2*int(binary.LittleEndian.Uint16(f.data[pos:])) + 2
+			} else {
+				p.mapIndex[i] = pos - f.dtzMap + 1
+				pos += int(f.data[pos]) + 1
+			}
+		}
+	}
+	return pos + pos&1
+}
+
+// Decompress the value of a position index
+func (p *syzygyPairs) value(index uint64) int {
+	if p.flags&SYZYGY_FLAG_SINGLE_VALUE != 0 {
+		return p.minSymLen
+	}
+
+	// The sparse index gives the block and offset of the value in the middle of the span, the blocks are walked from there
+	k := int(index / uint64(p.span))
+	block := int(binary.LittleEndian.Uint32(p.sparseIndex[6*k:]))
+	offset := int(binary.LittleEndian.Uint16(p.sparseIndex[6*k+4:]))
+	offset += int(index%uint64(p.span)) - p.span/2
+	for offset < 0 {
+		block--
+		offset += p.blockLen(block) + 1
+	}
+	for offset > p.blockLen(block) {
+		offset -= p.blockLen(block) + 1
+		block++
+	}
+
+	// Read the symbols of the block until the one holding the value
+	pos := block * p.blockSize
+	buffer := p.read32(pos)<<32 | p.read32(pos+4)
+	pos += 8
+	bufferBits := 64
+	sym := 0
+	for {
+		length := 0
+		for buffer < p.base[length] {
+			length++
+		}
+		sym = int((buffer-p.base[length])>>(64-length-p.minSymLen)) + p.lowest(length)
+		if offset <= int(p.symLen[sym]) {
+			break
+		}
+		offset -= int(p.symLen[sym]) + 1
+		length += p.minSymLen
+		buffer <<= length
+		bufferBits -= length
+		if bufferBits <= 32 {
+			bufferBits += 32
+			buffer |= p.read32(pos) << (64 - bufferBits)
+			pos += 4
+		}
+	}
+
+	// Expand the symbol into the pair holding the value, until it is a single value
+	for p.symLen[sym] != 0 {
+		left := p.left(sym)
+		if offset <= int(p.symLen[left]) {
+			sym = left
+		} else {
+			offset -= int(p.symLen[left]) + 1
+			sym = p.right(sym)
+		}
+	}
+	return p.left(sym)
+}
+
+// The piece codes of the files, white is 1 to 6 from the pawn to the king, and black 9 to 14
+func syzygyPiece(color Color, piece Piece) uint8 {
+	return uint8(piece) + 1 + 8*uint8(color)
+}
+
+// Get the index of the board's position in a file, with the file of the leading pawn and the side to move of its values
+// changeSide is true when a distance to zeroing file does not hold the side to move
+func (t *syzygyTable) encode(f *syzygyFile, b *Board) (file int, side int, index uint64, changeSide bool) {
+	// The files hold the first side of the code as white, and only white to move when both sides have the same pieces
+	flip := b.materialSignature() != t.key || (t.key == t.key2 && b.Turn == BLACK)
+	flipColor, flipSquares := uint8(0), Square(0)
+	side = int(b.Turn)
+	if flip {
+		flipColor, flipSquares = 8, 56
+		side ^= 1
+	}
+
+	var squares [SYZYGY_PIECES]Square
+	var pieces [SYZYGY_PIECES]uint8
+	size, leadPawns := 0, 0
+	var leadPawnBoard BitBoard
+
+	// Files with pawns hold a table for each file of the leading pawn, the pawn nearest the edge and furthest back
+	if t.hasPawns {
+		lead := f.pairs[0][0].pieces[0] ^ flipColor
+		leadPawnBoard = b.Pieces[lead>>3][PAWN]
+		for pawns := leadPawnBoard; pawns != 0; size++ {
+			squares[size] = pawns.popSquare() ^ flipSquares
+		}
+		leadPawns = size
+		for i := 1; i < leadPawns; i++ {
+			if SYZYGY_MAP_PAWNS[squares[i]] > SYZYGY_MAP_PAWNS[squares[0]] {
+				squares[0], squares[i] = squares[i], squares[0]
+			}
+		}
+		file = min(int(squares[0])%8, 7-int(squares[0])%8)
+	}
+
+	if f.isDTZ {
+		if int(f.pairs[file][0].flags&SYZYGY_FLAG_STM) != side && (t.key != t.key2 || t.hasPawns) {
+			return file, 0, 0, true
+		}
+		side = 0
+	}
+
+	for others := b.Occupancy[EITHER_COLOR] &^ leadPawnBoard; others != 0; size++ {
+		sq := others.popSquare()
+		color := WHITE
+		if b.Occupancy[BLACK]&sq.bitBoardPosition() != 0 {
+			color = BLACK
+		}
+		squares[size] = sq ^ flipSquares
+		pieces[size] = syzygyPiece(color, b.MailBox[sq]) ^ flipColor
+	}
+
+	// Put the pieces in the order of the file
+	p := &f.pairs[file][side]
+	for i := leadPawns; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if p.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+	return file, side, t.index(p, squares[:size], leadPawns), false
+}
+
+// Get the index of the squares of the pieces, in the order of the file
+// The board is mirrored so the leading piece is in the a1-d1-d4 triangle (or the leading pawn on the a to d files)
+func (t *syzygyTable) index(p *syzygyPairs, squares []Square, leadPawns int) uint64 {
+	if squares[0]%8 > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+
+	var index uint64
+	if t.hasPawns {
+		index = SYZYGY_LEAD_PAWN_INDEX[leadPawns][squares[0]]
+		slices.SortStableFunc(squares[1:leadPawns], func(a, b Square) int {
+			return cmp.Compare(SYZYGY_MAP_PAWNS[a], SYZYGY_MAP_PAWNS[b])
+		})
+		for i := 1; i < leadPawns; i++ {
+			index += SYZYGY_BINOMIAL[i][SYZYGY_MAP_PAWNS[squares[i]]]
+		}
+	} else {
+		if squares[0]/8 > 3 {
+			for i := range squares {
+				squares[i] ^= 56
+			}
+		}
+
+		// The first piece of the leading group off the a1-h8 diagonal is put below it
+		for i := range p.groupLength[0] {
+			offset := diagonalOffset(squares[i])
+			if offset == 0 {
+				continue
+			}
+			if offset > 0 {
+				for j := i; j < len(squares); j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+
+		// Three unique pieces are encoded together, skipping the squares of the pieces before them
+		// Otherwise the leading group is the two kings
+		if t.hasUniquePieces {
+			adjust1, adjust2 := 0, 0
+			if squares[1] > squares[0] {
+				adjust1++
+			}
+			if squares[2] > squares[0] {
+				adjust2++
+			}
+			if squares[2] > squares[1] {
+				adjust2++
+			}
+			rank0, rank1, rank2 := uint64(squares[0]/8), uint64(int(squares[1]/8)-adjust1), uint64(int(squares[2]/8)-adjust2)
+			switch {
+			case diagonalOffset(squares[0]) != 0:
+				index = (uint64(SYZYGY_MAP_A1D1D4[squares[0]])*63+uint64(int(squares[1])-adjust1))*62 + uint64(int(squares[2])-adjust2)
+			case diagonalOffset(squares[1]) != 0:
+				index = (6*63+rank0*28+uint64(SYZYGY_MAP_B1H1H7[squares[1]]))*62 + uint64(int(squares[2])-adjust2)
+			case diagonalOffset(squares[2]) != 0:
+				index = 6*63*62 + 4*28*62 + rank0*7*28 + rank1*28 + uint64(SYZYGY_MAP_B1H1H7[squares[2]])
+			default:
+				index = 6*63*62 + 4*28*62 + 4*7*28 + rank0*7*6 + rank1*6 + rank2
+			}
+		} else {
+			index = uint64(SYZYGY_MAP_KK[SYZYGY_MAP_A1D1D4[squares[0]]][squares[1]])
+		}
+	}
+	index *= p.groupIndex[0]
+
+	// The other groups are encoded by the squares left after the groups before them
+	start := p.groupLength[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; p.groupLength[next] != 0; next++ {
+		group := squares[start : start+p.groupLength[next]]
+		slices.Sort(group)
+		n := uint64(0)
+		for i, sq := range group {
+			free := int(sq)
+			for _, before := range squares[:start] {
+				if sq > before {
+					free--
+				}
+			}
+			if remainingPawns {
+				free -= 8
+			}
+			n += SYZYGY_BINOMIAL[i+1][free]
+		}
+		remainingPawns = false
+		index += n * p.groupIndex[next]
+		start += p.groupLength[next]
+	}
+	return index
+}
+
+// Probe a file for the board, which must have the pieces of the table
+// Win/draw/loss files give the result, distance to zeroing files the plies to zeroing of a position with the given result
+func (t *syzygyTable) probe(f *syzygyFile, b *Board, wdl int) (value int, changeSide bool, ok bool) {
+	if !f.load(t) {
+		return 0, false, false
+	}
+
+	file, side, index, changeSide := t.encode(f, b)
+	if changeSide {
+		return 0, true, true
+	}
+	p := &f.pairs[file][side]
+	value = p.value(index)
+	if !f.isDTZ {
+		return value - 2, false, true
+	}
+
+	if p.flags&SYZYGY_FLAG_MAPPED != 0 {
+		slot := p.mapIndex[SYZYGY_DTZ_MAPS[wdl+2]] + value
+		if p.flags&SYZYGY_FLAG_WIDE != 0 {
+			value = int(binary.LittleEndian.Uint16(f.data[f.dtzMap+2*slot:]))
+		} else {
+			value = int(f.data[f.dtzMap+slot])
+		}
+	}
+	if (wdl == WDL_WIN && p.flags&SYZYGY_FLAG_WIN_PLIES == 0) || (wdl == WDL_LOSS && p.flags&SYZYGY_FLAG_LOSS_PLIES == 0) ||
+		wdl == WDL_CURSED_WIN || wdl == WDL_BLESSED_LOSS {
+		value *= 2
+	}
+	return value + 1, false, true
+}
+
+// Probe the win/draw/loss file of the board, without looking at its moves
+func (b *Board) probeWDLTable() (int, bool) {
+	if b.Occupancy[EITHER_COLOR] == b.Pieces[WHITE][KING]|b.Pieces[BLACK][KING] {
+		return WDL_DRAW, true
+	}
+	t, found := SYZYGY_TABLES[b.materialSignature()]
+	if !found {
+		return 0, false
+	}
+	wdl, _, ok := t.probe(&t.wdl, b, WDL_DRAW)
+	return wdl, ok
+}
+
+// Get the win/draw/loss result of the board, from the perspective of the side to move
+// The files do not hold a correct value for positions a capture wins (or draws), as the generator picks what compresses best,
+// so the captures are searched first. With pawnMoves the pawn moves are searched too, for the distance to zeroing files
+// zeroing is true when the best move is one of the searched moves, then the distance to zeroing file can not be trusted
+func (b *Board) syzygySearch(pawnMoves bool) (wdl int, zeroing bool, ok bool) {
+	var moves MoveList
+	b.generatePseudoLegalMoves(&moves)
+
+	best := WDL_LOSS
+	legalMoves, searched := 0, 0
+	for _, move := range moves.slice() {
+		capture := move.code() == MOVE_CODE_CAPTURE || move.code() == MOVE_CODE_EN_PASSANT
+		pawn := b.MailBox[move.start()] == PAWN
+		undo, isLegal := b.makeMove(move)
+		if !isLegal {
+			b.unMakeMove(undo)
+			continue
+		}
+		legalMoves++
+		if !capture && !(pawnMoves && pawn) {
+			b.unMakeMove(undo)
+			continue
+		}
+		searched++
+
+		value, _, ok := b.syzygySearch(false)
+		b.unMakeMove(undo)
+		if !ok {
+			return WDL_DRAW, false, false
+		}
+		if -value > best {
+			best = -value
+			if best == WDL_WIN {
+				return best, true, true
+			}
+		}
+	}
+
+	// With every move searched the file is not needed, which also covers positions it does not hold, like en passant
+	allSearched := searched > 0 && searched == legalMoves
+	value := best
+	if !allSearched {
+		if value, ok = b.probeWDLTable(); !ok {
+			return WDL_DRAW, false, false
+		}
+	}
+	if best >= value {
+		return best, best > WDL_DRAW || allSearched, true
+	}
+	return value, false, true
+}
+
+// The distance to zeroing of a zeroing move, by the result after it
+func dtzBeforeZeroing(wdl int) int {
+	switch wdl {
+	case WDL_WIN:
+		return 1
+	case WDL_CURSED_WIN:
+		return 101
+	case WDL_BLESSED_LOSS:
+		return -101
+	case WDL_LOSS:
+		return -1
+	}
+	return 0
+}
+
+// The sign of a number, -1, 0 or 1
+func sign(n int) int {
+	return cmp.Compare(n, 0)
+}
+
+// Get the plies to the next capture or pawn move of the board with best play, from the perspective of the side to move
+// Positive is a win and negative a loss, cursed wins and blessed losses are 100 further away, and a draw is 0
+func (b *Board) probeDTZ() (int, bool) {
+	wdl, zeroing, ok := b.syzygySearch(true)
+	if !ok {
+		return 0, false
+	}
+	if wdl == WDL_DRAW {
+		return 0, true
+	}
+	if zeroing {
+		return dtzBeforeZeroing(wdl), true
+	}
+
+	t := SYZYGY_TABLES[b.materialSignature()]
+	dtz, changeSide, ok := t.probe(&t.dtz, b, wdl)
+	if !ok {
+		return 0, false
+	}
+	if !changeSide {
+		if wdl == WDL_CURSED_WIN || wdl == WDL_BLESSED_LOSS {
+			dtz += 100
+		}
+		return dtz * sign(wdl), true
+	}
+
+	// The file only holds the other side to move, so the best distance is found by looking one ply ahead
+	best := 0xFFFF
+	for _, move := range b.generateLegalMoves() {
+		zeroing := move.code() == MOVE_CODE_CAPTURE || move.code() == MOVE_CODE_EN_PASSANT || b.MailBox[move.start()] == PAWN
+		undo, _ := b.makeMove(move)
+
+		// The distance of a zeroing move is the one before it, from its result
+		var dtz int
+		if zeroing {
+			value, _, found := b.syzygySearch(false)
+			dtz, ok = -dtzBeforeZeroing(value), found
+		} else {
+			dtz, ok = b.probeDTZ()
+			dtz = -dtz
+		}
+		if dtz == 1 && b.isInCheck(b.Turn) && len(b.generateLegalMoves()) == 0 {
+			best = 1
+		}
+		b.unMakeMove(undo)
+		if !ok {
+			return 0, false
+		}
+
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < best && sign(dtz) == sign(wdl) {
+			best = dtz
+		}
+	}
+
+	// Without moves the side to move is mated
+	if best == 0xFFFF {
+		return -1, true
+	}
+	return best, true
+}
+
+// Check if the board can be probed, Syzygy tables do not hold castling rights
+func (b *Board) inSyzygy() bool {
+	return SYZYGY_MAX_PIECES > 0 && b.CR == 0 && bits.OnesCount64(uint64(b.Occupancy[EITHER_COLOR])) <= SYZYGY_MAX_PIECES
+}
+
+// Get the evaluation of the board from the Syzygy tables for the search, from the side to move's perspective
+// The tables count the fifty move rule from the last capture or pawn move, so they are only probed straight after one
+func (b *Board) syzygyEval(ply uint8) (Eval, bool) {
+	if b.HMC != 0 || !b.inSyzygy() {
+		return 0, false
+	}
+
+	wdl, _, ok := b.syzygySearch(false)
+	if !ok {
+		return 0, false
+	}
+
+	// Faster wins are better, cursed wins and blessed losses are kept just off a draw to play on for a mistake
+	switch wdl {
+	case WDL_WIN:
+		return SYZYGY_WIN - Eval(ply), true
+	case WDL_LOSS:
+		return -SYZYGY_WIN + Eval(ply), true
+	}
+	return Eval(wdl), true
+}
+
+// Filter the root moves to the ones keeping the best Syzygy result, returns how many are kept at the start of the moves
+// With the distance to zeroing files the fastest wins (or slowest losses) are kept, so wins always make progress,
+// with only the win/draw/loss files the moves keeping the result are kept. The moves are unchanged when the board is not in the tables
+func (b *Board) filterSyzygyRootMoves(moves []Move) int {
+	if !b.inSyzygy() {
+		return len(moves)
+	}
+
+	ranks := make([]int, len(moves))
+	legal := make([]bool, len(moves))
+	ok := true
+	for i, move := range moves {
+		undo, isLegal := b.makeMove(move)
+		legal[i] = isLegal
+		if isLegal {
+			ranks[i], ok = b.syzygyRootRank()
+		}
+		b.unMakeMove(undo)
+		if !ok {
+			break
+		}
+	}
+
+	// Without the distance to zeroing files, rank by the result alone
+	if !ok {
+		for i, move := range moves {
+			if !legal[i] {
+				continue
+			}
+			undo, _ := b.makeMove(move)
+			var wdl int
+			wdl, _, ok = b.syzygySearch(false)
+			ranks[i] = -wdl
+			b.unMakeMove(undo)
+			if !ok {
+				return len(moves)
+			}
+		}
+	}
+
+	best := 0
+	found := false
+	for i := range moves {
+		if legal[i] && (!found || ranks[i] > best) {
+			best, found = ranks[i], true
+		}
+	}
+	kept := 0
+	for i, move := range moves {
+		if legal[i] && ranks[i] == best {
+			moves[kept] = move
+			kept++
+		}
+	}
+	if kept == 0 {
+		return len(moves)
+	}
+	return kept
+}
+
+// Rank a root move by the distance to zeroing after it, from the perspective of the side that moved
+// Fast wins rank highest, then draws, then slow losses
+func (b *Board) syzygyRootRank() (int, bool) {
+	var dtz int
+	if b.HMC == 0 {
+		wdl, _, ok := b.syzygySearch(false)
+		if !ok {
+			return 0, false
+		}
+		dtz = dtzBeforeZeroing(-wdl)
+	} else {
+		value, ok := b.probeDTZ()
+		if !ok {
+			return 0, false
+		}
+		dtz = -value + sign(-value)
+	}
+
+	// A mate is a zeroing move
+	if dtz == 2 && b.isInCheck(b.Turn) && len(b.generateLegalMoves()) == 0 {
+		dtz = 1
+	}
+
+	switch {
+	case dtz > 0:
+		return 1<<16 - dtz, true
+	case dtz < 0:
+		return -1<<16 - dtz, true
+	}
+	return 0, true
+}
+
+// LoadSyzygy finds the Syzygy tables in directories separated like the PATH environment variable, returning how many were found
+// The files are mapped the first time they are probed, and the files of the tables loaded before are unmapped, so it
+// must not be called during a search
+func LoadSyzygy(path string) (int, error) {
+	for key, t := range SYZYGY_TABLES {
+		if key == t.key {
+			t.wdl.unload()
+			t.dtz.unload()
+		}
+	}
+	SYZYGY_TABLES = map[materialSignature]*syzygyTable{}
+	SYZYGY_MAX_PIECES = 0
+
+	found := 0
+	for _, dir := range filepath.SplitList(path) {
+		paths, err := filepath.Glob(filepath.Join(dir, "*"+SYZYGY_WDL_EXTENSION))
+		if err != nil {
+			return found, err
+		}
+		for _, wdlPath := range paths {
+			t, err := newSyzygyTable(strings.TrimSuffix(filepath.Base(wdlPath), SYZYGY_WDL_EXTENSION))
+			if err != nil {
+				return found, err
+			}
+			if _, ok := SYZYGY_TABLES[t.key]; ok {
+				continue
+			}
+
+			t.wdl.path = wdlPath
+			t.dtz.path = strings.TrimSuffix(wdlPath, SYZYGY_WDL_EXTENSION) + SYZYGY_DTZ_EXTENSION
+			t.dtz.isDTZ = true
+			SYZYGY_TABLES[t.key] = t
+			SYZYGY_TABLES[t.key2] = t
+			SYZYGY_MAX_PIECES = max(SYZYGY_MAX_PIECES, t.pieceCount)
+			found++
+		}
+	}
+	return found, nil
+}
+
+// ProbeSyzygy probes the Syzygy tables for a position, returning the win/draw/loss result and the distance to zeroing
+// from the perspective of the side to move, the tables must be loaded first
+func ProbeSyzygy(position FEN) (wdl int, dtz int, err error) {
+	board, err := position.toBoard(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !board.inSyzygy() {
+		return 0, 0, fmt.Errorf("Invalid position %v; Should have at most %d pieces and no castling rights", position, SYZYGY_MAX_PIECES)
+	}
+
+	wdl, _, ok := board.syzygySearch(false)
+	if !ok {
+		return 0, 0, fmt.Errorf("Failed to probe %v; The Syzygy table is missing", position)
+	}
+	if dtz, ok = board.probeDTZ(); !ok {
+		return wdl, 0, fmt.Errorf("Failed to probe %v; The Syzygy distance to zeroing table is missing", position)
+	}
+	return wdl, dtz, nil
+}
+
+// Setup the Syzygy tables of SYZYGY_PATH, called at engine startup
+func initSyzygy() {
+	initSyzygyIndexes()
+	if _, err := LoadSyzygy(SYZYGY_PATH); err != nil {
+		fmt.Printf("Failed to load the Syzygy tables: %v\n", err)
+	}
+}
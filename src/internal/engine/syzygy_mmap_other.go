@@ -0,0 +1,15 @@
+//go:build !unix
+
+package engine
+
+import "os"
+
+// Read a whole file into memory, where files are not mapped
+func mapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// Release a file read by mapFile, the garbage collector frees it
+func unmapFile(data []byte) error {
+	return nil
+}
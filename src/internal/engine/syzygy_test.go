@@ -0,0 +1,738 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewSyzygyTable(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected pieces, unique pieces and pawns of the leading side then the other side
+	tests := []struct {
+		code   string
+		pieces int
+		unique bool
+		pawns  [NUM_COLORS]int
+		err    bool
+	}{
+		{code: "KQvK", pieces: 3, unique: true},
+		{code: "KRRvK", pieces: 4, unique: false},
+		{code: "KPvKPP", pieces: 5, unique: true, pawns: [NUM_COLORS]int{1, 2}},
+		{code: "KPPvKP", pieces: 5, unique: true, pawns: [NUM_COLORS]int{1, 2}},
+		{code: "KPPvKPP", pieces: 6, unique: false, pawns: [NUM_COLORS]int{2, 2}},
+		{code: "KQK", err: true},
+		{code: "KQvQ", err: true},
+		{code: "KXvK", err: true},
+		{code: "KQRBNvKQR", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.code, func(t *testing.T) {
+			table, err := newSyzygyTable(tc.code)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", table.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if table.pieceCount != tc.pieces || table.hasUniquePieces != tc.unique || table.pawnCount != tc.pawns {
+				t.Errorf("Expected %d pieces, unique %v and pawns %v, got %d, %v and %v",
+					tc.pieces, tc.unique, tc.pawns, table.pieceCount, table.hasUniquePieces, table.pawnCount)
+			}
+		})
+	}
+
+	// The sizes of the index tables are fixed by the format
+	if SYZYGY_MAP_KK[9][63] != 461 || SYZYGY_MAP_PAWNS[8] != 47 || SYZYGY_LEAD_PAWNS_SIZE[1][0] != 6 || SYZYGY_BINOMIAL[2][5] != 10 {
+		t.Errorf("The index tables are not setup correctly")
+	}
+}
+
+func TestSyzygyDisabled(t *testing.T) {
+	InitEngine()
+
+	board, err := FEN("k7/8/1K6/8/8/8/7Q/8 w - - 0 1").toBoard(nil)
+	if err != nil {
+		t.Fatalf("Invalid FEN: %v", err)
+	}
+	if _, ok := board.syzygyEval(1); ok {
+		t.Errorf("Expected no Syzygy evaluation without tables")
+	}
+	var moves MoveList
+	numberOfMoves := board.generatePseudoLegalMoves(&moves)
+	if kept := board.filterSyzygyRootMoves(moves.slice()); kept != numberOfMoves {
+		t.Errorf("Expected all %d root moves to be kept without tables, got %d", numberOfMoves, kept)
+	}
+}
+
+func TestProbeSyzygy(t *testing.T) {
+	InitEngine()
+	defer func() {
+		TABLEBASES = map[materialSignature]tablebaseEntry{}
+		LoadSyzygy("")
+	}()
+
+	// The tables are written from the engine's own tablebases and bitbase, the KRvK distances are stored for black to move
+	// The drawn KBvK and KNvK are needed too, the pawn of KPvK can underpromote into them
+	for _, code := range []string{"KQK", "KRK"} {
+		if err := GenerateTablebase(code, ""); err != nil {
+			t.Fatalf("Failed to generate %v: %v", code, err)
+		}
+	}
+	dir := t.TempDir()
+	writeSyzygyTable(t, dir, "KQvK", WHITE)
+	writeSyzygyTable(t, dir, "KRvK", BLACK)
+	writeSyzygyTable(t, dir, "KPvK", WHITE)
+	writeSyzygyTable(t, dir, "KBvK", WHITE)
+	writeSyzygyTable(t, dir, "KNvK", WHITE)
+	if found, err := LoadSyzygy(dir); err != nil || found != 5 || SYZYGY_MAX_PIECES != 3 {
+		t.Fatalf("Expected 5 tables of 3 pieces, got %d of %d: %v", found, SYZYGY_MAX_PIECES, err)
+	}
+
+	// Check a spread of the positions, with the strong side as both colours
+	for _, code := range []string{"KQvK", "KRvK", "KPvK"} {
+		table, _ := newSyzygyTable(code)
+		pawnDTZs := syzygyPawnDTZs(table)
+		checked := 0
+		syzygyPositions(table, func(b *Board) {
+			checked++
+			if checked%97 != 0 {
+				return
+			}
+			for _, board := range []*Board{b, flipColors(b)} {
+				wdl, expectedDTZ := syzygyTruth(board)
+				if table.hasPawns {
+					expectedDTZ = pawnDTZs[b.Zobrist]
+				}
+				got, _, ok := board.syzygySearch(false)
+				if !ok || got != wdl {
+					t.Fatalf("%v: expected WDL %d, got %d (%v)", board.toFEN(), wdl, got, ok)
+				}
+				dtz, ok := board.probeDTZ()
+				if !ok || dtz != expectedDTZ {
+					t.Fatalf("%v: expected DTZ %d, got %d (%v)", board.toFEN(), expectedDTZ, dtz, ok)
+				}
+			}
+		})
+	}
+
+	// Only the moves with the fastest win are searched at the root
+	board, _ := FEN("k7/8/1K6/8/8/8/7Q/8 w - - 0 1").toBoard(nil)
+	var moves MoveList
+	board.generatePseudoLegalMoves(&moves)
+	moves.count = board.filterSyzygyRootMoves(moves.slice())
+	if moves.count != 1 || moves.moves[0].toPCN() != "h2h8" {
+		t.Errorf("Expected only the mate h2h8 to be kept, got %v", moves.slice())
+	}
+
+	// A win is a cutoff in the search after a capture or pawn move
+	board, _ = FEN("8/8/8/3k4/8/8/8/R3K3 w - - 0 1").toBoard(nil)
+	if eval, ok := board.syzygyEval(2); !ok || eval != SYZYGY_WIN-2 {
+		t.Errorf("Expected a tablebase win, got %d (%v)", eval, ok)
+	}
+
+	// A cut short file fails to probe instead of crashing
+	data, err := os.ReadFile(filepath.Join(dir, "KQvK"+SYZYGY_WDL_EXTENSION))
+	if err != nil {
+		t.Fatalf("Failed to read the table: %v", err)
+	}
+	corrupt := t.TempDir()
+	if err := os.WriteFile(filepath.Join(corrupt, "KQvK"+SYZYGY_WDL_EXTENSION), data[:len(data)/2], 0644); err != nil {
+		t.Fatalf("Failed to write the table: %v", err)
+	}
+	LoadSyzygy(corrupt)
+	board, _ = FEN("k7/8/1K6/8/8/8/7Q/8 w - - 0 1").toBoard(nil)
+	if _, _, ok := board.syzygySearch(false); ok {
+		t.Errorf("Expected probing a cut short table to fail")
+	}
+}
+
+// The real tables of testdata/syzygy, from the Syzygy generator, checked against the engine's own tablebases and bitbase
+// The tables written by the other tests use the prober's own indexing, so only real tables catch a bug on both sides
+// Real distances are stored in moves when that does not change the result, so they can be one ply longer
+func TestProbeSyzygyFiles(t *testing.T) {
+	InitEngine()
+	defer func() {
+		TABLEBASES = map[materialSignature]tablebaseEntry{}
+		LoadSyzygy("")
+	}()
+
+	dir := filepath.Join("testdata", "syzygy")
+	found, err := LoadSyzygy(dir)
+	if err != nil {
+		t.Fatalf("Failed to load %v: %v", dir, err)
+	}
+	if found == 0 {
+		t.Fatalf("No Syzygy tables in %v; Should have the real KQvK, KRvK, KPvK, KBvK and KNvK (.rtbw and .rtbz) of the Syzygy generator", dir)
+	}
+	for _, code := range []string{"KQK", "KRK"} {
+		if err := GenerateTablebase(code, ""); err != nil {
+			t.Fatalf("Failed to generate %v: %v", code, err)
+		}
+	}
+
+	for _, code := range []string{"KQvK", "KRvK", "KPvK", "KBvK", "KNvK"} {
+		table, _ := newSyzygyTable(code)
+		if _, err := os.Stat(filepath.Join(dir, code+SYZYGY_WDL_EXTENSION)); err != nil {
+			t.Errorf("Missing %v: %v", code, err)
+			continue
+		}
+		pawnDTZs := syzygyPawnDTZs(table)
+		checked := 0
+		syzygyPositions(table, func(b *Board) {
+			checked++
+			if checked%31 != 0 {
+				return
+			}
+			for _, board := range []*Board{b, flipColors(b)} {
+				wdl, expectedDTZ := syzygyTruth(board)
+				if table.hasPawns {
+					expectedDTZ = pawnDTZs[b.Zobrist]
+				}
+				got, _, ok := board.syzygySearch(false)
+				if !ok || got != wdl {
+					t.Fatalf("%v: expected WDL %d, got %d (%v)", board.toFEN(), wdl, got, ok)
+				}
+				dtz, ok := board.probeDTZ()
+				if !ok || sign(dtz) != sign(wdl) {
+					t.Fatalf("%v: expected a DTZ with the sign of %d, got %d (%v)", board.toFEN(), wdl, dtz, ok)
+				}
+				if (dtz < expectedDTZ-1 || dtz > expectedDTZ+1) && wdl != WDL_DRAW {
+					t.Fatalf("%v: expected DTZ %d, got %d", board.toFEN(), expectedDTZ, dtz)
+				}
+			}
+		})
+	}
+}
+
+// The result and distance to zeroing of a board from the engine's own tablebases and bitbase, from the perspective of the side to move
+// Without pawns there are no zeroing moves on the way to mate, so the distance to zeroing is the distance to mate
+func syzygyTruth(b *Board) (int, int) {
+	if b.isKPK() {
+		pawnSide := WHITE
+		if b.Pieces[WHITE][PAWN] == 0 {
+			pawnSide = BLACK
+		}
+		switch {
+		case !b.probeKPK():
+			return WDL_DRAW, 0
+		case b.Turn == pawnSide:
+			return WDL_WIN, 0
+		}
+		return WDL_LOSS, 0
+	}
+
+	result, plies, _ := b.probeTablebase()
+	switch result {
+	case 1:
+		return WDL_WIN, plies
+	case -1:
+		return WDL_LOSS, -max(plies, 1)
+	}
+	return WDL_DRAW, 0
+}
+
+// The board with the colors swapped and flipped vertically, the same position for the other side
+func flipColors(b *Board) *Board {
+	var pieces []placedPiece
+	for color := WHITE; color <= BLACK; color++ {
+		for piece := PAWN; piece <= KING; piece++ {
+			for squares := b.Pieces[color][piece]; squares != 0; {
+				pieces = append(pieces, placedPiece{color ^ 1, piece, squares.popSquare() ^ 56})
+			}
+		}
+	}
+	return newBoardFromPieces(b.Turn^1, pieces)
+}
+
+// Call f with every legal position of a table, with the first side of the code white
+func syzygyPositions(table *syzygyTable, f func(b *Board)) {
+	white, black, _ := strings.Cut(table.code, "v")
+	var pieces []placedPiece
+	for color, side := range []string{white, black} {
+		for _, c := range side {
+			piece := map[rune]Piece{'P': PAWN, 'N': KNIGHT, 'B': BISHOP, 'R': ROOK, 'Q': QUEEN, 'K': KING}[c]
+			pieces = append(pieces, placedPiece{Color(color), piece, 0})
+		}
+	}
+
+	var place func(i int, occupancy BitBoard)
+	place = func(i int, occupancy BitBoard) {
+		if i == len(pieces) {
+			for _, turn := range []Color{WHITE, BLACK} {
+				b := newBoardFromPieces(turn, pieces)
+				if !b.isInCheck(turn ^ 1) {
+					f(b)
+				}
+			}
+			return
+		}
+		for sq := range Square(NUM_SQUARES) {
+			if occupancy&sq.bitBoardPosition() != 0 || (pieces[i].piece == PAWN && (sq < 8 || sq >= 56)) {
+				continue
+			}
+			pieces[i].sq = sq
+			place(i+1, occupancy|sq.bitBoardPosition())
+		}
+	}
+	place(0, 0)
+}
+
+// The distance to zeroing of every position of a table with pawns, with the first side of the code white
+// Worked out backwards from the results of syzygyTruth: the winning side plays the fastest zeroing move that keeps the win,
+// and the losing side puts it off the longest
+func syzygyPawnDTZs(table *syzygyTable) map[ZobristHash]int {
+	dtzs := map[ZobristHash]int{}
+	if !table.hasPawns {
+		return dtzs
+	}
+
+	// The positions to work back from, by the distance to zeroing, mated positions are at 0
+	var queue [][]ZobristHash
+	assign := func(key ZobristHash, dtz, distance int) {
+		dtzs[key] = dtz
+		for len(queue) <= distance {
+			queue = append(queue, nil)
+		}
+		queue[distance] = append(queue[distance], key)
+	}
+
+	// The results, the positions each position is reached from without zeroing, and how many moves of each loss are not worked out
+	results := map[ZobristHash]int{}
+	predecessors := map[ZobristHash][]ZobristHash{}
+	unknown := map[ZobristHash]int{}
+	syzygyPositions(table, func(b *Board) {
+		key := b.Zobrist
+		wdl, _ := syzygyTruth(b)
+		results[key] = wdl
+		if wdl == WDL_DRAW {
+			return
+		}
+
+		moves := b.generateLegalMoves()
+		if len(moves) == 0 {
+			assign(key, -1, 0)
+			return
+		}
+		winsByZeroing := false
+		for _, move := range moves {
+			zeroing := move.code() == MOVE_CODE_CAPTURE || move.code() == MOVE_CODE_EN_PASSANT || b.MailBox[move.start()] == PAWN
+			undo, _ := b.makeMove(move)
+			if !zeroing {
+				predecessors[b.Zobrist] = append(predecessors[b.Zobrist], key)
+			} else if after, _ := syzygyTruth(b); after == WDL_LOSS {
+				winsByZeroing = true
+			}
+			b.unMakeMove(undo)
+		}
+		switch {
+		case wdl == WDL_WIN && winsByZeroing:
+			assign(key, 1, 1)
+		case wdl == WDL_LOSS:
+			unknown[key] = len(moves)
+		}
+	})
+
+	// Work back from the shortest distances, so a win takes its fastest move to a loss, and a loss is only known after all its moves
+	for distance := 0; distance < len(queue); distance++ {
+		for i := 0; i < len(queue[distance]); i++ {
+			key := queue[distance][i]
+			for _, predecessor := range predecessors[key] {
+				if _, ok := dtzs[predecessor]; ok {
+					continue
+				}
+				switch {
+				case results[predecessor] == WDL_WIN && results[key] == WDL_LOSS:
+					assign(predecessor, distance+1, distance+1)
+				case results[predecessor] == WDL_LOSS:
+					if unknown[predecessor]--; unknown[predecessor] == 0 {
+						assign(predecessor, -(distance + 1), distance+1)
+					}
+				}
+			}
+		}
+	}
+	return dtzs
+}
+
+// Write the win/draw/loss file and the distance to zeroing file of a table, the distances are held for one side to move
+func writeSyzygyTable(t *testing.T, dir, code string, dtzSide Color) {
+	table, err := newSyzygyTable(code)
+	if err != nil {
+		t.Fatalf("Invalid table: %v", err)
+	}
+	pawnDTZs := syzygyPawnDTZs(table)
+	wdlWriter := newSyzygyWriter(table, false, 0)
+	dtzWriter := newSyzygyWriter(table, true, uint8(dtzSide)|SYZYGY_FLAG_MAPPED|SYZYGY_FLAG_WIN_PLIES|SYZYGY_FLAG_LOSS_PLIES)
+	syzygyPositions(table, func(b *Board) {
+		wdl, dtz := syzygyTruth(b)
+		if table.hasPawns {
+			dtz = pawnDTZs[b.Zobrist]
+		}
+		if err := wdlWriter.add(b, wdl, wdl+2); err != nil {
+			t.Fatal(err)
+		}
+		if wdl != WDL_DRAW {
+			if err := dtzWriter.add(b, wdl, max(dtz, -dtz)-1); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	path := filepath.Join(dir, code)
+	if err := os.WriteFile(path+SYZYGY_WDL_EXTENSION, wdlWriter.bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write the table: %v", err)
+	}
+	if err := os.WriteFile(path+SYZYGY_DTZ_EXTENSION, dtzWriter.bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write the table: %v", err)
+	}
+}
+
+// Writes a Syzygy file the way the generator does, with the most frequent runs of values paired into symbols
+type syzygyWriter struct {
+	table  *syzygyTable
+	file   *syzygyFile
+	files  int
+	sides  int
+	values [4][NUM_COLORS]map[uint64]syzygyWriterValue
+}
+
+// A value to write, distances are written as their place in the map of their result
+type syzygyWriterValue struct {
+	wdl   int
+	value int
+}
+
+func newSyzygyWriter(table *syzygyTable, isDTZ bool, flags uint8) *syzygyWriter {
+	w := &syzygyWriter{table: table, file: &syzygyFile{isDTZ: isDTZ}, files: 1, sides: 1}
+	if table.hasPawns {
+		w.files = 4
+	}
+	if !isDTZ && table.key != table.key2 {
+		w.sides = 2
+	}
+
+	// The pieces are in a different order for each side to move, with the leading pawn first (only white pawns can lead)
+	white, black, _ := strings.Cut(table.code, "v")
+	var pieces []uint8
+	for color, side := range []string{white, black} {
+		for _, c := range side {
+			piece := map[rune]Piece{'P': PAWN, 'N': KNIGHT, 'B': BISHOP, 'R': ROOK, 'Q': QUEEN, 'K': KING}[c]
+			pieces = append(pieces, syzygyPiece(Color(color), piece))
+		}
+	}
+	reversed := 0
+	if lead := slices.Index(pieces, syzygyPiece(WHITE, PAWN)); lead >= 0 {
+		pawn := pieces[lead]
+		pieces = append([]uint8{pawn}, slices.Delete(pieces, lead, lead+1)...)
+		reversed = 1
+	}
+	for file := range w.files {
+		for side := range NUM_COLORS {
+			p := &w.file.pairs[file][side]
+			p.flags = flags
+			copy(p.pieces[:], pieces)
+			if Color(side) == BLACK {
+				slices.Reverse(p.pieces[reversed:len(pieces)])
+			}
+			table.setGroups(p, [2]int{0, 0xF}, file)
+			w.values[file][side] = map[uint64]syzygyWriterValue{}
+		}
+	}
+	return w
+}
+
+// Add the value of a board, failing if another position with the same index has a different value
+func (w *syzygyWriter) add(b *Board, wdl, value int) error {
+	file, side, index, changeSide := w.table.encode(w.file, b)
+	if changeSide {
+		return nil
+	}
+	v := syzygyWriterValue{wdl, value}
+	if previous, ok := w.values[file][side][index]; ok && previous != v {
+		return fmt.Errorf("%v: index %d already has %v, got %v", b.toFEN(), index, previous, v)
+	}
+	w.values[file][side][index] = v
+	return nil
+}
+
+// Get the bytes of the file
+func (w *syzygyWriter) bytes() []byte {
+	magic := SYZYGY_WDL_MAGIC
+	if w.file.isDTZ {
+		magic = SYZYGY_DTZ_MAGIC
+	}
+	data := append([]byte{}, magic[:]...)
+	header := byte(0)
+	if w.table.key != w.table.key2 {
+		header |= 1
+	}
+	if w.table.hasPawns {
+		header |= 2
+	}
+	data = append(data, header)
+	for file := range w.files {
+		data = append(data, 0)
+		for k := range w.table.pieceCount {
+			data = append(data, w.file.pairs[file][0].pieces[k]|w.file.pairs[file][1].pieces[k]<<4)
+		}
+	}
+	data = append(data, make([]byte, len(data)&1)...)
+
+	// The distances of each result are mapped, in the order win, loss, cursed win, blessed loss
+	var maps [4][4][]int
+	var encoded [4][NUM_COLORS]syzygyEncoded
+	for file := range w.files {
+		if w.file.isDTZ {
+			for _, value := range w.values[file][0] {
+				m := &maps[file][SYZYGY_DTZ_MAPS[value.wdl+2]]
+				if !slices.Contains(*m, value.value) {
+					*m = append(*m, value.value)
+				}
+			}
+		}
+		for side := range w.sides {
+			p := &w.file.pairs[file][side]
+			values := make([]int, p.groupIndex[slices.Index(p.groupLength[:], 0)])
+			for index, value := range w.values[file][side] {
+				values[index] = value.value
+				if w.file.isDTZ {
+					values[index] = slices.Index(maps[file][SYZYGY_DTZ_MAPS[value.wdl+2]], value.value)
+				}
+			}
+			encoded[file][side] = encodeSyzygyValues(values, p.flags)
+			data = append(data, encoded[file][side].header...)
+		}
+	}
+	if w.file.isDTZ {
+		for file := range w.files {
+			for _, m := range maps[file] {
+				data = append(data, byte(len(m)))
+				for _, value := range m {
+					data = append(data, byte(value))
+				}
+			}
+		}
+		data = append(data, make([]byte, len(data)&1)...)
+	}
+	for file := range w.files {
+		for side := range w.sides {
+			data = append(data, encoded[file][side].sparseIndex...)
+		}
+	}
+	for file := range w.files {
+		for side := range w.sides {
+			data = append(data, encoded[file][side].blockLength...)
+		}
+	}
+	for file := range w.files {
+		for side := range w.sides {
+			data = append(data, make([]byte, (64-len(data)%64)%64)...)
+			data = append(data, encoded[file][side].blocks...)
+		}
+	}
+	return data
+}
+
+// The compressed values of one file of the leading pawn and side to move
+type syzygyEncoded struct {
+	header      []byte
+	sparseIndex []byte
+	blockLength []byte
+	blocks      []byte
+}
+
+// Pair values into symbols, Huffman code the symbols into blocks, with a sparse index into them
+func encodeSyzygyValues(values []int, flags uint8) syzygyEncoded {
+	const blockSizeLog, spanLog = 6, 7
+	var e syzygyEncoded
+
+	frequencies := map[int]int{}
+	for _, value := range values {
+		frequencies[value]++
+	}
+	if len(frequencies) == 1 {
+		e.header = []byte{flags | SYZYGY_FLAG_SINGLE_VALUE, byte(values[0])}
+		return e
+	}
+
+	// Values are their own symbols, and the most frequent adjacent symbols are paired into new symbols (numbered from
+	// SYZYGY_PAIR_SYMBOL), like the generator does, so the tests expand pairs the way real files need
+	stream, pairs := pairSyzygySymbols(values)
+	frequencies = map[int]int{}
+	for _, sym := range stream {
+		frequencies[sym]++
+	}
+	for sym, pair := range pairs {
+		frequencies[sym] = max(frequencies[sym], 1)
+		frequencies[pair[0]] = max(frequencies[pair[0]], 1)
+		frequencies[pair[1]] = max(frequencies[pair[1]], 1)
+	}
+	var valuesOf func(sym int) int
+	valuesOf = func(sym int) int {
+		if pair, ok := pairs[sym]; ok {
+			return valuesOf(pair[0]) + valuesOf(pair[1])
+		}
+		return 1
+	}
+
+	// Build the Huffman code lengths by merging the two rarest nodes until one is left
+	type node struct {
+		weight  int
+		symbols []int
+	}
+	var nodes []node
+	lengths := map[int]int{}
+	for sym, frequency := range frequencies {
+		nodes = append(nodes, node{frequency, []int{sym}})
+	}
+	slices.SortFunc(nodes, func(a, b node) int { return cmp.Compare(a.symbols[0], b.symbols[0]) })
+	for len(nodes) > 1 {
+		slices.SortStableFunc(nodes, func(a, b node) int { return cmp.Compare(a.weight, b.weight) })
+		merged := node{nodes[0].weight + nodes[1].weight, append(slices.Clone(nodes[0].symbols), nodes[1].symbols...)}
+		for _, sym := range merged.symbols {
+			lengths[sym]++
+		}
+		nodes = append([]node{merged}, nodes[2:]...)
+	}
+
+	// The symbols are numbered from the longest code, and each length's codes count up from its base
+	symbols := make([]int, 0, len(lengths))
+	for sym := range lengths {
+		symbols = append(symbols, sym)
+	}
+	slices.SortFunc(symbols, func(a, b int) int {
+		return cmp.Or(cmp.Compare(lengths[b], lengths[a]), cmp.Compare(a, b))
+	})
+	maxLen, minLen := lengths[symbols[0]], lengths[symbols[len(symbols)-1]]
+	lowest := make([]int, maxLen+2)
+	base := make([]int, maxLen+2)
+	counts := make([]int, maxLen+2)
+	for _, sym := range symbols {
+		counts[lengths[sym]]++
+	}
+	for length := maxLen - 1; length >= minLen; length-- {
+		lowest[length] = lowest[length+1] + counts[length+1]
+		base[length] = (base[length+1] + counts[length+1]) / 2
+	}
+	symbolOf := map[int]int{}
+	for number, sym := range symbols {
+		symbolOf[sym] = number
+	}
+
+	// Pack the codes into blocks, starting a new block when a code does not fit, the blocks count the values of their symbols
+	blockBits := 8 << blockSizeLog
+	var blockCounts []int
+	var blocks []byte
+	used := blockBits
+	for _, sym := range stream {
+		length := lengths[sym]
+		if used+length > blockBits {
+			blocks = append(blocks, make([]byte, blockBits/8)...)
+			blockCounts = append(blockCounts, 0)
+			used = 0
+		}
+		code := base[length] + symbolOf[sym] - lowest[length]
+		start := len(blocks)*8 - blockBits + used
+		for bit := range length {
+			if code>>(length-1-bit)&1 != 0 {
+				blocks[(start+bit)/8] |= 0x80 >> ((start + bit) % 8)
+			}
+		}
+		used += length
+		blockCounts[len(blockCounts)-1] += valuesOf(sym)
+	}
+	e.blocks = blocks
+
+	e.header = []byte{flags, blockSizeLog, spanLog, 0}
+	e.header = binary.LittleEndian.AppendUint32(e.header, uint32(len(blockCounts)))
+	e.header = append(e.header, byte(maxLen), byte(minLen))
+	for length := minLen; length <= maxLen; length++ {
+		e.header = binary.LittleEndian.AppendUint16(e.header, uint16(lowest[length]))
+	}
+	e.header = binary.LittleEndian.AppendUint16(e.header, uint16(len(symbols)))
+	for _, sym := range symbols {
+		if pair, ok := pairs[sym]; ok {
+			left, right := symbolOf[pair[0]], symbolOf[pair[1]]
+			e.header = append(e.header, byte(left), byte(left>>8)&0xF|byte(right&0xF)<<4, byte(right>>4))
+		} else {
+			e.header = append(e.header, byte(sym), byte(sym>>8)&0xF|0xF0, 0xFF)
+		}
+	}
+	e.header = append(e.header, make([]byte, len(symbols)&1)...)
+
+	for _, count := range blockCounts {
+		e.blockLength = binary.LittleEndian.AppendUint16(e.blockLength, uint16(count-1))
+	}
+
+	// Each sparse index entry is the block and offset of the value in the middle of its span
+	span := 1 << spanLog
+	for k := 0; k*span < len(values); k++ {
+		target := k*span + span/2
+		block, start := 0, 0
+		for block < len(blockCounts)-1 && start+blockCounts[block] <= target {
+			start += blockCounts[block]
+			block++
+		}
+		e.sparseIndex = binary.LittleEndian.AppendUint32(e.sparseIndex, uint32(block))
+		e.sparseIndex = binary.LittleEndian.AppendUint16(e.sparseIndex, uint16(target-start))
+	}
+	return e
+}
+
+// Symbols above the values are pairs of symbols in the test files
+const SYZYGY_PAIR_SYMBOL = 1 << 12
+
+// The most pairs made of a file's values
+const SYZYGY_MAX_PAIRS = 16
+
+// Replace the most frequent adjacent symbols with a pair of them, as long as one is frequent enough to be worth it
+// A symbol expands to at most 256 values, the format stores the lengths in a byte
+// Returns the symbols of the values and the two symbols of each pair
+func pairSyzygySymbols(values []int) ([]int, map[int][2]int) {
+	stream := slices.Clone(values)
+	pairs := map[int][2]int{}
+	lengths := map[int]int{}
+	length := func(sym int) int { return max(lengths[sym], 1) }
+	for sym := SYZYGY_PAIR_SYMBOL; sym < SYZYGY_PAIR_SYMBOL+SYZYGY_MAX_PAIRS; sym++ {
+		counts := map[[2]int]int{}
+		for i := 0; i+1 < len(stream); i++ {
+			if length(stream[i])+length(stream[i+1]) <= 256 {
+				counts[[2]int{stream[i], stream[i+1]}]++
+			}
+		}
+		best, bestCount := [2]int{}, 0
+		for pair, count := range counts {
+			if count > bestCount || (count == bestCount && slices.Compare(pair[:], best[:]) < 0) {
+				best, bestCount = pair, count
+			}
+		}
+		if bestCount < 4 {
+			break
+		}
+
+		paired := stream[:0:0]
+		for i := 0; i < len(stream); i++ {
+			if i+1 < len(stream) && stream[i] == best[0] && stream[i+1] == best[1] {
+				paired = append(paired, sym)
+				i++
+			} else {
+				paired = append(paired, stream[i])
+			}
+		}
+		stream = paired
+		pairs[sym] = best
+		lengths[sym] = length(best[0]) + length(best[1])
+	}
+	return stream, pairs
+}
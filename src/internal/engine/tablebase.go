@@ -0,0 +1,583 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+This file holds the tablebases of small endgames, generated by the engine itself with retrograde analysis.
+A tablebase holds the distance to mate of every position of an endgame without pawns of up to 4 pieces (KQK, KRK, KBNK, KQKR, ...),
+with either side to move. It is solved backwards from the mates: a position that can move to a lost position is won,
+and a position where every move reaches a won position is lost. The moves are undone with an un-move generator,
+and every position is only checked forwards with the move generator once, to count its moves and look up its captures.
+Tables are saved to disk in a custom format, and probed by the search which then plays these endgames perfectly.
+Like other distance to mate tablebases, the fifty move rule is ignored.
+*/
+
+// The most pieces (including the kings) a tablebase can have
+const TABLEBASE_MAX_PIECES = 4
+
+// Directory the tablebases are loaded from when the engine is initialized, none are loaded when empty
+var TABLEBASE_DIR string
+
+// File extension and header of a saved tablebase
+const TABLEBASE_EXTENSION = ".ztb"
+const TABLEBASE_MAGIC = "ZZTB"
+const TABLEBASE_VERSION = 1
+
+// A tablebase of an endgame
+type Tablebase struct {
+	// The endgame, like "KQKR", the first side is stored as white
+	Code string
+
+	// The pieces of the positions in index order: the white king, white's pieces, the black king, then black's pieces
+	pieces []tablebasePiece
+
+	// The distance to mate of each position, from the perspective of the side to move
+	// n > 0 mates in n moves, n < 0 is mated in -n-1 moves, 0 is a draw (or not a legal position)
+	dtm []int8
+}
+
+type tablebasePiece struct {
+	color Color
+	piece Piece
+}
+
+// The tablebases that are loaded or generated, by the material signature of the boards they hold
+// flip is true when the colors of the board have to be swapped to probe the tablebase
+var TABLEBASES = map[materialSignature]tablebaseEntry{}
+
+type tablebaseEntry struct {
+	tablebase *Tablebase
+	flip      bool
+}
+
+// The pieces that can be in a tablebase, in the order they are written in the code
+var TABLEBASE_PIECES = map[rune]Piece{'Q': QUEEN, 'R': ROOK, 'B': BISHOP, 'N': KNIGHT}
+
+// Setup an empty tablebase for an endgame code, like "KQKR"
+// The code is put in order, so "KRKQ" is the same tablebase as "KQKR" with the colors flipped
+func newTablebase(code string) (*Tablebase, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !strings.HasPrefix(code, "K") || strings.Count(code, "K") != 2 {
+		return nil, fmt.Errorf("Invalid tablebase %q; Should be a king and pieces for each side, like KQKR", code)
+	}
+	if strings.Contains(code, "P") {
+		return nil, fmt.Errorf("Invalid tablebase %q; Pawns are not supported", code)
+	}
+	if len(code) > TABLEBASE_MAX_PIECES {
+		return nil, fmt.Errorf("Invalid tablebase %q; Should be at most %d pieces", code, TABLEBASE_MAX_PIECES)
+	}
+
+	second := strings.LastIndex(code, "K")
+	sides := [NUM_COLORS]string{code[1:second], code[second+1:]}
+	var values [NUM_COLORS]Eval
+	for color, side := range sides {
+		// Order the pieces of each side from the strongest
+		ordered := ""
+		for _, c := range "QRBN" {
+			ordered += strings.Repeat(string(c), strings.Count(side, string(c)))
+		}
+		if len(ordered) != len(side) {
+			return nil, fmt.Errorf("Invalid tablebase %q; Pieces should be Q, R, B or N", code)
+		}
+		sides[color] = ordered
+		for _, c := range ordered {
+			values[color] += PIECE_VALUES[TABLEBASE_PIECES[c]]
+		}
+	}
+
+	// The stronger side is stored as white
+	if values[BLACK] > values[WHITE] || (values[BLACK] == values[WHITE] && sides[BLACK] < sides[WHITE]) {
+		sides[WHITE], sides[BLACK] = sides[BLACK], sides[WHITE]
+	}
+
+	t := &Tablebase{Code: "K" + sides[WHITE] + "K" + sides[BLACK]}
+	for color, side := range sides {
+		t.pieces = append(t.pieces, tablebasePiece{Color(color), KING})
+		for _, c := range side {
+			t.pieces = append(t.pieces, tablebasePiece{Color(color), TABLEBASE_PIECES[c]})
+		}
+	}
+	return t, nil
+}
+
+// The number of positions in the tablebase
+// The white king is kept in the a1-d4 corner by mirroring the board, the other pieces can be anywhere
+func (t *Tablebase) size() int {
+	size := 16 * int(NUM_COLORS)
+	for range t.pieces[1:] {
+		size *= NUM_SQUARES
+	}
+	return size
+}
+
+// Get the index of a position, given the squares of the pieces in index order
+func (t *Tablebase) encode(squares [TABLEBASE_MAX_PIECES]Square, turn Color) int {
+	// Without pawns the board can be mirrored across both middle lines, this puts the white king in the a1-d4 corner
+	// No position is its own mirror image, so every position has exactly one index
+	transform := Square(0)
+	if squares[0]%8 > 3 {
+		transform ^= 7
+	}
+	if squares[0]/8 > 3 {
+		transform ^= 56
+	}
+	for i := range t.pieces {
+		squares[i] ^= transform
+	}
+
+	// Identical pieces can be swapped, so they are kept in order of their squares
+	for i := 1; i < len(t.pieces); i++ {
+		if t.pieces[i] == t.pieces[i-1] && squares[i] < squares[i-1] {
+			squares[i], squares[i-1] = squares[i-1], squares[i]
+		}
+	}
+
+	index := int(squares[0]/8)*4 + int(squares[0]%8)
+	for _, sq := range squares[1:len(t.pieces)] {
+		index = index*NUM_SQUARES + int(sq)
+	}
+	return index*int(NUM_COLORS) + int(turn)
+}
+
+// Get the squares of the pieces and the side to move of an index
+func (t *Tablebase) decode(index int) ([TABLEBASE_MAX_PIECES]Square, Color) {
+	var squares [TABLEBASE_MAX_PIECES]Square
+	turn := Color(index % int(NUM_COLORS))
+	index /= int(NUM_COLORS)
+	for i := len(t.pieces) - 1; i > 0; i-- {
+		squares[i] = Square(index % NUM_SQUARES)
+		index /= NUM_SQUARES
+	}
+	squares[0] = Square((index/4)*8 + index%4)
+	return squares, turn
+}
+
+// Get the squares of the pieces of a board in index order, and the side to move
+func (t *Tablebase) boardSquares(b *Board, flip bool) ([TABLEBASE_MAX_PIECES]Square, Color) {
+	var squares [TABLEBASE_MAX_PIECES]Square
+	remaining := b.Pieces
+	turn := b.Turn
+	flipSquare := Square(0)
+	if flip {
+		turn ^= 1
+		flipSquare = 56
+	}
+	for i, p := range t.pieces {
+		color := p.color
+		if flip {
+			color ^= 1
+		}
+		squares[i] = remaining[color][p.piece].popSquare() ^ flipSquare
+	}
+	return squares, turn
+}
+
+// Setup the board of an index, returns nil if two pieces are on the same square
+func (t *Tablebase) board(index int) *Board {
+	squares, turn := t.decode(index)
+	pieces := make([]placedPiece, 0, TABLEBASE_MAX_PIECES)
+	var occupancy BitBoard
+	for i, p := range t.pieces {
+		if occupancy&squares[i].bitBoardPosition() != 0 {
+			return nil
+		}
+		occupancy |= squares[i].bitBoardPosition()
+		pieces = append(pieces, placedPiece{p.color, p.piece, squares[i]})
+	}
+	return newBoardFromPieces(turn, pieces)
+}
+
+// Add the positions that could have moved to a position without a capture
+func (t *Tablebase) unMoves(index int, previous []int) []int {
+	squares, turn := t.decode(index)
+	var occupancy BitBoard
+	for _, sq := range squares[:len(t.pieces)] {
+		occupancy |= sq.bitBoardPosition()
+	}
+
+	// The side that just moved is the one not to move, and every piece moves back the way it came
+	moved := turn ^ 1
+	for i, p := range t.pieces {
+		if p.color != moved {
+			continue
+		}
+		sq := squares[i]
+		var from BitBoard
+		switch p.piece {
+		case KING:
+			from = KING_MOVES[sq]
+		case KNIGHT:
+			from = KNIGHT_MOVES[sq]
+		case BISHOP:
+			from = getBishopAttacks(sq, occupancy)
+		case ROOK:
+			from = getRookAttacks(sq, occupancy)
+		case QUEEN:
+			from = getBishopAttacks(sq, occupancy) |
+				getRookAttacks(sq, occupancy)
+		}
+		from &^= occupancy
+		for from != 0 {
+			before := squares
+			before[i] = from.popSquare()
+			previous = append(previous, t.encode(before, moved))
+		}
+	}
+	return previous
+}
+
+// The state of a position while a tablebase is being generated
+const (
+	TB_UNKNOWN uint8 = iota
+	TB_INVALID
+	TB_DRAW
+	TB_WIN
+	TB_LOSS
+)
+
+// A position to resolve once the positions are resolved in order of the plies to mate
+type tablebaseScheduled struct {
+	index int
+	plies int
+}
+
+// Solve every position of the tablebase, the tablebases it captures into must already be registered
+func (t *Tablebase) generate() error {
+	size := t.size()
+	status := make([]uint8, size)
+
+	// Quiet moves that are not known to lose yet, the longest loss found so far,
+	// and if a draw or a win was found in the captures
+	remaining := make([]uint8, size)
+	lossPlies := make([]uint8, size)
+	canDraw := make([]bool, size)
+	hasWin := make([]bool, size)
+
+	// Check every position forwards once, in parallel as this is the slow part
+	workers := runtime.NumCPU()
+	scheduled := make([][]tablebaseScheduled, workers)
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var moves MoveList
+			for index := worker; index < size; index += workers {
+				// Identical pieces out of order are the same position as another index, and are never probed
+				board := t.board(index)
+				if board == nil || t.encode(t.decode(index)) != index {
+					status[index] = TB_INVALID
+					continue
+				}
+
+				// The side not to move can not be in check, this also stops the kings touching
+				if board.isInCheck(board.Turn ^ 1) {
+					status[index] = TB_INVALID
+					continue
+				}
+
+				legalMoves, quiet := 0, 0
+				bestWin, worstLoss := 0, 0
+				board.generatePseudoLegalMoves(&moves)
+				for _, move := range moves.slice() {
+					undo, isLegal := board.makeMove(move)
+					if isLegal {
+						legalMoves++
+						if move.code() != MOVE_CODE_CAPTURE {
+							quiet++
+						} else {
+							// Captures leave the tablebase, so the result is looked up in the smaller one
+							result, plies, _ := board.probeTablebase()
+							switch {
+							case result < 0 && (bestWin == 0 || plies+1 < bestWin):
+								bestWin = plies + 1
+							case result == 0:
+								canDraw[index] = true
+							case result > 0:
+								worstLoss = max(worstLoss, plies+1)
+							}
+						}
+					}
+					board.unMakeMove(undo)
+				}
+
+				switch {
+				case legalMoves == 0 && board.isInCheck(board.Turn):
+					scheduled[worker] = append(scheduled[worker], tablebaseScheduled{index, 0})
+				case legalMoves == 0:
+					status[index] = TB_DRAW
+				case bestWin > 0:
+					hasWin[index] = true
+					scheduled[worker] = append(scheduled[worker], tablebaseScheduled{index, bestWin})
+				case quiet == 0 && canDraw[index]:
+					status[index] = TB_DRAW
+				case quiet == 0:
+					scheduled[worker] = append(scheduled[worker], tablebaseScheduled{index, worstLoss})
+				}
+				remaining[index] = uint8(quiet)
+				lossPlies[index] = uint8(worstLoss)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Positions to resolve by the plies to mate, an odd number of plies is a win for the side to move, even is a loss
+	var buckets [][]int
+	schedule := func(index, plies int) {
+		for len(buckets) <= plies {
+			buckets = append(buckets, nil)
+		}
+		buckets[plies] = append(buckets[plies], index)
+	}
+	for _, worker := range scheduled {
+		for _, s := range worker {
+			schedule(s.index, s.plies)
+		}
+	}
+
+	// Resolve the positions from the shortest mates, so each position gets the fastest win or the slowest loss
+	t.dtm = make([]int8, size)
+	previous := make([]int, 0, 64)
+	for plies := 0; plies < len(buckets); plies++ {
+		if plies > 253 {
+			return fmt.Errorf("Failed to generate %v; Mates longer than 127 moves can not be stored", t.Code)
+		}
+		for _, index := range buckets[plies] {
+			if status[index] != TB_UNKNOWN {
+				continue
+			}
+			won := plies%2 == 1
+			if won {
+				status[index] = TB_WIN
+				t.dtm[index] = int8((plies + 1) / 2)
+			} else {
+				status[index] = TB_LOSS
+				t.dtm[index] = int8(-plies/2 - 1)
+			}
+
+			previous = t.unMoves(index, previous[:0])
+			for _, before := range previous {
+				if status[before] != TB_UNKNOWN {
+					continue
+				}
+
+				// Moving to a lost position wins, and a position is lost once every move reaches a won position
+				if !won {
+					schedule(before, plies+1)
+					continue
+				}
+				remaining[before]--
+				lossPlies[before] = max(lossPlies[before], uint8(plies+1))
+				if remaining[before] == 0 && !hasWin[before] && !canDraw[before] {
+					schedule(before, int(lossPlies[before]))
+				}
+			}
+		}
+		buckets[plies] = nil
+	}
+
+	// Everything else can not be forced, so is a draw (the dtm is already 0)
+	return nil
+}
+
+// Probe the tablebases for the board, from the perspective of the side to move
+// The result is 1 for a win, 0 for a draw and -1 for a loss, with the plies to mate
+// Positions that can not be won by either side are draws without a tablebase
+func (b *Board) probeTablebase() (result int, plies int, ok bool) {
+	if bits.OnesCount64(uint64(b.Occupancy[EITHER_COLOR])) > TABLEBASE_MAX_PIECES {
+		return 0, 0, false
+	}
+	if b.isInsufficientMaterial() {
+		return 0, 0, true
+	}
+
+	entry, found := TABLEBASES[b.materialSignature()]
+	if !found {
+		return 0, 0, false
+	}
+	t := entry.tablebase
+	dtm := t.dtm[t.encode(t.boardSquares(b, entry.flip))]
+	switch {
+	case dtm > 0:
+		return 1, 2*int(dtm) - 1, true
+	case dtm < 0:
+		return -1, 2 * (-int(dtm) - 1), true
+	}
+	return 0, 0, true
+}
+
+// Get the evaluation of the board from the tablebases for the search, as a mate score from the side to move's perspective
+func (b *Board) tablebaseEval(ply uint8) (Eval, bool) {
+	if len(TABLEBASES) == 0 {
+		return 0, false
+	}
+
+	result, plies, ok := b.probeTablebase()
+	if !ok {
+		return 0, false
+	}
+
+	// Match the mate scores of the search, which are MIN_EVAL plus the ply of the mated position
+	switch result {
+	case 1:
+		return MAX_EVAL - Eval(int(ply)+plies), true
+	case -1:
+		return MIN_EVAL + Eval(int(ply)+plies), true
+	}
+	return 0, true
+}
+
+// Add a tablebase to the ones probed
+func registerTablebase(t *Tablebase) {
+	TABLEBASES[signatureOf(t.Code, BLACK)] = tablebaseEntry{t, true}
+	TABLEBASES[signatureOf(t.Code, WHITE)] = tablebaseEntry{t, false}
+}
+
+// Get the endgames a tablebase captures into that need their own tablebase
+func (t *Tablebase) captureCodes() []string {
+	var codes []string
+	for i, p := range t.pieces {
+		if p.piece == KING {
+			continue
+		}
+
+		// Build the code of the pieces left after this one is captured
+		var sides [NUM_COLORS]string
+		for j, other := range t.pieces {
+			if j != i {
+				sides[other.color] += other.piece.toString(WHITE)
+			}
+		}
+		code := sides[WHITE] + sides[BLACK]
+
+		// A lone minor piece can never mate, so does not need a tablebase
+		if len(code) == 2 || (len(code) == 3 && (strings.Contains(code, "B") || strings.Contains(code, "N"))) {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// GenerateTablebase generates the tablebase of an endgame, like "KQKR", and the tablebases it captures into
+// Tablebases that are already loaded are not generated again, generated ones are saved into dir when it is not empty
+// The engine must be initialized first
+func GenerateTablebase(code string, dir string) error {
+	t, err := newTablebase(code)
+	if err != nil {
+		return err
+	}
+	if entry, ok := TABLEBASES[signatureOf(t.Code, WHITE)]; ok && entry.tablebase.Code == t.Code {
+		return nil
+	}
+
+	for _, capture := range t.captureCodes() {
+		if err := GenerateTablebase(capture, dir); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	if err := t.generate(); err != nil {
+		return err
+	}
+	registerTablebase(t)
+	fmt.Printf("Generated %v (%d positions) in %v\n", t.Code, t.size(), time.Since(start).Round(time.Millisecond))
+
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return t.Save(filepath.Join(dir, t.Code+TABLEBASE_EXTENSION))
+}
+
+// Save the tablebase, the header is the magic, the version, the code and the number of positions, followed by the dtm of each
+func (t *Tablebase) Save(path string) error {
+	data := make([]byte, 0, len(TABLEBASE_MAGIC)+2+len(t.Code)+4+len(t.dtm))
+	data = append(data, TABLEBASE_MAGIC...)
+	data = append(data, TABLEBASE_VERSION, byte(len(t.Code)))
+	data = append(data, t.Code...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(t.dtm)))
+	for _, dtm := range t.dtm {
+		data = append(data, byte(dtm))
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadTablebase loads a tablebase saved by the engine
+func LoadTablebase(path string) (*Tablebase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(TABLEBASE_MAGIC) + 2
+	if len(data) < header || string(data[:len(TABLEBASE_MAGIC)]) != TABLEBASE_MAGIC {
+		return nil, fmt.Errorf("Invalid tablebase file %v; Not a tablebase generated by the engine", path)
+	}
+	if version := data[len(TABLEBASE_MAGIC)]; version != TABLEBASE_VERSION {
+		return nil, fmt.Errorf("Invalid tablebase file %v; Version %d is not supported", path, version)
+	}
+	codeLength := int(data[len(TABLEBASE_MAGIC)+1])
+	if len(data) < header+codeLength+4 {
+		return nil, fmt.Errorf("Invalid tablebase file %v; The header is cut short", path)
+	}
+
+	code := string(data[header : header+codeLength])
+	t, err := newTablebase(code)
+	if err != nil {
+		return nil, err
+	}
+	if t.Code != code {
+		return nil, fmt.Errorf("Invalid tablebase file %v; The code %v should be %v", path, code, t.Code)
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[header+codeLength:]))
+	positions := data[header+codeLength+4:]
+	if size != t.size() || len(positions) != size {
+		return nil, fmt.Errorf("Invalid tablebase file %v; Should be %d positions (found %d)", path, t.size(), len(positions))
+	}
+	t.dtm = make([]int8, size)
+	for i, dtm := range positions {
+		t.dtm[i] = int8(dtm)
+	}
+	return t, nil
+}
+
+// LoadTablebases loads every tablebase in a directory to be probed by the search, returning how many were loaded
+func LoadTablebases(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+TABLEBASE_EXTENSION))
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		t, err := LoadTablebase(path)
+		if err != nil {
+			return 0, err
+		}
+		registerTablebase(t)
+	}
+	return len(paths), nil
+}
+
+// Load the tablebases of TABLEBASE_DIR, called at engine startup
+func initTablebases() {
+	if TABLEBASE_DIR == "" {
+		return
+	}
+	if _, err := LoadTablebases(TABLEBASE_DIR); err != nil {
+		fmt.Printf("Failed to load the tablebases: %v\n", err)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTablebase(t *testing.T) {
+	InitEngine()
+
+	// Tests setup to be run, with the expected code after ordering, or an error
+	tests := []struct {
+		code     string
+		expected string
+		err      bool
+	}{
+		{code: "KQK", expected: "KQK"},
+		{code: "krkq", expected: "KQKR"},
+		{code: "KNKB", expected: "KBKN"},
+		{code: "KNBK", expected: "KBNK"},
+		{code: "KPK", err: true},
+		{code: "KQRKR", err: true},
+		{code: "KQ", err: true},
+		{code: "KXK", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.code, func(t *testing.T) {
+			tablebase, err := newTablebase(tc.code)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", tablebase.Code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tablebase.Code != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, tablebase.Code)
+			}
+		})
+	}
+}
+
+func TestGenerateTablebase(t *testing.T) {
+	InitEngine()
+	defer func() { TABLEBASES = map[materialSignature]tablebaseEntry{} }()
+
+	dir := t.TempDir()
+	for _, code := range []string{"KQK", "KRK"} {
+		if err := GenerateTablebase(code, dir); err != nil {
+			t.Fatalf("Failed to generate %v: %v", code, err)
+		}
+	}
+
+	// The longest mates are well known, 10 moves for KQK and 16 for KRK
+	for code, longest := range map[string]int8{"KQK": 10, "KRK": 16} {
+		entry := TABLEBASES[signatureOf(code, WHITE)]
+		got := int8(0)
+		for _, dtm := range entry.tablebase.dtm {
+			got = max(got, dtm)
+		}
+		if got != longest {
+			t.Errorf("%v: expected the longest mate to be %d moves, got %d", code, longest, got)
+		}
+	}
+
+	// Tests setup to be run, with the expected result and plies to mate for the side to move
+	tests := []struct {
+		name     string
+		position FEN
+		result   int
+		plies    int
+	}{
+		{
+			name:     "Mate in one",
+			position: "k7/8/1K6/8/8/8/7Q/8 w - - 0 1",
+			result:   1,
+			plies:    1,
+		},
+		{
+			name:     "Checkmated",
+			position: "k6Q/8/1K6/8/8/8/8/8 b - - 0 1",
+			result:   -1,
+			plies:    0,
+		},
+		{
+			name:     "Black rook mates in one",
+			position: "7r/8/8/8/8/1k6/8/K7 b - - 0 1",
+			result:   1,
+			plies:    1,
+		},
+		{
+			name:     "Stalemate",
+			position: "k7/2Q5/1K6/8/8/8/8/8 b - - 0 1",
+			result:   0,
+			plies:    0,
+		},
+		{
+			name:     "Hanging queen",
+			position: "8/8/8/8/8/8/1kQ5/7K b - - 0 1",
+			result:   0,
+			plies:    0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			board, err := tc.position.toBoard(nil)
+			if err != nil {
+				t.Fatalf("Invalid FEN: %v", err)
+			}
+			result, plies, ok := board.probeTablebase()
+			if !ok {
+				t.Fatalf("Expected the position to be in a tablebase")
+			}
+			if result != tc.result || plies != tc.plies {
+				t.Errorf("Expected result %d in %d plies, got %d in %d plies", tc.result, tc.plies, result, plies)
+			}
+		})
+	}
+
+	// The saved tablebases load back the same
+	loaded, err := LoadTablebase(filepath.Join(dir, "KRK"+TABLEBASE_EXTENSION))
+	if err != nil {
+		t.Fatalf("Failed to load KRK: %v", err)
+	}
+	generated := TABLEBASES[signatureOf("KRK", WHITE)].tablebase
+	if loaded.Code != generated.Code || string(int8Bytes(loaded.dtm)) != string(int8Bytes(generated.dtm)) {
+		t.Errorf("Loaded tablebase does not match the generated one")
+	}
+}
+
+func int8Bytes(values []int8) []byte {
+	data := make([]byte, len(values))
+	for i, v := range values {
+		data[i] = byte(v)
+	}
+	return data
+}
@@ -9,13 +9,14 @@ On the cloud server, memory will be highly constrained so they should be easily
 TODO: Redo the locking on the TT tables to use atomic reads/writes and be non-locking, once on production
 */
 
-// TT entry definition (currently 128 bits)
+// TT entry definition (currently 64 bits)
+// The index of an entry is the lower bits of the zobrist hash, so only the upper 16 bits are kept to check it is the same position
 type TTEntry struct {
-	move    Move
-	eval    Eval
-	depth   uint8
-	flag    uint8
-	zobrist ZobristHash
+	key   uint16
+	move  Move
+	eval  Eval
+	depth uint8
+	flag  uint8
 }
 
 // TT flags
@@ -27,9 +28,12 @@ const (
 )
 
 // Size of the TT table
-// The memory will be TT_SIZE * sizeof(TTEntry)
-// With 256MB of memory, the size of the TT table can be ~16,777,216 entries if the tt entry size is 128 btis
-const TT_SIZE = 1 << 20
+// The memory will be TT_SIZE * sizeof(TTEntry), 16MB
+// With 256MB of memory, the size of the TT table can be ~33,554,432 entries if the tt entry size is 64 bits
+const TT_SIZE = 1 << 21
+
+// The shift of the zobrist hash to get the key of its TT entry
+const TT_KEY_SHIFT = 48
 
 // Use a slice instead of a fixed-size array
 var TT []TTEntry
@@ -53,12 +57,22 @@ func updateTT(zobrist ZobristHash, eval Eval, flag, depth uint8, move Move) {
 	// Get the TT entry
 	entry := &TT[key]
 
-	// Write everything except zobrist
+	// Write everything except the key
 	entry.eval = eval
 	entry.depth = depth
 	entry.flag = flag
 	entry.move = move
 
-	// Write the Zobrist update last
-	entry.zobrist = zobrist
+	// Write the key update last
+	entry.key = uint16(zobrist >> TT_KEY_SHIFT)
+}
+
+// Get the TT entry of a position, nil if the TT does not hold it
+// Entries never written have no flag, so they are not mistaken for a position with a key of 0
+func probeTT(zobrist ZobristHash) *TTEntry {
+	entry := &TT[zobrist&(TT_SIZE-1)]
+	if entry.flag == TT_LOCKED || entry.key != uint16(zobrist>>TT_KEY_SHIFT) {
+		return nil
+	}
+	return entry
 }
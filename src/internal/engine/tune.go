@@ -0,0 +1,371 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+This file holds the Texel tuner for the evaluation parameters.
+The tuner reads quiet positions labelled with the result of the game they came from, and minimizes the
+error between the result and a sigmoid of the static evaluation with gradient descent (Adam).
+Every evaluation term is linear in its weights, so each position is traced once and the tuner works on the traces.
+*/
+
+// Options for a tuning run
+type TuneOptions struct {
+	// File of labelled positions, one FEN and game result per line
+	PositionsPath string
+
+	// File the tuned parameters are written to
+	OutPath string
+
+	// Number of passes of gradient descent over all the positions
+	Epochs int
+
+	// The Adam learning rate, in centipawns
+	LearningRate float64
+
+	// Scaling of the sigmoid, fitted to the positions when 0
+	K float64
+}
+
+// Default tuning options
+var DEFAULT_TUNE_OPTIONS = TuneOptions{
+	OutPath:      "params.json",
+	Epochs:       500,
+	LearningRate: 1,
+}
+
+// A labelled position, stored as the weights its evaluation used
+type tuningPosition struct {
+	// Result of the game from white's perspective (1 win, 0.5 draw, 0 loss)
+	result float64
+
+	// Phase score of the position
+	phase int
+
+	// Index of each weight used into the parameter vector, and how many more times white used it than black
+	indexes []int
+	coefs   []float64
+}
+
+// Evaluate a traced position with the parameter vector, split into opening and endgame values
+func (p *tuningPosition) eval(opening, endgame []float64) float64 {
+	o, e := 0.0, 0.0
+	for i, index := range p.indexes {
+		o += p.coefs[i] * opening[index]
+		e += p.coefs[i] * endgame[index]
+	}
+	return (o*float64(256-p.phase) + e*float64(p.phase)) / 256
+}
+
+// Convert an evaluation into an expected score, K scales the curve
+func sigmoid(eval, k float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*eval/400))
+}
+
+// Parse the result of a labelled position
+// Accepts the common formats, [1.0] / [0.5] / [0.0], "1-0" / "1/2-1/2" / "0-1", or a bare number
+func parseTuningResult(s string) (float64, error) {
+	s = strings.Trim(strings.TrimSpace(s), "[]\";")
+	switch s {
+	case "1-0":
+		return 1, nil
+	case "0-1":
+		return 0, nil
+	case "1/2-1/2":
+		return 0.5, nil
+	}
+
+	result, err := strconv.ParseFloat(s, 64)
+	if err != nil || (result != 0 && result != 0.5 && result != 1) {
+		return 0, fmt.Errorf("Invalid result %q; Should be 1-0, 0-1, 1/2-1/2, 1.0, 0.5 or 0.0", s)
+	}
+	return result, nil
+}
+
+// Split a labelled position line into its FEN and result
+// The result is either the last field of the line, or an EPD c9 operation
+func parseTuningLine(line string) (FEN, float64, error) {
+	var fields []string
+	var resultField string
+	if before, after, found := strings.Cut(line, "c9 "); found {
+		fields = strings.Fields(before)
+		resultField = after
+	} else {
+		fields = strings.Fields(line)
+		if len(fields) > 0 {
+			resultField = fields[len(fields)-1]
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	if len(fields) < 4 {
+		return "", 0, fmt.Errorf("Invalid position %q; Should be a FEN followed by the result", line)
+	}
+	result, err := parseTuningResult(resultField)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// EPD positions only have 4 fields, so add the move counters
+	if len(fields) < 6 || !isNumber(fields[4]) || !isNumber(fields[5]) {
+		fields = append(fields[:4], "0", "1")
+	}
+	return FEN(strings.Join(fields[:6], " ")), result, nil
+}
+
+// Trace the evaluation of a board, and store it against the parameter vector
+func newTuningPosition(board *Board, result float64, index map[*Weight]int) tuningPosition {
+	// Scaling the eval scales every weight it used, so the scale is folded into the coefficients
+	trace := evalTrace{}
+	scale := float64(board.endgameScale(board.evaluateTerms(trace))) / SCALE_NORMAL
+
+	position := tuningPosition{
+		result:  result,
+		phase:   board.getPhaseScore(),
+		indexes: make([]int, 0, len(trace)),
+		coefs:   make([]float64, 0, len(trace)),
+	}
+	for weight, counts := range trace {
+		coef := counts[WHITE] - counts[BLACK]
+		if coef == 0 {
+			continue
+		}
+		position.indexes = append(position.indexes, index[weight])
+		position.coefs = append(position.coefs, float64(coef)*scale)
+	}
+	return position
+}
+
+// Load the labelled positions from a file, skipping positions that are not quiet
+func loadTuningPositions(path string, index map[*Weight]int) ([]tuningPosition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	positions := make([]tuningPosition, 0, 100000)
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fen, result, err := parseTuningLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		board, err := fen.toBoard(nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		// The static eval means nothing with the side to move in check, and known endgames do not use the weights
+		if board.isInCheck(board.Turn) {
+			skipped++
+			continue
+		}
+		if _, ok := board.endgameEval(); ok {
+			skipped++
+			continue
+		}
+		positions = append(positions, newTuningPosition(board, result, index))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("No positions found in %v", path)
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d positions in check or in known endgames\n", skipped)
+	}
+	return positions, nil
+}
+
+// Mean squared error of the predicted scores against the game results
+func tuningError(positions []tuningPosition, opening, endgame []float64, k float64) float64 {
+	total := 0.0
+	for i := range positions {
+		diff := positions[i].result - sigmoid(positions[i].eval(opening, endgame), k)
+		total += diff * diff
+	}
+	return total / float64(len(positions))
+}
+
+// Find the K that best fits the current evaluation to the results, with a golden section search
+func fitK(positions []tuningPosition, opening, endgame []float64) float64 {
+	ratio := (math.Sqrt(5) - 1) / 2
+	low, high := 0.05, 5.0
+	for high-low > 0.001 {
+		k1 := high - ratio*(high-low)
+		k2 := low + ratio*(high-low)
+		if tuningError(positions, opening, endgame, k1) < tuningError(positions, opening, endgame, k2) {
+			high = k2
+		} else {
+			low = k1
+		}
+	}
+	return (low + high) / 2
+}
+
+// Gradient of the error with respect to every opening and endgame weight
+// The positions are split between the cores, each summing into its own gradient
+func tuningGradient(positions []tuningPosition, opening, endgame []float64, k float64) ([]float64, []float64) {
+	workers := runtime.NumCPU()
+	chunk := (len(positions) + workers - 1) / workers
+	openingGrads := make([][]float64, workers)
+	endgameGrads := make([][]float64, workers)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		openingGrads[w] = make([]float64, len(opening))
+		endgameGrads[w] = make([]float64, len(endgame))
+		start := min(w*chunk, len(positions))
+		end := min(start+chunk, len(positions))
+
+		wg.Add(1)
+		go func(part []tuningPosition, openingGrad, endgameGrad []float64) {
+			defer wg.Done()
+			for i := range part {
+				p := &part[i]
+				s := sigmoid(p.eval(opening, endgame), k)
+
+				// Derivative of (result - s)^2 with respect to the eval
+				g := 2 * (s - p.result) * s * (1 - s) * math.Ln10 * k / 400
+				openingScale := g * float64(256-p.phase) / 256
+				endgameScale := g * float64(p.phase) / 256
+				for j, index := range p.indexes {
+					openingGrad[index] += openingScale * p.coefs[j]
+					endgameGrad[index] += endgameScale * p.coefs[j]
+				}
+			}
+		}(positions[start:end], openingGrads[w], endgameGrads[w])
+	}
+	wg.Wait()
+
+	// Combine the gradients of each worker, averaged over the positions
+	for w := 1; w < workers; w++ {
+		for i := range opening {
+			openingGrads[0][i] += openingGrads[w][i]
+			endgameGrads[0][i] += endgameGrads[w][i]
+		}
+	}
+	for i := range opening {
+		openingGrads[0][i] /= float64(len(positions))
+		endgameGrads[0][i] /= float64(len(positions))
+	}
+	return openingGrads[0], endgameGrads[0]
+}
+
+// Adam optimizer state for one half (opening or endgame) of the parameter vector
+type adam struct {
+	m []float64
+	v []float64
+}
+
+// Adam constants
+const (
+	ADAM_BETA1   = 0.9
+	ADAM_BETA2   = 0.999
+	ADAM_EPSILON = 1e-8
+)
+
+func newAdam(size int) *adam {
+	return &adam{m: make([]float64, size), v: make([]float64, size)}
+}
+
+// Take one step against the gradient, step is the 1-based step number
+func (a *adam) step(params, grad []float64, learningRate float64, step int) {
+	correction1 := 1 - math.Pow(ADAM_BETA1, float64(step))
+	correction2 := 1 - math.Pow(ADAM_BETA2, float64(step))
+	for i := range params {
+		a.m[i] = ADAM_BETA1*a.m[i] + (1-ADAM_BETA1)*grad[i]
+		a.v[i] = ADAM_BETA2*a.v[i] + (1-ADAM_BETA2)*grad[i]*grad[i]
+		mHat := a.m[i] / correction1
+		vHat := a.v[i] / correction2
+		params[i] -= learningRate * mHat / (math.Sqrt(vHat) + ADAM_EPSILON)
+	}
+}
+
+// Tune runs the Texel tuner, starting from the current evaluation parameters
+// The tuned parameters are written to the output file and returned
+func Tune(options TuneOptions) (*EvalParams, error) {
+	// Init the engine
+	InitEngine()
+
+	if options.OutPath == "" {
+		options.OutPath = DEFAULT_TUNE_OPTIONS.OutPath
+	}
+	if options.Epochs <= 0 {
+		options.Epochs = DEFAULT_TUNE_OPTIONS.Epochs
+	}
+	if options.LearningRate <= 0 {
+		options.LearningRate = DEFAULT_TUNE_OPTIONS.LearningRate
+	}
+
+	// The traces point at the weights of the parameters the engine evaluates with
+	weights := EVAL_PARAMS.weights()
+	index := make(map[*Weight]int, len(weights))
+	opening := make([]float64, len(weights))
+	endgame := make([]float64, len(weights))
+	for i, w := range weights {
+		index[w.weight] = i
+		opening[i] = float64(w.weight.Opening)
+		endgame[i] = float64(w.weight.Endgame)
+	}
+
+	fmt.Println("Starting the tuner.")
+	positions, err := loadTuningPositions(options.PositionsPath, index)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Loaded %d positions, tuning %d weights\n", len(positions), 2*len(weights))
+
+	k := options.K
+	if k <= 0 {
+		k = fitK(positions, opening, endgame)
+	}
+	startError := tuningError(positions, opening, endgame, k)
+	fmt.Printf("K: %.4f, starting error: %.6f\n\n", k, startError)
+
+	openingAdam := newAdam(len(weights))
+	endgameAdam := newAdam(len(weights))
+	for epoch := 1; epoch <= options.Epochs; epoch++ {
+		openingGrad, endgameGrad := tuningGradient(positions, opening, endgame, k)
+		openingAdam.step(opening, openingGrad, options.LearningRate, epoch)
+		endgameAdam.step(endgame, endgameGrad, options.LearningRate, epoch)
+
+		if epoch%50 == 0 || epoch == options.Epochs {
+			fmt.Printf("Epoch %d: error %.6f\n", epoch, tuningError(positions, opening, endgame, k))
+		}
+	}
+
+	// Round the tuned weights into a copy of the parameters
+	tuned := EVAL_PARAMS
+	for i, w := range tuned.weights() {
+		w.weight.Opening = Eval(math.Round(opening[i]))
+		w.weight.Endgame = Eval(math.Round(endgame[i]))
+	}
+	tuned.build()
+
+	fmt.Printf("\nFinal error: %.6f (started at %.6f)\n", tuningError(positions, opening, endgame, k), startError)
+	if err := SaveEvalParams(options.OutPath, &tuned); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Saved the tuned parameters to %v\n", options.OutPath)
+
+	return &tuned, nil
+}
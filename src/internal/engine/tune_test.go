@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTuningLine(t *testing.T) {
+	// Tests setup to be run
+	tests := []struct {
+		name   string
+		line   string
+		fen    FEN
+		result float64
+		err    bool
+	}{
+		{
+			name:   "FEN with bracketed result",
+			line:   "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1 [0.5]",
+			fen:    "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			result: 0.5,
+		},
+		{
+			name:   "EPD with c9 result",
+			line:   "8/8/4k3/8/8/3QK3/8/8 w - - c9 \"1-0\";",
+			fen:    "8/8/4k3/8/8/3QK3/8/8 w - - 0 1",
+			result: 1,
+		},
+		{
+			name:   "FEN with PGN result",
+			line:   "8/8/4k3/8/8/3qK3/8/8 w - - 12 60 0-1",
+			fen:    "8/8/4k3/8/8/3qK3/8/8 w - - 12 60",
+			result: 0,
+		},
+		{
+			name: "Invalid result",
+			line: "8/8/4k3/8/8/3qK3/8/8 w - - 12 60 0.7",
+			err:  true,
+		},
+		{
+			name: "Missing result",
+			line: "8/8/4k3/8/8/3qK3/8/8 w -",
+			err:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fen, result, err := parseTuningLine(tc.line)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Expected an error, got %q %v", fen, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if fen != tc.fen || result != tc.result {
+				t.Errorf("Expected %q %v, got %q %v", tc.fen, tc.result, fen, result)
+			}
+		})
+	}
+}
+
+func TestTuningTraceMatchesEval(t *testing.T) {
+	InitEngine()
+
+	// The tuner evaluates from the trace, so it must agree with the real evaluation
+	weights := EVAL_PARAMS.weights()
+	index := make(map[*Weight]int, len(weights))
+	opening := make([]float64, len(weights))
+	endgame := make([]float64, len(weights))
+	for i, w := range weights {
+		index[w.weight] = i
+		opening[i] = float64(w.weight.Opening)
+		endgame[i] = float64(w.weight.Endgame)
+	}
+
+	fens := []FEN{
+		STARTING_POSITION_FEN,
+		"r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 9",
+		"8/5pk1/6p1/3R4/1p6/1r4P1/5PK1/8 b - - 3 41",
+		"8/5k2/8/3b1p2/5P2/4PK2/8/2B5 w - - 0 1",
+		"4k3/1P6/8/8/8/8/6p1/4K3 w - - 0 1",
+	}
+	for _, fen := range fens {
+		board, err := fen.toBoard(nil)
+		if err != nil {
+			t.Fatalf("Invalid FEN %v: %v", fen, err)
+		}
+
+		position := newTuningPosition(board, 0.5, index)
+		traced := position.eval(opening, endgame)
+		eval := float64(board.eval())
+
+		// The real eval rounds each term separately, so allow a little rounding error
+		if math.Abs(traced-eval) > 5 {
+			t.Errorf("%v: traced eval %.2f, expected %.2f", fen, traced, eval)
+		}
+	}
+}
@@ -23,15 +23,25 @@ type Color uint8
 // Aliasing Eval to int16 for better type safety
 type Eval int16
 
-// Definging the move structure
-// Code will be a variety of things (is a caputure, white castle kingside, etc)
-// promotion holds the piece to promote too
-type Move struct {
-	start     Square
-	target    Square
-	promotion Piece
-	code      uint8
-}
+// Defining the move, packed into 16 bits so it is cheap to copy and store in the TT
+// Bits 0-5 are the start square, bits 6-11 the target square and bits 12-15 the flags
+// The flags are the move code (see MOVE_CODE_NONE), or for promotions 8 + the piece promoted to (knight to queen as 0 to 3),
+// plus 4 if the promotion captures
+// The zero move (a1 to a1) is never a real move, and is used as no move
+type Move uint16
+
+// No move, ex. an empty TT entry or killer slot
+const NO_MOVE Move = 0
+
+// The masks and shifts of the parts of a move
+const (
+	MOVE_SQUARE_MASK     = 0x3F
+	MOVE_TARGET_SHIFT    = 6
+	MOVE_FLAGS_SHIFT     = 12
+	MOVE_FROM_TO_MASK    = 0xFFF
+	MOVE_PROMOTION_FLAG  = 8
+	MOVE_PROMOTION_TAKES = 4
+)
 
 // This structure is used to unmake moves in place on a board, after making a move
 type MoveUndo struct {
@@ -43,6 +53,7 @@ type MoveUndo struct {
 	eps         Square
 	start       Square
 	target      Square
+	evalState   EvalState
 }
 
 // Move code definitions
@@ -64,7 +75,8 @@ type MoveEval struct {
 type Killers [MAX_PLY][2]Move
 
 // Aliasing cutoff history hueristic for better type safety
-type CutoffHeuristic [NUM_COLORS][NUM_SQUARES][NUM_SQUARES]int
+// Indexed by the side to move and the start and target squares of the move (see Move.fromTo)
+type CutoffHeuristic [NUM_COLORS][NUM_SQUARES * NUM_SQUARES]int
 
 // Defining mins and maxes for the eval type, this is close to max for 16-bit int but not there (to avoid overflow issues)
 const (
@@ -79,6 +91,14 @@ const MAX_PLY = 64
 // This comes from lichess official study that it is 218, but setting to 256 is fine
 const MAX_NUMBER_OF_MOVES_IN_A_POSITION = 256
 
+// A list of moves, filled by the move generation, with the scores they are ordered by in the search
+// This is allocated once per ply of a search and reused, the moves past count are left over from before
+type MoveList struct {
+	moves  [MAX_NUMBER_OF_MOVES_IN_A_POSITION]Move
+	scores [MAX_NUMBER_OF_MOVES_IN_A_POSITION]int32
+	count  int
+}
+
 // Defining a delta margin to use in Delta Pruning in the Quiescence search
 // This is in centipawns
 const DELTA_MARGIN = 150
@@ -291,4 +311,12 @@ type Board struct {
 	// This stores the square of the king for both sides
 	// Keep this updated, makes finding the king more efficient during move generation
 	KingSquare [NUM_COLORS]Square
+
+	// The parts of the evaluation that are updated as moves are made, instead of recomputed at every evaluation
+	// Keep this updated in makeMove, it is restored from the MoveUndo in unMakeMove
+	EvalState EvalState
+
+	// The evaluation parameters of the search on the board (EVAL_PARAMS when nil), and their key in the pawn hash
+	params    *EvalParams
+	paramsKey ZobristHash
 }
@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+This file holds the UCI front-end, so the engine can be played by chess GUIs and by other engines in matches.
+Commands are read one per line and answered on the output, the protocol is described at https://www.wbec-ridderkerk.nl/html/UCIProtocol.html
+Only the commands and options the engine supports are handled, the others are ignored as the protocol asks.
+Like Evalute, the go command plays from the opening book while the position is in it (see BOOK_PATH).
+Commands are handled one at a time, so a search is not stopped by stop, except the ponder search of go ponder,
+which runs in the background until ponderhit makes it the real search or stop throws it away.
+*/
+
+// The name the engine gives itself over UCI
+const UCI_NAME = "Zugzwang"
+
+// The state of a UCI session, the position to search and how to search it
+type uciSession struct {
+	out      io.Writer
+	position FEN
+	history  []FEN
+	options  SearchOptions
+
+	// The last move of the position, the reply go ponder ponders on
+	lastMove string
+
+	// Whether the engine plays from its book, the OwnBook option
+	ownBook bool
+
+	// Whether the GUI lets the engine ponder, the Ponder option, the best move then has the reply to ponder on
+	ponder bool
+
+	// The search of go ponder is running in the background, with the options of the go command
+	pondering     bool
+	ponderOptions SearchOptions
+}
+
+/*
+RunUCI reads UCI commands from in and writes the engine's answers to out, until the quit command or the end of the input.
+The engine must be initialized first, the book of BOOK_PATH is played from unless the OwnBook option turns it off.
+*/
+func RunUCI(in io.Reader, out io.Writer) error {
+	session := &uciSession{
+		out:      out,
+		position: STARTING_POSITION_FEN,
+		options:  DEFAULT_SEARCH_OPTIONS,
+		ownBook:  BOOK != nil,
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			session.uci()
+		case "isready":
+			fmt.Fprintln(out, "readyok")
+		case "ucinewgame":
+			session.position, session.history = STARTING_POSITION_FEN, nil
+		case "setoption":
+			session.setOption(fields[1:])
+		case "position":
+			session.setPosition(fields[1:])
+		case "go":
+			session.goSearch(fields[1:])
+		case "ponderhit":
+			session.ponderHit()
+		case "stop":
+			session.stop()
+		case "quit":
+			StopPonder()
+			return nil
+		}
+	}
+	StopPonder()
+	return scanner.Err()
+}
+
+// Identify the engine and list its options
+func (s *uciSession) uci() {
+	fmt.Fprintf(s.out, "id name %v\n", UCI_NAME)
+	fmt.Fprintln(s.out, "id author Zugzwang")
+	fmt.Fprintf(s.out, "option name OwnBook type check default %v\n", s.ownBook)
+	fmt.Fprintf(s.out, "option name BookFile type string default %v\n", uciString(BOOK_PATH))
+	fmt.Fprintf(s.out, "option name BestBookMove type check default %v\n", BOOK_BEST_ONLY)
+	fmt.Fprintf(s.out, "option name Ponder type check default %v\n", s.ponder)
+	fmt.Fprintln(s.out, "uciok")
+}
+
+// The empty string is written as <empty> in UCI
+func uciString(value string) string {
+	if value == "" {
+		return "<empty>"
+	}
+	return value
+}
+
+// Set an option, from "name <name> value <value>"
+func (s *uciSession) setOption(fields []string) {
+	line := strings.Join(fields, " ")
+	name, value, _ := strings.Cut(strings.TrimPrefix(line, "name "), " value ")
+	value = strings.TrimSpace(value)
+	if value == "<empty>" {
+		value = ""
+	}
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ownbook":
+		s.ownBook = value == "true"
+		s.loadBook()
+	case "bookfile":
+		BOOK_PATH = value
+		s.loadBook()
+	case "bestbookmove":
+		BOOK_BEST_ONLY = value == "true"
+	case "ponder":
+		s.ponder = value == "true"
+	default:
+		fmt.Fprintf(s.out, "info string Invalid option %q; Should be OwnBook, BookFile, BestBookMove or Ponder\n", name)
+	}
+}
+
+// Load the book of BOOK_PATH if the engine plays from its book, else unload it
+func (s *uciSession) loadBook() {
+	if !s.ownBook {
+		BOOK = nil
+		return
+	}
+	initBook()
+}
+
+// Set the position, from "startpos" or "fen <fen>" and then "moves <moves>"
+func (s *uciSession) setPosition(fields []string) {
+	var position FEN
+	switch {
+	case len(fields) > 0 && fields[0] == "startpos":
+		position, fields = STARTING_POSITION_FEN, fields[1:]
+	case len(fields) > 0 && fields[0] == "fen":
+		end := 1
+		for end < len(fields) && fields[end] != "moves" {
+			end++
+		}
+		position, fields = FEN(strings.Join(fields[1:end], " ")), fields[end:]
+	default:
+		fmt.Fprintln(s.out, "info string Invalid position; Should be startpos or fen followed by the moves")
+		return
+	}
+
+	var history []FEN
+	var lastMove string
+	if len(fields) > 0 && fields[0] == "moves" {
+		for _, move := range fields[1:] {
+			next, err := PlayMove(position, move)
+			if err != nil {
+				fmt.Fprintf(s.out, "info string Invalid move %v; %v\n", move, err)
+				return
+			}
+			history = append(history, position)
+			position, lastMove = next, move
+		}
+	}
+	s.position, s.history, s.lastMove = position, history, lastMove
+}
+
+// Search the position and answer with the best move, from "depth <plies>", "movetime <ms>" and "nodes <nodes>"
+// Other limits, like the clock, are not supported and the position is searched with the default options
+// With "ponder" the position after the last move is searched in the background, there is no answer until ponderhit or stop
+func (s *uciSession) goSearch(fields []string) {
+	s.pondering = false
+	options := s.options
+	for i := 0; i+1 < len(fields); i++ {
+		value, err := strconv.Atoi(fields[i+1])
+		if err != nil || value < 0 {
+			continue
+		}
+		switch fields[i] {
+		case "depth":
+			options.Depth = uint8(min(max(value, 1), 10))
+		case "movetime":
+			options.MoveTime = time.Duration(value) * time.Millisecond
+		case "nodes":
+			options.Nodes = value
+		default:
+			continue
+		}
+		i++
+	}
+
+	// The last move is the reply the GUI expects, the engine ponders on it from the position after the engine's move
+	if slices.Contains(fields, "ponder") && s.lastMove != "" {
+		err := PonderOn(s.history[len(s.history)-1], s.history[:len(s.history)-1], s.lastMove, options)
+		if err == nil {
+			s.pondering, s.ponderOptions = true, options
+			return
+		}
+		fmt.Fprintf(s.out, "info string %v\n", err)
+	}
+
+	s.search(options)
+}
+
+// Search the position with the options and answer with the best move
+func (s *uciSession) search(options SearchOptions) {
+	response, err := Evalute(s.position, s.history, 1, options)
+	if err != nil {
+		fmt.Fprintf(s.out, "info string %v\n", err)
+		fmt.Fprintln(s.out, "bestmove 0000")
+		return
+	}
+	s.writeBestMove(response, options)
+}
+
+// The GUI's reply was played, so the ponder search becomes the real search and is answered
+// If the ponder search has nothing to play, the position is searched like any other
+func (s *uciSession) ponderHit() {
+	if !s.pondering {
+		return
+	}
+	s.pondering = false
+
+	response, ok := PonderHit(s.position, 1)
+	if !ok {
+		s.search(s.ponderOptions)
+		return
+	}
+	s.writeBestMove(response, s.ponderOptions)
+}
+
+// The GUI's reply was not played, so the ponder search is stopped
+// The GUI throws the answer away, but still expects one, so the last depth the ponder search completed is answered
+func (s *uciSession) stop() {
+	if !s.pondering {
+		return
+	}
+	s.pondering = false
+
+	response, ok := PonderResults(1)
+	if !ok {
+		fmt.Fprintln(s.out, "bestmove 0000")
+		return
+	}
+	fmt.Fprintf(s.out, "bestmove %v\n", response.BestMove())
+}
+
+// Write the move of a response, "0000" when the position has no legal moves
+// When the GUI lets the engine ponder, the move is followed by the reply the engine expects
+func (s *uciSession) writeBestMove(response *EvaluateResponse, options SearchOptions) {
+	move := response.BestMove()
+	if move == "" {
+		fmt.Fprintln(s.out, "bestmove 0000")
+		return
+	}
+
+	if response.nodes == 0 {
+		fmt.Fprintln(s.out, "info string book move")
+	} else {
+		eval := response.MoveEvals[0].eval
+		fmt.Fprintf(s.out, "info nodes %d time %d score cp %d pv %v\n", response.nodes, response.duration.Milliseconds(), eval, move)
+	}
+
+	if s.ponder {
+		history := append(slices.Clone(s.history), s.position)
+		if after, err := PlayMove(s.position, move); err == nil {
+			if reply, ok := ExpectedReply(after, history, options); ok {
+				fmt.Fprintf(s.out, "bestmove %v ponder %v\n", move, reply)
+				return
+			}
+		}
+	}
+	fmt.Fprintf(s.out, "bestmove %v\n", move)
+}
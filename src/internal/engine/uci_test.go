@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUCI(t *testing.T) {
+	InitEngine()
+	defer func() { BOOK = nil; BOOK_PATH = ""; BOOK_BEST_ONLY = false }()
+
+	// A book with only d2d4 from the starting position, which the search would not play at depth 1
+	board, err := STARTING_POSITION_FEN.toBoard(nil)
+	if err != nil {
+		t.Fatalf("Failed to build the starting position: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := writeBook(path, []BookEntry{{Key: board.polyglotKey(), Move: uint16(D2)<<6 | uint16(D4), Weight: 1}}); err != nil {
+		t.Fatalf("Failed to write the book: %v", err)
+	}
+
+	// The book is loaded at startup from BOOK_PATH, as cmd/engine does with -playbook
+	BOOK_PATH = path
+	initBook()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+
+		// Whether the best move has the reply to ponder on
+		ponder bool
+	}{
+		{
+			name:     "handshake",
+			input:    "uci\nisready\n",
+			expected: []string{"id name " + UCI_NAME, "option name OwnBook type check default true", "option name BookFile type string default " + path, "uciok", "readyok"},
+		},
+		{
+			name:     "start position from the book",
+			input:    "position startpos\ngo depth 1\n",
+			expected: []string{"info string book move", "bestmove d2d4"},
+		},
+		{
+			name:     "out of the book is searched",
+			input:    "position startpos moves d2d4\ngo depth 1\n",
+			expected: []string{"info nodes", "bestmove "},
+		},
+		{
+			name:     "book turned off",
+			input:    "setoption name OwnBook value false\nposition startpos\ngo depth 1\nsetoption name OwnBook value true\n",
+			expected: []string{"info nodes", "bestmove "},
+		},
+		{
+			name:     "book set by the option",
+			input:    "setoption name BookFile value " + path + "\nposition fen " + string(STARTING_POSITION_FEN) + "\ngo depth 1\n",
+			expected: []string{"info string book move", "bestmove d2d4"},
+		},
+		{
+			name:     "ponder hit",
+			input:    "setoption name Ponder value true\nposition startpos moves e2e4 e7e5\ngo ponder depth 3\nponderhit\nsetoption name Ponder value false\n",
+			expected: []string{"info nodes", "bestmove "},
+		},
+		{
+			name:     "ponder stopped",
+			input:    "position startpos moves e2e4 e7e5\ngo ponder depth 3\nstop\nposition startpos moves e2e4 d7d5\ngo depth 2\n",
+			expected: []string{"bestmove ", "info nodes", "bestmove "},
+		},
+		{
+			name:     "best move with the reply to ponder on",
+			input:    "setoption name Ponder value true\nposition startpos moves e2e4\ngo depth 2\nsetoption name Ponder value false\n",
+			expected: []string{"info nodes", "bestmove "},
+			ponder:   true,
+		},
+		{
+			name:     "mated",
+			input:    "position startpos moves f2f3 e7e5 g2g4 d8h4\ngo depth 1\n",
+			expected: []string{"bestmove 0000"},
+		},
+	}
+
+	for _, tc := range tests {
+		var out strings.Builder
+		if err := RunUCI(strings.NewReader(tc.input+"quit\n"), &out); err != nil {
+			t.Fatalf("%v: Failed to run UCI: %v", tc.name, err)
+		}
+
+		// The expected lines are prefixes of the output lines, in order
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		next := 0
+		for _, line := range lines {
+			if next < len(tc.expected) && strings.HasPrefix(line, tc.expected[next]) {
+				next++
+			}
+		}
+		if next != len(tc.expected) {
+			t.Errorf("%v: Expected the lines %q in order, got %q", tc.name, tc.expected, lines)
+		}
+
+		// Every go is answered with one best move, a go ponder only once it is hit or stopped
+		var bestMoves, expectedBestMoves []string
+		for _, line := range lines {
+			if strings.HasPrefix(line, "bestmove") {
+				bestMoves = append(bestMoves, line)
+			}
+		}
+		for _, line := range tc.expected {
+			if strings.HasPrefix(line, "bestmove") {
+				expectedBestMoves = append(expectedBestMoves, line)
+			}
+		}
+		if len(bestMoves) != len(expectedBestMoves) {
+			t.Errorf("%v: Expected %d best moves, got %q", tc.name, len(expectedBestMoves), bestMoves)
+		}
+		if tc.ponder && (len(bestMoves) == 0 || len(strings.Fields(bestMoves[0])) != 4 || strings.Fields(bestMoves[0])[2] != "ponder") {
+			t.Errorf("%v: Expected the best move with the reply to ponder on, got %q", tc.name, bestMoves)
+		}
+		if PONDER != nil {
+			t.Errorf("%v: Expected the ponder search to be done", tc.name)
+		}
+	}
+}
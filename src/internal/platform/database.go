@@ -91,3 +91,9 @@ func StartGame(gameId string, ctx context.Context) error {
 
 	return nil
 }
+
+// Function used to end a game, so it no longer counts as an active game
+func EndGame(gameId string, ctx context.Context) error {
+	_, err := db.ExecContext(ctx, "UPDATE games SET Status = 'Finished' WHERE GameID = ?", gameId)
+	return err
+}